@@ -143,11 +143,13 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolRejournalFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
+			utils.TxPoolPriceCeilingFlag,
 			utils.TxPoolAccountSlotsFlag,
 			utils.TxPoolGlobalSlotsFlag,
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolEntrypointAllowlistFlag,
 		},
 	},
 	{
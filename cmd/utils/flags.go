@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
@@ -357,6 +358,11 @@ var (
 		Usage: "Price bump percentage to replace an already existing transaction",
 		Value: eth.DefaultConfig.TxPool.PriceBump,
 	}
+	TxPoolPriceCeilingFlag = cli.StringFlag{
+		Name:   "txpool.priceceiling",
+		Usage:  "Maximum gas price to enforce for acceptance into the pool (default = unrestricted)",
+		EnvVar: "TXPOOL_PRICE_CEILING",
+	}
 	TxPoolAccountSlotsFlag = cli.Uint64Flag{
 		Name:  "txpool.accountslots",
 		Usage: "Minimum number of executable transaction slots guaranteed per account",
@@ -382,6 +388,11 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolEntrypointAllowlistFlag = cli.StringFlag{
+		Name:   "txpool.entrypointallowlist",
+		Usage:  "Comma separated contracts sequencer transactions may call (default = unrestricted)",
+		EnvVar: "TXPOOL_ENTRYPOINT_ALLOWLIST",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:   "cache",
@@ -1429,6 +1440,13 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolPriceBumpFlag.Name) {
 		cfg.PriceBump = ctx.GlobalUint64(TxPoolPriceBumpFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolPriceCeilingFlag.Name) {
+		ceiling, ok := math.ParseBig256(ctx.GlobalString(TxPoolPriceCeilingFlag.Name))
+		if !ok {
+			Fatalf("Invalid gas price in --txpool.priceceiling: %s", ctx.GlobalString(TxPoolPriceCeilingFlag.Name))
+		}
+		cfg.PriceCeiling = ceiling
+	}
 	if ctx.GlobalIsSet(TxPoolAccountSlotsFlag.Name) {
 		cfg.AccountSlots = ctx.GlobalUint64(TxPoolAccountSlotsFlag.Name)
 	}
@@ -1444,6 +1462,17 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolEntrypointAllowlistFlag.Name) {
+		allowlist := strings.Split(ctx.GlobalString(TxPoolEntrypointAllowlistFlag.Name), ",")
+		cfg.EntrypointAllowlist = make(map[common.Address]bool, len(allowlist))
+		for _, contract := range allowlist {
+			if trimmed := strings.TrimSpace(contract); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid contract in --txpool.entrypointallowlist: %s", trimmed)
+			} else {
+				cfg.EntrypointAllowlist[common.HexToAddress(trimmed)] = true
+			}
+		}
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
@@ -1703,7 +1732,11 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		addrManagerOwnerAddress := cfg.Rollup.AddressManagerOwnerAddress
 		l1ETHGatewayAddress := cfg.Rollup.L1ETHGatewayAddress
 		stateDumpPath := cfg.Rollup.StateDumpPath
-		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address, xdomainAddress, l1ETHGatewayAddress, addrManagerOwnerAddress, stateDumpPath, chainID, gasLimit)
+		genesis, err := core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address, xdomainAddress, l1ETHGatewayAddress, addrManagerOwnerAddress, stateDumpPath, chainID, gasLimit)
+		if err != nil {
+			Fatalf("Failed to create developer genesis: %v", err)
+		}
+		cfg.Genesis = genesis
 		if !ctx.GlobalIsSet(MinerGasPriceFlag.Name) && !ctx.GlobalIsSet(MinerLegacyGasPriceFlag.Name) {
 			cfg.Miner.GasPrice = big.NewInt(1)
 		}
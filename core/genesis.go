@@ -444,8 +444,10 @@ func DefaultGoerliGenesisBlock() *Genesis {
 	}
 }
 
-// DeveloperGenesisBlock returns the 'geth --dev' genesis block.
-func DeveloperGenesisBlock(period uint64, faucet, l1XDomainMessengerAddress common.Address, l1ETHGatewayAddress common.Address, addrManagerOwnerAddress common.Address, stateDumpPath string, chainID *big.Int, gasLimit uint64) *Genesis {
+// DeveloperGenesisBlock returns the 'geth --dev' genesis block. When the OVM
+// is enabled, it returns an error if the state dump cannot be fetched or is
+// missing one of the OVM contracts.
+func DeveloperGenesisBlock(period uint64, faucet, l1XDomainMessengerAddress common.Address, l1ETHGatewayAddress common.Address, addrManagerOwnerAddress common.Address, stateDumpPath string, chainID *big.Int, gasLimit uint64) (*Genesis, error) {
 	// Override the default period to the user requested one
 	config := *params.AllCliqueProtocolChanges
 	config.Clique.Period = period
@@ -458,28 +460,31 @@ func DeveloperGenesisBlock(period uint64, faucet, l1XDomainMessengerAddress comm
 	if vm.UsingOVM {
 		// Fetch the state dump from the state dump path
 		if stateDumpPath == "" {
-			panic("Must pass state dump path")
+			return nil, errors.New("must pass state dump path")
 		}
 		log.Info("Fetching state dump", "path", stateDumpPath)
 		err := fetchStateDump(stateDumpPath, &stateDump)
 		if err != nil {
-			panic(fmt.Sprintf("Cannot fetch state dump: %s", err))
+			return nil, fmt.Errorf("cannot fetch state dump: %v", err)
 		}
 		_, ok := stateDump.Accounts["Lib_AddressManager"]
 		if !ok {
-			panic("Lib_AddressManager not in state dump")
+			return nil, errors.New("Lib_AddressManager not in state dump")
 		}
 		_, ok = stateDump.Accounts["OVM_StateManager"]
 		if !ok {
-			panic("OVM_StateManager not in state dump")
+			return nil, errors.New("OVM_StateManager not in state dump")
 		}
-		_, ok = stateDump.Accounts["OVM_ExecutionManager"]
+		executionManager, ok := stateDump.Accounts["OVM_ExecutionManager"]
 		if !ok {
-			panic("OVM_ExecutionManager not in state dump")
+			return nil, errors.New("OVM_ExecutionManager not in state dump")
+		}
+		if err := CheckExecutionManagerABI(executionManager.ABI); err != nil {
+			return nil, fmt.Errorf("cannot use state dump: %w", err)
 		}
 		_, ok = stateDump.Accounts["OVM_SequencerEntrypoint"]
 		if !ok {
-			panic("OVM_SequencerEntrypoint not in state dump")
+			return nil, errors.New("OVM_SequencerEntrypoint not in state dump")
 		}
 	}
 	config.StateDump = &stateDump
@@ -503,7 +508,7 @@ func DeveloperGenesisBlock(period uint64, faucet, l1XDomainMessengerAddress comm
 		L1CrossDomainMessengerAddress: l1XDomainMessengerAddress,
 		AddressManagerOwnerAddress:    addrManagerOwnerAddress,
 		L1ETHGatewayAddress:           l1ETHGatewayAddress,
-	}
+	}, nil
 }
 
 func decodePrealloc(data string) GenesisAlloc {
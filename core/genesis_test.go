@@ -166,3 +166,12 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+func TestDeveloperGenesisBlockStateDump(t *testing.T) {
+	vm.UsingOVM = true
+	defer func() { vm.UsingOVM = false }()
+
+	if _, err := DeveloperGenesisBlock(0, common.Address{}, common.Address{}, common.Address{}, common.Address{}, "", nil, params.GenesisGasLimit); err == nil {
+		t.Fatal("expected an error without a state dump path")
+	}
+}
@@ -90,12 +90,22 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 			return nil, err
 		}
 	} else {
-		decompressor := config.StateDump.Accounts["OVM_SequencerEntrypoint"]
-		msg, err = asOvmMessage(tx, types.MakeSigner(config, header.Number), decompressor.Address)
+		signer := types.MakeSigner(config, header.Number)
+		executionManager := config.StateDump.Accounts["OVM_ExecutionManager"]
+		if err := checkDirectEMCall(config, header.Number, tx, signer, executionManager.Address); err != nil {
+			return nil, err
+		}
+		decompressor := sequencerDecompressor(config, header.Number)
+		msg, err = asOvmMessage(config, header.Number, tx, signer, decompressor)
 		if err != nil {
 			return nil, err
 		}
 	}
+	// The position of the transaction in the block is carried through the
+	// OVM wrapping of the message
+	if m, ok := msg.(types.Message); ok {
+		msg = m.WithTxIndex(uint(statedb.TxIndex()))
+	}
 	// Create a new context to be used in the EVM environment
 	context := NewEVMContext(msg, header, bc, author)
 	// Create a new environment which holds all relevant information
@@ -203,13 +203,17 @@ func (st *StateTransition) preCheck() error {
 // returning the result including the used gas. It returns an error if failed.
 // An error indicates a consensus issue.
 func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bool, err error) {
+	if vm.UsingOVM {
+		st.msg = applyDepositGasFloor(st.evm, st.msg)
+	}
 	if err = st.preCheck(); err != nil {
 		return
 	}
 
 	if vm.UsingOVM {
 		// OVM_ENABLED
-		if st.evm.EthCallSender == nil {
+		st.evm.Context.OvmDirectCall = st.evm.EthCallSender == nil && st.evm.ChainConfig().IsOvmGodAddress(st.evm.BlockNumber, st.msg.From())
+		if st.evm.EthCallSender == nil && !st.evm.Context.OvmDirectCall {
 			st.msg, err = toExecutionManagerRun(st.evm, st.msg)
 		}
 		st.data = st.msg.Data()
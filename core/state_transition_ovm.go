@@ -2,17 +2,164 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
+	"reflect"
+	"sync"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rollup/dump"
 )
 
 var ZeroAddress = common.HexToAddress("0x0000000000000000000000000000000000000000")
 
+// MetadataTxHash is the message metadata key holding the hash of the
+// transaction that a message was derived from.
+const MetadataTxHash = "txHash"
+
+var (
+	// ErrEntrypointNoCode is returned when the entrypoint of a sequencer
+	// transaction has no code and the chain config checks entrypoint code.
+	ErrEntrypointNoCode = errors.New("entrypoint has no code")
+
+	// ErrDirectEMCall is returned when a sequencer transaction targets the
+	// execution manager directly instead of being wrapped by it.
+	ErrDirectEMCall = errors.New("sequencer transaction cannot call the execution manager directly")
+
+	// ErrInvalidQueueOrigin is returned when a message has a missing or
+	// unknown queue origin.
+	ErrInvalidQueueOrigin = errors.New("invalid queue origin")
+
+	// ErrGodSelfCall is returned when a transaction sent by the god address
+	// targets the god address and the chain config does not allow it.
+	ErrGodSelfCall = errors.New("god address cannot call itself")
+
+	// ErrZeroTarget is returned when a sequencer transaction is sent to the
+	// zero address, which the compressed encoding reserves for contract
+	// creations. Creations must be built without a recipient.
+	ErrZeroTarget = errors.New("sequencer transaction to the zero address")
+
+	// ErrZeroGasLimit is returned when a sequencer transaction would be
+	// passed to the execution manager with no gas, which always fails.
+	ErrZeroGasLimit = errors.New("execution manager run with zero gas limit")
+
+	// ErrCompressedFieldOverflow is returned when the gas limit or nonce of
+	// a sequencer transaction does not fit in its 3 byte field of the
+	// compressed encoding.
+	ErrCompressedFieldOverflow = errors.New("value does not fit in compressed sequencer encoding")
+
+	// ErrNoGodAddress is returned when a god message is built while the
+	// chain config has no god address.
+	ErrNoGodAddress = errors.New("god address not set")
+
+	// ErrInvalidRunABI is returned when the execution manager ABI has no
+	// `run` method matching the arguments packed by toExecutionManagerRun.
+	ErrInvalidRunABI = errors.New("invalid execution manager run abi")
+
+	// ErrInvalidRunCalldata is returned when decoding calldata that is not
+	// an execution manager run.
+	ErrInvalidRunCalldata = errors.New("invalid execution manager run calldata")
+
+	// ErrEntrypointNotAllowed is returned when a sequencer transaction targets
+	// a contract that is not in the entrypoint allowlist.
+	ErrEntrypointNotAllowed = errors.New("entrypoint not allowed")
+
+	// ErrNondeterministicWrapping is returned when wrapping the same
+	// transaction twice results in different messages.
+	ErrNondeterministicWrapping = errors.New("nondeterministic message wrapping")
+
+	// ErrDecompressorInputTooLarge is returned when the compressed encoding
+	// of a sequencer transaction is larger than the decompressor accepts.
+	ErrDecompressorInputTooLarge = errors.New("decompressor input too large")
+
+	// ErrWrappingInvariant is returned when a transaction sent by the
+	// god address is routed through the sequencer decompressor, or another
+	// sequencer transaction bypasses it.
+	ErrWrappingInvariant = errors.New("wrapping invariant violated")
+)
+
+// maxCompressedField is the largest value of the 3 byte gas limit and nonce
+// fields of the compressed sequencer transaction encoding.
+const maxCompressedField = 1<<24 - 1
+
+// executionManagerRunSig is the signature of the execution manager method
+// that toExecutionManagerRun packs an ovmTransaction for.
+const executionManagerRunSig = "run((uint256,uint256,uint8,address,address,uint256,bytes),address)"
+
+// defaultSignatureHashType is the signature hash type that messages with an
+// undefined signature hash type are encoded as for the sequencer entrypoint,
+// unless the chain config sets another default. Such messages can only come
+// from transactions that are already in the canonical transaction chain,
+// since others are rejected by checkSignatureType.
+const defaultSignatureHashType = types.CreateEOA
+
+// defaultSighashType returns the signature hash type that messages with an
+// undefined signature hash type are encoded as at the block number, which is
+// the default of the chain config once its fork is active.
+func defaultSighashType(config *params.ChainConfig, number *big.Int) types.SignatureHashType {
+	if sighashType, ok := config.OvmDefaultSighashTypeAt(number); ok {
+		return types.SignatureHashType(sighashType)
+	}
+	return defaultSignatureHashType
+}
+
+// gasPriceScalar returns the divisor of the compressed gas price at the
+// block number, which is the one of the active decompressor fork if it sets
+// one and types.GasPriceScalar otherwise.
+func gasPriceScalar(config *params.ChainConfig, number *big.Int) uint64 {
+	if scalar, ok := config.OvmGasPriceScalarAt(number); ok {
+		return scalar
+	}
+	return types.GasPriceScalar
+}
+
+// sequencerDecompressor returns the address of the decompressor for the
+// block number, falling back to the OVM_SequencerEntrypoint of the state dump
+// if no decompressor fork of the chain config is active.
+func sequencerDecompressor(config *params.ChainConfig, number *big.Int) common.Address {
+	if addr, ok := config.OvmDecompressorAt(number); ok {
+		return addr
+	}
+	return config.StateDump.Accounts["OVM_SequencerEntrypoint"].Address
+}
+
+// revertSelector is the selector of the Error(string) revert data that
+// solidity returns for require and revert with a reason.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// RevertReason decodes the reason string from the return data of a reverted
+// message. When the OVM is enabled, the return data of a message wrapped for
+// the execution manager is the revert data of the inner call, see evm.Call.
+// It returns false if the return data is not an Error(string).
+func RevertReason(ret []byte) (string, bool) {
+	if len(ret) < 4 || !bytes.Equal(ret[:4], revertSelector) {
+		return "", false
+	}
+	typ, err := abi.NewType("string", "", nil)
+	if err != nil {
+		return "", false
+	}
+	var reason string
+	if err := (abi.Arguments{{Type: typ}}).Unpack(&reason, ret[4:]); err != nil {
+		return "", false
+	}
+	return reason, true
+}
+
+// IsDecompressorMessage returns whether the message is sent to the sequencer
+// decompressor active at the block number, which is the case for sequencer
+// transactions wrapped by asOvmMessage.
+func IsDecompressorMessage(config *params.ChainConfig, number *big.Int, msg Message) bool {
+	to := msg.To()
+	return to != nil && *to == sequencerDecompressor(config, number)
+}
+
 type ovmTransaction struct {
 	Timestamp     *big.Int       "json:\"timestamp\""
 	BlockNumber   *big.Int       "json:\"blockNumber\""
@@ -24,42 +171,685 @@ type ovmTransaction struct {
 }
 
 func toExecutionManagerRun(evm *vm.EVM, msg Message) (Message, error) {
+	// The check is opt in, because some flows create the entrypoint account
+	// within the same transaction.
+	if evm.ChainConfig().IsOvmEntrypointCodeCheck(evm.BlockNumber) {
+		if err := checkEntrypointCode(evm, msg); err != nil {
+			return nil, err
+		}
+	}
+
+	ret, err := ExecutionManagerRunCalldata(evm, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	outputmsg, err := modMessage(
+		msg,
+		msg.From(),
+		&evm.Context.OvmExecutionManager.Address,
+		ret,
+		evm.Context.GasLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return outputmsg, nil
+}
+
+// ExecutionManagerRunCalldata returns the calldata of the execution manager
+// run that toExecutionManagerRun wraps the message in.
+func ExecutionManagerRunCalldata(evm *vm.EVM, msg Message) ([]byte, error) {
+	gasLimit, err := executionManagerGasLimit(evm, msg)
+	if err != nil {
+		return nil, err
+	}
+	if qo := msg.QueueOrigin(); gasLimit == 0 && (qo == nil || qo.Uint64() != uint64(types.QueueOriginL1ToL2)) {
+		return nil, ErrZeroGasLimit
+	}
+	// The zero address entrypoint represents a contract creation
+	var entrypoint common.Address
+	if msg.To() != nil {
+		entrypoint = *msg.To()
+	}
+
+	// From the OVM L1 block number fork onwards, deposits carry the
+	// authoritative L1 block number, which takes precedence over the block
+	// number of the context.
+	blockNumber := evm.Context.BlockNumber
+	if qo := msg.QueueOrigin(); qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2) && msg.L1BlockNumber() != nil && evm.ChainConfig().IsOvmL1BlockNumber(blockNumber) {
+		blockNumber = msg.L1BlockNumber()
+	}
+
 	tx := ovmTransaction{
-		evm.Context.Time,
-		evm.Context.BlockNumber, // TODO (what's the correct block number?)
+		ResolveTimestamp(evm.ChainConfig(), msg, evm.Context),
+		blockNumber,
 		uint8(msg.QueueOrigin().Uint64()),
 		*msg.L1MessageSender(),
-		*msg.To(),
-		big.NewInt(int64(msg.Gas())),
+		entrypoint,
+		new(big.Int).SetUint64(gasLimit),
 		msg.Data(),
 	}
 
 	var abi = evm.Context.OvmExecutionManager.ABI
+	if err := checkExecutionManagerABIOnce(abi); err != nil {
+		return nil, err
+	}
 	var args = []interface{}{
 		tx,
 		evm.Context.OvmStateManager.Address,
 	}
 
-	ret, err := abi.Pack("run", args...)
+	return abi.Pack("run", args...)
+}
+
+// FromExecutionManagerRunCalldata decodes the calldata of an execution
+// manager run and rebuilds an approximation of the transaction that was
+// wrapped in it, for inspection. The recipient, data, gas limit, queue
+// origin, L1 message sender and block number are recovered. The nonce, value,
+// gas price and signature are not part of the run and are left unset, so the
+// transaction cannot be executed or sent as is.
+func FromExecutionManagerRunCalldata(emABI abi.ABI, data []byte) (*types.Transaction, error) {
+	if err := CheckExecutionManagerABI(emABI); err != nil {
+		return nil, err
+	}
+	method := emABI.Methods["run"]
+	if len(data) < 4 || !bytes.Equal(data[:4], method.ID()) {
+		return nil, fmt.Errorf("%w: not a run call", ErrInvalidRunCalldata)
+	}
+	var run struct {
+		Transaction     ovmTransaction
+		OvmStateManager common.Address
+	}
+	if err := method.Inputs.Unpack(&run, data[4:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRunCalldata, err)
+	}
+	ovmTx := run.Transaction
+	queueOrigin, err := getQueueOrigin(new(big.Int).SetUint64(uint64(ovmTx.L1QueueOrigin)))
 	if err != nil {
 		return nil, err
 	}
+	if !ovmTx.GasLimit.IsUint64() {
+		return nil, fmt.Errorf("%w: gas limit %d", ErrInvalidRunCalldata, ovmTx.GasLimit)
+	}
+	gasLimit := ovmTx.GasLimit.Uint64()
+	// The zero address entrypoint represents a contract creation
+	if ovmTx.Entrypoint == (common.Address{}) {
+		return types.NewContractCreation(0, new(big.Int), gasLimit, new(big.Int), ovmTx.Data, &ovmTx.L1TxOrigin, ovmTx.BlockNumber, queueOrigin), nil
+	}
+	return types.NewTransaction(0, ovmTx.Entrypoint, new(big.Int), gasLimit, new(big.Int), ovmTx.Data, &ovmTx.L1TxOrigin, ovmTx.BlockNumber, queueOrigin, types.SighashEIP155), nil
+}
 
-	outputmsg, err := modMessage(
-		msg,
-		msg.From(),
-		&evm.Context.OvmExecutionManager.Address,
-		ret,
-		evm.Context.GasLimit,
-	)
+// ResolveTimestamp returns the timestamp the message is executed at. From the
+// OVM L1 timestamp fork onwards the message carries the authoritative
+// timestamp: for sequencer transactions it is the timestamp assigned by the
+// sequencer, for deposits it is the timestamp of the L1 block the deposit was
+// enqueued in. It can differ from the time of the context when blocks are
+// replayed, so the context time is only used for messages without a
+// timestamp and for blocks before the fork.
+func ResolveTimestamp(config *params.ChainConfig, msg Message, ctx vm.Context) *big.Int {
+	if !config.IsOvmL1Timestamp(ctx.BlockNumber) {
+		return ctx.Time
+	}
+	if m, ok := msg.(interface{ L1Timestamp() uint64 }); ok && m.L1Timestamp() != 0 {
+		return new(big.Int).SetUint64(m.L1Timestamp())
+	}
+	return ctx.Time
+}
+
+// CheckExecutionManagerABI checks that the execution manager ABI has the
+// `run` method that messages are wrapped in.
+func CheckExecutionManagerABI(emABI abi.ABI) error {
+	method, ok := emABI.Methods["run"]
+	if !ok {
+		return fmt.Errorf("%w: no run method", ErrInvalidRunABI)
+	}
+	if sig := method.Sig(); sig != executionManagerRunSig {
+		return fmt.Errorf("%w: have %s, want %s", ErrInvalidRunABI, sig, executionManagerRunSig)
+	}
+	return nil
+}
+
+// runABIChecks caches the results of CheckExecutionManagerABI by the method
+// set of the execution manager ABI, which all copies of the ABI share. The
+// cached entries keep their method set alive, so that its address cannot be
+// reused by another ABI.
+var runABIChecks sync.Map // map[uintptr]*runABICheck
+
+type runABICheck struct {
+	methods map[string]abi.Method
+	once    sync.Once
+	err     error
+}
+
+// checkExecutionManagerABIOnce runs CheckExecutionManagerABI once for each
+// execution manager ABI and returns the cached result afterwards, so that
+// the ABI is not checked again for every message that is wrapped.
+func checkExecutionManagerABIOnce(emABI abi.ABI) error {
+	key := reflect.ValueOf(emABI.Methods).Pointer()
+	v, _ := runABIChecks.LoadOrStore(key, &runABICheck{methods: emABI.Methods})
+	check := v.(*runABICheck)
+	check.once.Do(func() { check.err = CheckExecutionManagerABI(emABI) })
+	return check.err
+}
+
+// executionManagerGasLimit returns the gas limit passed to the execution
+// manager run for the message. The wrapped message is a call to the
+// execution manager and only pays the intrinsic gas of a call, so from the
+// OVM creation gas fork onwards the additional intrinsic gas of a contract
+// creation is deducted from the gas available to the creation.
+func executionManagerGasLimit(evm *vm.EVM, msg Message) (uint64, error) {
+	config := evm.ChainConfig()
+	if msg.To() != nil || !config.IsOvmCreationGas(evm.BlockNumber) || !config.IsHomestead(evm.BlockNumber) {
+		return msg.Gas(), nil
+	}
+	creationCost := params.TxGasContractCreation - params.TxGas
+	if msg.Gas() < creationCost {
+		return 0, fmt.Errorf("%w: creation with gas %d", ErrIntrinsicGas, msg.Gas())
+	}
+	return msg.Gas() - creationCost, nil
+}
+
+// WrappingDataCost returns the extra intrinsic calldata gas introduced by
+// wrapping the message with toExecutionManagerRun, compared to the calldata
+// of the message itself. The EVM is required because the packed calldata
+// depends on the execution manager ABI and the block context.
+func WrappingDataCost(evm *vm.EVM, msg Message) (uint64, error) {
+	wrapped, err := toExecutionManagerRun(evm, msg)
+	if err != nil {
+		return 0, err
+	}
+	homestead := evm.ChainConfig().IsHomestead(evm.BlockNumber)
+	istanbul := evm.ChainConfig().IsIstanbul(evm.BlockNumber)
+	raw, err := IntrinsicGas(msg.Data(), false, homestead, istanbul)
+	if err != nil {
+		return 0, err
+	}
+	packed, err := IntrinsicGas(wrapped.Data(), false, homestead, istanbul)
+	if err != nil {
+		return 0, err
+	}
+	return packed - raw, nil
+}
+
+// DecompressorGasOverhead estimates the gas consumed by the sequencer
+// decompressor itself when handling a compressed payload of the given size,
+// as routed by asOvmMessage. It counts the work the decompressor has to do
+// for every transaction: copying the payload into memory, hashing it,
+// recovering the signer and calling the target. The estimate is a lower
+// bound, as the control flow of the contract is not included.
+func DecompressorGasOverhead(data []byte) uint64 {
+	words := toWordSize(uint64(len(data)))
+	memory := words*params.MemoryGas + words*words/params.QuadCoeffDiv
+	copying := words * params.CopyGas
+	hashing := params.Sha3Gas + words*params.Sha3WordGas
+	return memory + copying + hashing + params.EcrecoverGas + params.CallGasEIP150
+}
+
+// ethSignMessageSize is the size of the personal message hashed for
+// SighashEthSign transactions, the 28 byte prefix and the 32 byte digest.
+const ethSignMessageSize = 28 + 32
+
+// EthSignOverhead returns the extra gas the decompressor uses to recover the
+// signer of a SighashEthSign transaction compared to an EIP155 one. The
+// fields are hashed in both cases, but EthSign transactions additionally
+// hash the personal message built from that digest.
+func EthSignOverhead() uint64 {
+	return params.Sha3Gas + toWordSize(ethSignMessageSize)*params.Sha3WordGas
+}
+
+// CanAffordWrapping reports whether balance covers the value of the
+// transaction and the gas of its intrinsic cost, the wrapping calldata cost
+// and the decompressor overhead at the gas price of the transaction. The
+// overheads only apply to transactions that are wrapped, see WouldWrap.
+func CanAffordWrapping(evm *vm.EVM, tx *types.Transaction, signer types.Signer, balance *big.Int) (bool, error) {
+	homestead := evm.ChainConfig().IsHomestead(evm.BlockNumber)
+	istanbul := evm.ChainConfig().IsIstanbul(evm.BlockNumber)
+	gas, err := IntrinsicGas(tx.Data(), tx.To() == nil, homestead, istanbul)
+	if err != nil {
+		return false, err
+	}
+	overhead, err := wrappingOverheadGas(evm, tx, signer)
+	if err != nil {
+		return false, err
+	}
+	gas += overhead
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gas), tx.GasPrice())
+	cost.Add(cost, tx.Value())
+	return balance.Cmp(cost) >= 0, nil
+}
+
+// wrappingOverheadGas returns the wrapping calldata cost and the decompressor
+// overhead of the transaction, or zero if it is not wrapped.
+func wrappingOverheadGas(evm *vm.EVM, tx *types.Transaction, signer types.Signer) (uint64, error) {
+	decompressor := sequencerDecompressor(evm.ChainConfig(), evm.BlockNumber)
+	msg, err := asOvmMessage(evm.ChainConfig(), evm.BlockNumber, tx, signer, decompressor)
+	if err != nil {
+		return 0, err
+	}
+	qo := msg.QueueOrigin()
+	deposit := qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2)
+	if deposit || evm.ChainConfig().IsOvmGodAddress(evm.BlockNumber, msg.From()) {
+		return 0, nil
+	}
+	wrapping, err := WrappingDataCost(evm, msg)
+	if err != nil {
+		return 0, err
+	}
+	overhead := wrapping + DecompressorGasOverhead(msg.Data())
+	if tx.SignatureHashType() == types.SighashEthSign {
+		overhead += EthSignOverhead()
+	}
+	return overhead, nil
+}
+
+// AllInCostEstimate returns the most the transaction can cost its sender: the
+// gas limit at the gas price plus the value, the L1 data fee at the given L1
+// base fee and the wrapping overhead at the gas price. Deposits originate on
+// L1 and pay no L1 data fee.
+func AllInCostEstimate(evm *vm.EVM, tx *types.Transaction, signer types.Signer, baseFee *big.Int) (*big.Int, error) {
+	l2Fee, l1Fee, overheadFee, err := FeeBreakdown(evm, tx, signer, baseFee)
 	if err != nil {
 		return nil, err
 	}
+	cost := new(big.Int).Add(l2Fee, l1Fee)
+	cost.Add(cost, overheadFee)
+	return cost.Add(cost, tx.Value()), nil
+}
 
-	return outputmsg, nil
+// FeeBreakdown splits the fees included in AllInCostEstimate into the L2
+// execution fee of the gas limit, the L1 data fee and the fee of the wrapping
+// overhead. The value of the transaction is not a fee and is not included.
+func FeeBreakdown(evm *vm.EVM, tx *types.Transaction, signer types.Signer, baseFee *big.Int) (l2Fee, l1Fee, overheadFee *big.Int, err error) {
+	overhead, err := wrappingOverheadGas(evm, tx, signer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	l2Fee = new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasPrice())
+	overheadFee = new(big.Int).Mul(new(big.Int).SetUint64(overhead), tx.GasPrice())
+	l1Fee = new(big.Int)
+	if qo := tx.QueueOrigin(); qo == nil || qo.Uint64() != uint64(types.QueueOriginL1ToL2) {
+		l1Fee = tx.L1DataFee(baseFee)
+	}
+	return l2Fee, l1Fee, overheadFee, nil
+}
+
+// ValidateIntrinsicGas returns ErrIntrinsicGas if the gas limit of the
+// transaction does not cover the intrinsic gas charged by the state
+// transition when running the OVM. That is the intrinsic gas of the wrapped
+// execution manager run, which includes the wrapping overhead, except for
+// transactions from the god address, which are not wrapped. The gas limit of
+// deposits is raised to the deposit gas floor of the chain config first.
+func ValidateIntrinsicGas(evm *vm.EVM, tx *types.Transaction, signer types.Signer) error {
+	required, err := ovmIntrinsicGas(evm, tx, signer)
+	if err != nil {
+		return err
+	}
+	provided := tx.Gas()
+	floor := evm.ChainConfig().OvmDepositGasFloorAt(evm.BlockNumber)
+	if qo := tx.QueueOrigin(); qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2) && provided < floor {
+		provided = floor
+	}
+	if provided < required {
+		return fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, provided, required)
+	}
+	return nil
+}
+
+// ovmIntrinsicGas returns the intrinsic gas that TransitionDb charges for
+// the transaction.
+func ovmIntrinsicGas(evm *vm.EVM, tx *types.Transaction, signer types.Signer) (uint64, error) {
+	homestead := evm.ChainConfig().IsHomestead(evm.BlockNumber)
+	istanbul := evm.ChainConfig().IsIstanbul(evm.BlockNumber)
+	decompressor := sequencerDecompressor(evm.ChainConfig(), evm.BlockNumber)
+	msg, err := asOvmMessage(evm.ChainConfig(), evm.BlockNumber, tx, signer, decompressor)
+	if err != nil {
+		return 0, err
+	}
+	if evm.ChainConfig().IsOvmGodAddress(evm.BlockNumber, msg.From()) {
+		return IntrinsicGas(msg.Data(), msg.To() == nil, homestead, istanbul)
+	}
+	wrapped, err := toExecutionManagerRun(evm, applyDepositGasFloor(evm, msg))
+	if err != nil {
+		return 0, err
+	}
+	return IntrinsicGas(wrapped.Data(), false, homestead, istanbul)
+}
+
+// AssertDeterministicWrapping wraps the transaction for the execution
+// manager twice, the way TransitionDb does, and returns
+// ErrNondeterministicWrapping if the resulting messages differ in any field,
+// including the packed run calldata. It catches wrapping that depends on
+// map iteration order or on the time, which would make re-execution
+// diverge.
+func AssertDeterministicWrapping(evm *vm.EVM, tx *types.Transaction, signer types.Signer) error {
+	first, err := wrapTransaction(evm, tx, signer)
+	if err != nil {
+		return err
+	}
+	second, err := wrapTransaction(evm, tx, signer)
+	if err != nil {
+		return err
+	}
+	if field := messageDiff(first, second); field != "" {
+		return fmt.Errorf("%w: %s differs", ErrNondeterministicWrapping, field)
+	}
+	return nil
+}
+
+// wrapTransaction returns the message that TransitionDb executes for the
+// transaction, which is wrapped for the execution manager unless it is sent
+// by the god address.
+func wrapTransaction(evm *vm.EVM, tx *types.Transaction, signer types.Signer) (Message, error) {
+	decompressor := sequencerDecompressor(evm.ChainConfig(), evm.BlockNumber)
+	msg, err := asOvmMessage(evm.ChainConfig(), evm.BlockNumber, tx, signer, decompressor)
+	if err != nil {
+		return nil, err
+	}
+	if evm.ChainConfig().IsOvmGodAddress(evm.BlockNumber, msg.From()) {
+		return msg, nil
+	}
+	return toExecutionManagerRun(evm, applyDepositGasFloor(evm, msg))
+}
+
+// WrappedMessageID returns an identifier of the message that the transaction
+// is executed as in the block context of the EVM, for caching across retried
+// block builds. It is computed over the transaction hash and the recipient
+// and calldata of the wrapped message, so it only depends on the transaction
+// and the block context, never on the wall clock.
+func WrappedMessageID(evm *vm.EVM, tx *types.Transaction, signer types.Signer) (common.Hash, error) {
+	msg, err := wrapTransaction(evm, tx, signer)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var to common.Address
+	if msg.To() != nil {
+		to = *msg.To()
+	}
+	return crypto.Keccak256Hash(tx.Hash().Bytes(), to.Bytes(), msg.Data()), nil
+}
+
+// messageDiff returns the name of the first field that differs between the
+// messages, or the empty string if they are equal.
+func messageDiff(a, b Message) string {
+	addrEqual := func(x, y *common.Address) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	bigEqual := func(x, y *big.Int) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && x.Cmp(y) == 0)
+	}
+	switch {
+	case a.From() != b.From():
+		return "from"
+	case !addrEqual(a.To(), b.To()):
+		return "to"
+	case a.Nonce() != b.Nonce():
+		return "nonce"
+	case !bigEqual(a.Value(), b.Value()):
+		return "value"
+	case a.Gas() != b.Gas():
+		return "gas"
+	case !bigEqual(a.GasPrice(), b.GasPrice()):
+		return "gasPrice"
+	case !bytes.Equal(a.Data(), b.Data()):
+		return "data"
+	case a.CheckNonce() != b.CheckNonce():
+		return "checkNonce"
+	case !addrEqual(a.L1MessageSender(), b.L1MessageSender()):
+		return "l1MessageSender"
+	case !bigEqual(a.L1BlockNumber(), b.L1BlockNumber()):
+		return "l1BlockNumber"
+	case !bigEqual(a.QueueOrigin(), b.QueueOrigin()):
+		return "queueOrigin"
+	case a.SignatureHashType() != b.SignatureHashType():
+		return "signatureHashType"
+	}
+	return ""
+}
+
+// toWordSize returns the number of 32 byte words needed to hold size bytes.
+func toWordSize(size uint64) uint64 {
+	return (size + 31) / 32
+}
+
+// HashExecutionManagerRun returns a deterministic hash of a message wrapped
+// by toExecutionManagerRun, computed over the execution manager address and
+// the packed `run` calldata. Identical wrapped messages hash equal.
+func HashExecutionManagerRun(msg Message, emAddr common.Address) common.Hash {
+	return crypto.Keccak256Hash(emAddr.Bytes(), msg.Data())
+}
+
+// checkEntrypointCode returns an error if the message is a sequencer
+// transaction whose entrypoint has no code in the current state.
+func checkEntrypointCode(evm *vm.EVM, msg Message) error {
+	qo := msg.QueueOrigin()
+	if qo != nil && qo.Uint64() != uint64(types.QueueOriginSequencer) {
+		return nil
+	}
+	// Creations have no entrypoint
+	if msg.To() == nil {
+		return nil
+	}
+	if evm.StateDB.GetCodeSize(*msg.To()) == 0 {
+		return fmt.Errorf("%w: %s", ErrEntrypointNoCode, msg.To().Hex())
+	}
+	return nil
+}
+
+// WouldWrap returns whether the transaction is transformed by asOvmMessage
+// and toExecutionManagerRun before execution, which is the case for all
+// transactions except those sent by the god address of the chain config.
+func WouldWrap(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer) (bool, error) {
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return false, err
+	}
+	return !config.IsOvmGodAddress(number, from), nil
+}
+
+// AssertWrappingInvariant returns ErrWrappingInvariant if asOvmMessage does
+// not route the transaction the way WouldWrap expects: transactions sent by
+// the god address must be passed through unchanged and all other sequencer
+// transactions must be sent to the given sequencer decompressor, which is
+// the one configured for the block the transaction is executed in. Deposits
+// do not go through the decompressor and must be passed through as well.
+func AssertWrappingInvariant(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer, decompressor common.Address) error {
+	msg, err := asOvmMessage(config, number, tx, signer, decompressor)
+	if err != nil {
+		return err
+	}
+	return checkWrappingInvariant(config, number, tx, signer, msg, decompressor)
+}
+
+// checkWrappingInvariant returns ErrWrappingInvariant if msg, the message
+// that the transaction was converted to, is not routed as WouldWrap
+// expects.
+func checkWrappingInvariant(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer, msg Message, decompressor common.Address) error {
+	wrap, err := WouldWrap(config, number, tx, signer)
+	if err != nil {
+		return err
+	}
+	if qo := tx.QueueOrigin(); qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2) {
+		wrap = false
+	}
+	to := msg.To()
+	passed := ((to == nil && tx.To() == nil) || (to != nil && tx.To() != nil && *to == *tx.To())) && bytes.Equal(msg.Data(), tx.Data())
+	wrapped := to != nil && *to == decompressor && !passed
+	switch {
+	case wrap && !wrapped:
+		return fmt.Errorf("%w: %s bypasses the decompressor", ErrWrappingInvariant, tx.Hash().Hex())
+	case !wrap && !passed:
+		return fmt.Errorf("%w: %s is not passed through", ErrWrappingInvariant, tx.Hash().Hex())
+	}
+	return nil
+}
+
+// NewGodMessage creates the message of a system operation sent by the god
+// address of the chain config at the block number. It is executed as is by
+// the state transition, without being wrapped for the execution manager, and
+// does not check or pay for the nonce and gas.
+func NewGodMessage(config *params.ChainConfig, number *big.Int, to common.Address, data []byte, gas uint64) (Message, error) {
+	god := config.OvmGodAddressAt(number)
+	if god == nil {
+		return nil, ErrNoGodAddress
+	}
+	return types.NewMessage(*god, &to, 0, new(big.Int), gas, new(big.Int), data, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155), nil
+}
+
+// checkGodSelfCall returns ErrGodSelfCall if the message is sent by the god
+// address to the god address, unless the chain config allows it.
+func checkGodSelfCall(config *params.ChainConfig, number *big.Int, msg Message) error {
+	if config.IsOvmGodSelfCallAllowed(number) || !config.IsOvmGodAddress(number, msg.From()) {
+		return nil
+	}
+	if to := msg.To(); to != nil && config.IsOvmGodAddress(number, *to) {
+		return fmt.Errorf("%w: %s", ErrGodSelfCall, to.Hex())
+	}
+	return nil
+}
+
+// checkDirectEMCall returns ErrDirectEMCall if the transaction is a
+// sequencer transaction that targets the execution manager, which would
+// bypass the wrapping done by toExecutionManagerRun. Transactions sent by
+// the god address are allowed, as are transactions that are already in the
+// canonical transaction chain, which must be executed as they were enqueued.
+func checkDirectEMCall(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer, executionManager common.Address) error {
+	qo := tx.QueueOrigin()
+	if qo != nil && qo.Uint64() != uint64(types.QueueOriginSequencer) {
+		return nil
+	}
+	if tx.GetMeta().Index != nil {
+		return nil
+	}
+	if tx.To() == nil || *tx.To() != executionManager {
+		return nil
+	}
+	from, err := types.Sender(signer, tx)
+	if err == nil && config.IsOvmGodAddress(number, from) {
+		return nil
+	}
+	return ErrDirectEMCall
+}
+
+// checkZeroTarget returns ErrZeroTarget if the transaction is a sequencer
+// transaction sent to the zero address, the same check asOvmMessage makes
+// when encoding it. Contract creations have no recipient and are allowed, as
+// are deposits and transactions sent by the god address, which are not
+// encoded.
+func checkZeroTarget(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer) error {
+	qo := tx.QueueOrigin()
+	if qo != nil && qo.Uint64() != uint64(types.QueueOriginSequencer) {
+		return nil
+	}
+	if tx.To() == nil || *tx.To() != ZeroAddress {
+		return nil
+	}
+	from, err := types.Sender(signer, tx)
+	if err == nil && config.IsOvmGodAddress(number, from) {
+		return nil
+	}
+	return ErrZeroTarget
+}
+
+// ValidateEntrypointAllowed returns ErrEntrypointNotAllowed if the
+// transaction is a sequencer transaction whose target is not in allow.
+// Contract creations have no target and are rejected as well. Deposits and
+// transactions sent by the god address are not restricted.
+func ValidateEntrypointAllowed(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer, allow map[common.Address]bool) error {
+	qo := tx.QueueOrigin()
+	if qo != nil && qo.Uint64() != uint64(types.QueueOriginSequencer) {
+		return nil
+	}
+	if to := tx.To(); to != nil && allow[*to] {
+		return nil
+	}
+	from, err := types.Sender(signer, tx)
+	if err == nil && config.IsOvmGodAddress(number, from) {
+		return nil
+	}
+	if tx.To() == nil {
+		return fmt.Errorf("%w: contract creation", ErrEntrypointNotAllowed)
+	}
+	return fmt.Errorf("%w: %s", ErrEntrypointNotAllowed, tx.To().Hex())
+}
+
+// OVMConfig holds the parameters of the validation done by AdmitTransaction.
+// A zero size limit disables the corresponding check.
+type OVMConfig struct {
+	Number *big.Int // Number of the block the transaction is admitted for, which selects the fork rules
+
+	MaxTxSize            uint64 // Maximum size of the RLP encoding of a transaction
+	MaxDecompressorInput int    // Maximum size of the compressed encoding of a sequencer transaction
+	MaxDepositDataSize   int    // Maximum calldata size of an L1 to L2 deposit
+}
+
+// AdmitTransaction runs the validation a transaction has to pass before it
+// is admitted to the pool, in order, and returns the first error: the
+// signature format, the signature hash type and sender, the intrinsic gas,
+// the coherence of the OVM fields, the representability in the compressed
+// encoding and the size limits. Deposits are not signed or compressed and
+// skip those checks.
+func AdmitTransaction(config *params.ChainConfig, tx *types.Transaction, signer types.Signer, cfg OVMConfig) error {
+	qo := tx.QueueOrigin()
+	deposit := qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2)
+
+	if err := types.ValidateSignatureFormat(tx); err != nil {
+		return err
+	}
+	if !deposit {
+		if err := checkSignatureType(tx, signer); err != nil {
+			return err
+		}
+	}
+	homestead := config.IsHomestead(cfg.Number)
+	istanbul := config.IsIstanbul(cfg.Number)
+	gas, err := IntrinsicGas(tx.Data(), tx.To() == nil, homestead, istanbul)
+	if err != nil {
+		return err
+	}
+	if tx.Gas() < gas {
+		return fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, tx.Gas(), gas)
+	}
+	if err := types.ValidateOVMTransaction(tx); err != nil {
+		return err
+	}
+	if !deposit && !types.GasPriceRepresentableWithScalar(tx.GasPrice(), gasPriceScalar(config, cfg.Number)) {
+		return ErrGasPriceNotRepresentable
+	}
+	if err := DryRunAsOvmMessage(config, cfg.Number, tx, signer); err != nil {
+		return err
+	}
+	if cfg.MaxTxSize != 0 && uint64(tx.Size()) > cfg.MaxTxSize {
+		return ErrOversizedData
+	}
+	if cfg.MaxDecompressorInput != 0 {
+		if err := ValidateDecompressorInputSize(config, cfg.Number, tx, signer, cfg.MaxDecompressorInput); err != nil {
+			return err
+		}
+	}
+	if cfg.MaxDepositDataSize != 0 {
+		if err := types.ValidateDepositDataSize(tx, cfg.MaxDepositDataSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSignatureType returns types.ErrSighashMismatch if the signature hash
+// type of the transaction is not one that the sequencer entrypoint
+// understands, or if the sender cannot be recovered under it.
+func checkSignatureType(tx *types.Transaction, signer types.Signer) error {
+	if !isKnownSighashType(tx.SignatureHashType()) {
+		return fmt.Errorf("%w: unknown type %d", types.ErrSighashMismatch, tx.SignatureHashType())
+	}
+	if _, err := types.Sender(signer, tx); err != nil {
+		return fmt.Errorf("%w: %s: %v", types.ErrSighashMismatch, tx.SignatureHashType(), err)
+	}
+	return nil
 }
 
-func asOvmMessage(tx *types.Transaction, signer types.Signer, decompressor common.Address) (Message, error) {
+func asOvmMessage(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer, decompressor common.Address) (Message, error) {
 	msg, err := tx.AsMessage(signer)
 	if err != nil {
 		// This should only be allowed to pass if the transaction is in the ctc
@@ -69,6 +859,16 @@ func asOvmMessage(tx *types.Transaction, signer types.Signer, decompressor commo
 			return msg, fmt.Errorf("Cannot convert tx to message in asOvmMessage: %w", err)
 		}
 	}
+	msg = msg.WithMetadata(map[string]interface{}{
+		MetadataTxHash: tx.Hash(),
+	})
+	if err := checkGodSelfCall(config, number, msg); err != nil {
+		return msg, err
+	}
+	// Transactions from the god address are not wrapped
+	if config.IsOvmGodAddress(number, msg.From()) {
+		return msg, nil
+	}
 
 	// Queue origin L1ToL2 transactions do not go through the
 	// sequencer entrypoint. The calldata is expected to be in the
@@ -79,8 +879,24 @@ func asOvmMessage(tx *types.Transaction, signer types.Signer, decompressor commo
 		return msg, nil
 	}
 
+	// The signature type byte is derived from the signature hash type, so
+	// make sure that the signature was produced under it.
+	if tx.GetMeta().Index == nil {
+		if err := checkSignatureType(tx, signer); err != nil {
+			return msg, err
+		}
+	}
+
 	v, r, s := tx.RawSignatureValues()
 
+	// A zero r or s is never a valid signature and would only be rejected
+	// by the decompressor during execution.
+	if r.Sign() == 0 || s.Sign() == 0 {
+		if tx.GetMeta().Index == nil {
+			return msg, fmt.Errorf("%w: zero signature r or s", types.ErrInvalidSig)
+		}
+	}
+
 	// V parameter here will include the chain ID, so we need to recover the original V. If the V
 	// does not equal zero or one, we have an invalid parameter and need to throw an error.
 	// This is technically a duplicate check because it happens inside of
@@ -95,24 +911,39 @@ func asOvmMessage(tx *types.Transaction, signer types.Signer, decompressor commo
 
 	// Since we use a fixed encoding, we need to insert some placeholder address to represent that
 	// the user wants to create a contract (in this case, the zero address).
+	// A transaction to the zero address would be indistinguishable from a
+	// contract creation, so only a nil recipient marks a creation.
 	var target common.Address
 	if tx.To() == nil {
 		target = ZeroAddress
 	} else {
 		target = *tx.To()
+		if target == ZeroAddress && tx.GetMeta().Index == nil {
+			return msg, ErrZeroTarget
+		}
 	}
 
-	// Divide the gas price by one million to compress it
-	// before it is send to the sequencer entrypoint. This is to save
-	// space on calldata.
-	gasPrice := new(big.Int).Div(msg.GasPrice(), new(big.Int).SetUint64(1000000))
+	// Scale the gas price down to compress it before it is sent to the
+	// sequencer entrypoint. This is to save space on calldata.
+	encodedGasPrice, err := types.EncodeGasPriceWithScalar(msg.GasPrice(), gasPriceScalar(config, number))
+	if err != nil {
+		return msg, err
+	}
+	gasPrice := new(big.Int).SetUint64(uint64(encodedGasPrice))
+	if msg.Gas() > maxCompressedField {
+		return msg, fmt.Errorf("%w: gas limit %d", ErrCompressedFieldOverflow, msg.Gas())
+	}
+	if msg.Nonce() > maxCompressedField {
+		return msg, fmt.Errorf("%w: nonce %d", ErrCompressedFieldOverflow, msg.Nonce())
+	}
 
 	// Sequencer uses a custom encoding structure --
 	// We originally receive sequencer transactions encoded in this way, but we decode them before
 	// inserting into Geth so we can make transactions easily parseable. However, this means that
 	// we need to re-encode the transactions before executing them.
+	sigType := getSignatureType(msg, defaultSighashType(config, number))
 	var data = new(bytes.Buffer)
-	data.WriteByte(getSignatureType(msg))                    // 1 byte: 00 == EIP 155, 02 == ETH Sign Message
+	data.WriteByte(sigType)                                  // 1 byte: 00 == EIP 155, 02 == ETH Sign Message
 	data.Write(fillBytes(r, 32))                             // 32 bytes: Signature `r` parameter
 	data.Write(fillBytes(s, 32))                             // 32 bytes: Signature `s` parameter
 	data.Write(fillBytes(v, 1))                              // 1 byte: Signature `v` parameter
@@ -139,6 +970,118 @@ func asOvmMessage(tx *types.Transaction, signer types.Signer, decompressor commo
 	return outmsg, nil
 }
 
+// ValidateDecompressorInputSize returns ErrDecompressorInputTooLarge if the
+// compressed encoding of the transaction that asOvmMessage passes to the
+// sequencer decompressor is longer than maxBytes. Deposits and transactions
+// sent by the god address do not go through the decompressor and are not
+// checked.
+func ValidateDecompressorInputSize(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer, maxBytes int) error {
+	input, err := decompressorInput(config, number, tx, signer)
+	if err != nil {
+		return err
+	}
+	if size := len(input); size > maxBytes {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrDecompressorInputTooLarge, size, maxBytes)
+	}
+	return nil
+}
+
+// CompressionSavings returns the size of the RLP encoding of the transaction
+// and the size of the compressed encoding that asOvmMessage passes to the
+// sequencer decompressor instead. Deposits and transactions sent by the
+// god address are not compressed and are reported with the same size for
+// both, as are transactions that cannot be encoded.
+func CompressionSavings(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer) (uncompressed, compressed int) {
+	uncompressed = int(tx.Size())
+	input, err := decompressorInput(config, number, tx, signer)
+	if err != nil || input == nil {
+		return uncompressed, uncompressed
+	}
+	return uncompressed, len(input)
+}
+
+// decompressorInput returns the compressed encoding of the transaction that
+// is passed to the sequencer decompressor, or nil if the transaction is a
+// deposit or sent by the god address and is not compressed.
+func decompressorInput(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer) ([]byte, error) {
+	msg, err := asOvmMessage(config, number, tx, signer, common.Address{})
+	if err != nil {
+		return nil, err
+	}
+	qo := msg.QueueOrigin()
+	if (qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2)) || config.IsOvmGodAddress(number, msg.From()) {
+		return nil, nil
+	}
+	return msg.Data(), nil
+}
+
+// EffectiveRecipient returns the recipient that the sender of the
+// transaction intended, which is nil for a contract creation. Unlike the To
+// of the message that the transaction is wrapped in, which is the sequencer
+// entrypoint or the execution manager, it is the target of the execution.
+func EffectiveRecipient(tx *types.Transaction) *common.Address {
+	return tx.To()
+}
+
+// DryRunAsOvmMessage runs the validation and encoding of asOvmMessage on the
+// transaction and returns the first error, discarding the message. It is a
+// cheap check that the transaction can be executed before it is pooled.
+func DryRunAsOvmMessage(config *params.ChainConfig, number *big.Int, tx *types.Transaction, signer types.Signer) error {
+	_, err := asOvmMessage(config, number, tx, signer, common.Address{})
+	return err
+}
+
+// SimulateOVMTransaction predicts whether the message would succeed when
+// executed by TransitionDb, and how much gas it would use, without
+// committing any state changes. The message is wrapped for the execution
+// manager unless it is sent by the god address, and the wrapped call is run
+// against a snapshot of the state that is reverted afterwards. Nonces and
+// balances are not checked and no gas is bought.
+func SimulateOVMTransaction(evm *vm.EVM, msg Message) (success bool, gasUsed uint64, err error) {
+	msg = applyDepositGasFloor(evm, msg)
+	direct := evm.ChainConfig().IsOvmGodAddress(evm.BlockNumber, msg.From())
+	if !direct {
+		if msg, err = toExecutionManagerRun(evm, msg); err != nil {
+			return false, 0, err
+		}
+	}
+	homestead := evm.ChainConfig().IsHomestead(evm.BlockNumber)
+	istanbul := evm.ChainConfig().IsIstanbul(evm.BlockNumber)
+	intrinsic, err := IntrinsicGas(msg.Data(), msg.To() == nil, homestead, istanbul)
+	if err != nil {
+		return false, 0, err
+	}
+	if msg.Gas() < intrinsic {
+		return false, 0, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, msg.Gas(), intrinsic)
+	}
+
+	snapshot := evm.StateDB.Snapshot()
+	defer evm.StateDB.RevertToSnapshot(snapshot)
+	defer func(prev bool) { evm.Context.OvmDirectCall = prev }(evm.Context.OvmDirectCall)
+	evm.Context.OvmDirectCall = direct
+
+	var (
+		sender = vm.AccountRef(msg.From())
+		gas    = msg.Gas() - intrinsic
+		vmerr  error
+	)
+	if msg.To() == nil {
+		_, _, gas, vmerr = evm.Create(sender, msg.Data(), gas, msg.Value())
+	} else {
+		_, gas, vmerr = evm.Call(sender, *msg.To(), msg.Data(), gas, msg.Value())
+	}
+	if vmerr == vm.ErrInsufficientBalance {
+		return false, 0, vmerr
+	}
+	// Apply the refund counter the same way refundGas does
+	used := msg.Gas() - gas
+	refund := used / 2
+	if refund > evm.StateDB.GetRefund() {
+		refund = evm.StateDB.GetRefund()
+	}
+	return vmerr == nil, used - refund, nil
+}
+
 func EncodeSimulatedMessage(msg Message, timestamp, blockNumber *big.Int, executionManager, stateManager dump.OvmDumpAccount) (Message, error) {
 	tx := ovmTransaction{
 		timestamp,
@@ -171,6 +1114,35 @@ func EncodeSimulatedMessage(msg Message, timestamp, blockNumber *big.Int, execut
 	)
 }
 
+// applyDepositGasFloor returns the message with its gas limit raised to the
+// deposit gas floor of the chain config if it is an L1 to L2 message below
+// the floor. The floor is the minimum gas limit L1 to L2 messages are
+// executed with, so that there is always enough gas for the bridge callback. Other
+// messages are returned unchanged.
+func applyDepositGasFloor(evm *vm.EVM, msg Message) Message {
+	floor := evm.ChainConfig().OvmDepositGasFloorAt(evm.BlockNumber)
+	qo := msg.QueueOrigin()
+	if qo == nil || qo.Uint64() != uint64(types.QueueOriginL1ToL2) || msg.Gas() >= floor {
+		return msg
+	}
+	queueOrigin, _ := getQueueOrigin(qo)
+	outmsg := types.NewMessage(
+		msg.From(),
+		msg.To(),
+		msg.Nonce(),
+		msg.Value(),
+		floor,
+		msg.GasPrice(),
+		msg.Data(),
+		msg.CheckNonce(),
+		msg.L1MessageSender(),
+		msg.L1BlockNumber(),
+		queueOrigin,
+		msg.SignatureHashType(),
+	)
+	return carryOver(msg, outmsg)
+}
+
 func modMessage(
 	msg Message,
 	from common.Address,
@@ -198,24 +1170,61 @@ func modMessage(
 		msg.SignatureHashType(),
 	)
 
-	return outmsg, nil
+	return carryOver(msg, outmsg), nil
+}
+
+// carryOver copies the tracing metadata, the originating transaction hash, the
+// timestamp and the block position of msg to outmsg, which replaces it.
+func carryOver(msg Message, outmsg types.Message) types.Message {
+	if m, ok := msg.(interface{ Metadata() map[string]interface{} }); ok {
+		outmsg = outmsg.WithMetadata(m.Metadata())
+	}
+	if m, ok := msg.(interface{ OriginTxHash() common.Hash }); ok {
+		outmsg = outmsg.WithOriginTxHash(m.OriginTxHash())
+	}
+	if m, ok := msg.(interface{ L1Timestamp() uint64 }); ok {
+		outmsg = outmsg.WithL1Timestamp(m.L1Timestamp())
+	}
+	if m, ok := msg.(interface{ TxIndex() uint }); ok {
+		outmsg = outmsg.WithTxIndex(m.TxIndex())
+	}
+	return outmsg
 }
 
 func getSignatureType(
 	msg Message,
+	fallback types.SignatureHashType,
 ) uint8 {
-	if msg.SignatureHashType() == 0 {
+	sighashType := msg.SignatureHashType()
+	if !isKnownSighashType(sighashType) {
+		sighashType = fallback
+	}
+	if sighashType == types.SighashEIP155 {
 		return 0
-	} else if msg.SignatureHashType() == 1 {
+	} else if sighashType == types.SighashEthSign {
 		return 2
 	} else {
 		return 1
 	}
 }
 
+// isKnownSighashType reports whether the signature hash type is one of
+// types.ValidSignatureHashTypes.
+func isKnownSighashType(sighashType types.SignatureHashType) bool {
+	for _, known := range types.ValidSignatureHashTypes() {
+		if sighashType == known {
+			return true
+		}
+	}
+	return false
+}
+
 func getQueueOrigin(
 	queueOrigin *big.Int,
 ) (types.QueueOrigin, error) {
+	if queueOrigin == nil {
+		return types.QueueOriginSequencer, ErrInvalidQueueOrigin
+	}
 	if queueOrigin.Cmp(big.NewInt(0)) == 0 {
 		return types.QueueOriginSequencer, nil
 	} else if queueOrigin.Cmp(big.NewInt(1)) == 0 {
@@ -223,7 +1232,7 @@ func getQueueOrigin(
 	} else if queueOrigin.Cmp(big.NewInt(2)) == 0 {
 		return types.QueueOriginL1ToL2, nil
 	} else {
-		return types.QueueOriginSequencer, fmt.Errorf("invalid queue origin: %d", queueOrigin)
+		return types.QueueOriginSequencer, fmt.Errorf("%w: %d", ErrInvalidQueueOrigin, queueOrigin)
 	}
 }
 
@@ -0,0 +1,2035 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup/dump"
+)
+
+const testExecutionManagerABI = `
+[
+	{
+		"type": "function",
+		"name": "run",
+		"inputs": [
+			{
+				"name": "_transaction",
+				"type": "tuple",
+				"components": [
+					{ "name": "timestamp", "type": "uint256" },
+					{ "name": "blockNumber", "type": "uint256" },
+					{ "name": "l1QueueOrigin", "type": "uint8" },
+					{ "name": "l1TxOrigin", "type": "address" },
+					{ "name": "entrypoint", "type": "address" },
+					{ "name": "gasLimit", "type": "uint256" },
+					{ "name": "data", "type": "bytes" }
+				]
+			},
+			{ "name": "_ovmStateManager", "type": "address" }
+		],
+		"outputs": []
+	}
+]
+`
+
+var (
+	testExecutionManagerAddress = common.HexToAddress("0x4200000000000000000000000000000000000001")
+	testStateManagerAddress     = common.HexToAddress("0x4200000000000000000000000000000000000002")
+)
+
+// newTestOvmEVM creates an EVM backed by an empty in memory state with an
+// execution manager that exposes the `run` method.
+func newTestOvmEVM(t *testing.T) *vm.EVM {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	emABI, err := abi.JSON(strings.NewReader(testExecutionManagerABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := *params.TestChainConfig
+	config.StateDump = &dump.OvmDump{
+		Accounts: map[string]dump.OvmDumpAccount{
+			"OVM_ExecutionManager": {
+				Address: testExecutionManagerAddress,
+				ABI:     emABI,
+			},
+			"OVM_StateManager": {
+				Address: testStateManagerAddress,
+			},
+		},
+	}
+	ctx := vm.Context{
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		GasLimit:    9000000,
+	}
+	return vm.NewEVM(ctx, statedb, &config, vm.Config{})
+}
+
+func newTestSequencerMessage(to common.Address) types.Message {
+	return types.NewMessage(common.Address{}, &to, 0, new(big.Int), 21000, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+}
+
+func TestToExecutionManagerRunEntrypointCode(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	evm.ChainConfig().OvmEntrypointCodeCheckBlock = big.NewInt(1)
+	empty := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	code := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	evm.StateDB.SetCode(code, []byte{0x60, 0x00})
+
+	_, err := toExecutionManagerRun(evm, newTestSequencerMessage(empty))
+	if !errors.Is(err, ErrEntrypointNoCode) {
+		t.Fatalf("expected %v, got %v", ErrEntrypointNoCode, err)
+	}
+
+	msg, err := toExecutionManagerRun(evm, newTestSequencerMessage(code))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *msg.To() != testExecutionManagerAddress {
+		t.Fatalf("expected message to target the execution manager, got %s", msg.To().Hex())
+	}
+
+	// The check is opt in, so an empty entrypoint is allowed before the
+	// fork block and by default.
+	evm.ChainConfig().OvmEntrypointCodeCheckBlock = big.NewInt(2)
+	if _, err := toExecutionManagerRun(evm, newTestSequencerMessage(empty)); err != nil {
+		t.Fatalf("unexpected error before the fork block: %v", err)
+	}
+	evm.ChainConfig().OvmEntrypointCodeCheckBlock = nil
+	if _, err := toExecutionManagerRun(evm, newTestSequencerMessage(empty)); err != nil {
+		t.Fatalf("unexpected error with check disabled: %v", err)
+	}
+}
+
+func TestCheckDirectEMCall(t *testing.T) {
+	config := *params.TestChainConfig
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.NewOVMSigner(big.NewInt(1))
+
+	sign := func(to common.Address, queueOrigin types.QueueOrigin) *types.Transaction {
+		tx := types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, queueOrigin, types.SighashEIP155)
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	direct := sign(testExecutionManagerAddress, types.QueueOriginSequencer)
+	if err := checkDirectEMCall(&config, common.Big0, direct, signer, testExecutionManagerAddress); err != ErrDirectEMCall {
+		t.Fatalf("expected %v, got %v", ErrDirectEMCall, err)
+	}
+
+	other := sign(common.HexToAddress("0x1111111111111111111111111111111111111111"), types.QueueOriginSequencer)
+	if err := checkDirectEMCall(&config, common.Big0, other, signer, testExecutionManagerAddress); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deposit := sign(testExecutionManagerAddress, types.QueueOriginL1ToL2)
+	if err := checkDirectEMCall(&config, common.Big0, deposit, signer, testExecutionManagerAddress); err != nil {
+		t.Fatalf("unexpected error for deposit: %v", err)
+	}
+
+	// Transactions in the canonical transaction chain are executed as is
+	indexed := sign(testExecutionManagerAddress, types.QueueOriginSequencer)
+	indexed.SetIndex(0)
+	if err := checkDirectEMCall(&config, common.Big0, indexed, signer, testExecutionManagerAddress); err != nil {
+		t.Fatalf("unexpected error for ctc transaction: %v", err)
+	}
+
+	config.OvmGodAddressBlock = common.Big0
+	config.OvmGodAddress = &from
+	if err := checkDirectEMCall(&config, common.Big0, direct, signer, testExecutionManagerAddress); err != nil {
+		t.Fatalf("unexpected error for god address: %v", err)
+	}
+}
+
+func TestMessageMetadataPreserved(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+
+	tx := types.NewTransaction(0, common.HexToAddress("0x1111111111111111111111111111111111111111"), new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	tx, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, decompressor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err = toExecutionManagerRun(newTestOvmEVM(t), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := msg.(types.Message).Metadata()
+	if metadata[MetadataTxHash] != tx.Hash() {
+		t.Fatalf("expected tx hash %s in metadata, got %v", tx.Hash().Hex(), metadata[MetadataTxHash])
+	}
+}
+
+func TestMessageOriginTxHashPreserved(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+
+	tx := types.NewTransaction(0, common.HexToAddress("0x1111111111111111111111111111111111111111"), new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	tx, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, decompressor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash := msg.(types.Message).OriginTxHash(); hash != tx.Hash() {
+		t.Fatalf("expected origin tx hash %s after asOvmMessage, got %s", tx.Hash().Hex(), hash.Hex())
+	}
+	msg, err = toExecutionManagerRun(newTestOvmEVM(t), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash := msg.(types.Message).OriginTxHash(); hash != tx.Hash() {
+		t.Fatalf("expected origin tx hash %s after wrapping, got %s", tx.Hash().Hex(), hash.Hex())
+	}
+
+	// Messages not created from a transaction have no origin
+	if hash := newTestSequencerMessage(common.Address{}).OriginTxHash(); hash != (common.Hash{}) {
+		t.Fatalf("expected no origin tx hash, got %s", hash.Hex())
+	}
+}
+
+func TestAsOvmMessageEmptyContractCreation(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+
+	tx := types.NewContractCreation(1, new(big.Int), 1000000, big.NewInt(2000000), nil, nil, nil, types.QueueOriginSequencer)
+	tx, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, decompressor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *msg.To() != decompressor {
+		t.Fatalf("expected message to target the decompressor, got %s", msg.To().Hex())
+	}
+
+	// The payload is the fixed size header followed by no calldata, with the
+	// zero address as the target of the contract creation.
+	data := msg.Data()
+	if len(data) != 95 {
+		t.Fatalf("expected 95 byte payload, got %d", len(data))
+	}
+	if data[0] != 0 {
+		t.Fatalf("expected EIP155 signature type, got %d", data[0])
+	}
+	if nonce := new(big.Int).SetBytes(data[72:75]); nonce.Uint64() != 1 {
+		t.Fatalf("expected nonce 1, got %d", nonce)
+	}
+	if target := common.BytesToAddress(data[75:95]); target != ZeroAddress {
+		t.Fatalf("expected zero address target, got %s", target.Hex())
+	}
+}
+
+func TestHashExecutionManagerRun(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	wrap := func(to common.Address) Message {
+		msg, err := toExecutionManagerRun(evm, newTestSequencerMessage(to))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	a := HashExecutionManagerRun(wrap(to), testExecutionManagerAddress)
+	b := HashExecutionManagerRun(wrap(to), testExecutionManagerAddress)
+	if a != b {
+		t.Fatalf("expected equal messages to hash equal, got %s and %s", a.Hex(), b.Hex())
+	}
+
+	other := HashExecutionManagerRun(wrap(common.HexToAddress("0x2222222222222222222222222222222222222222")), testExecutionManagerAddress)
+	if a == other {
+		t.Fatal("expected messages with different entrypoints to hash differently")
+	}
+	if a == HashExecutionManagerRun(wrap(to), testStateManagerAddress) {
+		t.Fatal("expected different execution manager addresses to hash differently")
+	}
+}
+
+func TestTransitionDbTargetReverted(t *testing.T) {
+	vm.UsingOVM = true
+	defer func() { vm.UsingOVM = false }()
+
+	evm := newTestOvmEVM(t)
+	target := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	// The target returns 96 zero bytes, which is the ABI encoding of a
+	// failed call by an EOA contract.
+	evm.StateDB.SetCode(target, common.FromHex("0x60606000f3"))
+
+	// The execution manager calls the target and then stops, so the outer
+	// call always succeeds.
+	code := append(common.FromHex("0x60006000600060006000"), 0x73)
+	code = append(code, target.Bytes()...)
+	code = append(code, 0x5a, 0xf1, 0x00)
+	evm.StateDB.SetCode(testExecutionManagerAddress, code)
+
+	msg := types.NewMessage(common.Address{}, &target, 0, new(big.Int), evm.Context.GasLimit, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	_, _, failed, err := ApplyMessage(evm, msg, new(GasPool).AddGas(evm.Context.GasLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !evm.Context.OriginalTargetReverted {
+		t.Fatal("expected the target revert to be detected")
+	}
+	if !failed {
+		t.Fatal("expected the message to be marked as failed")
+	}
+
+	// A target that returns the ABI encoding of a successful call is not
+	// flagged.
+	evm.StateDB.SetCode(target, common.FromHex("0x600160005260606000f3"))
+	_, _, failed, err = ApplyMessage(evm, msg, new(GasPool).AddGas(evm.Context.GasLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evm.Context.OriginalTargetReverted || failed {
+		t.Fatal("expected the target to succeed")
+	}
+}
+
+func TestSimulateOVMTransaction(t *testing.T) {
+	vm.UsingOVM = true
+	defer func() { vm.UsingOVM = false }()
+
+	evm := newTestOvmEVM(t)
+	target := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	// The execution manager calls the target and then stops
+	code := append(common.FromHex("0x60006000600060006000"), 0x73)
+	code = append(code, target.Bytes()...)
+	code = append(code, 0x5a, 0xf1, 0x00)
+	evm.StateDB.SetCode(testExecutionManagerAddress, code)
+
+	msg := types.NewMessage(common.Address{}, &target, 0, new(big.Int), 1000000, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+
+	// The target returns 96 zero bytes, the encoding of a reverted call
+	evm.StateDB.SetCode(target, common.FromHex("0x60606000f3"))
+	success, used, err := SimulateOVMTransaction(evm, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if success {
+		t.Fatal("expected the reverting call to fail")
+	}
+	if used == 0 || used > msg.Gas() {
+		t.Fatalf("unexpected gas used %d", used)
+	}
+
+	// The target stores a value and returns the encoding of a successful
+	// call. The store must not be committed.
+	evm.StateDB.SetCode(target, common.FromHex("0x6001600055600160005260606000f3"))
+	success, used, err = SimulateOVMTransaction(evm, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success {
+		t.Fatal("expected the call to succeed")
+	}
+	if used == 0 || used > msg.Gas() {
+		t.Fatalf("unexpected gas used %d", used)
+	}
+	if value := evm.StateDB.GetState(target, common.Hash{}); value != (common.Hash{}) {
+		t.Fatalf("expected the simulation to discard state changes, got %x", value)
+	}
+}
+
+func TestWrappingDataCost(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	cost := func(size int) uint64 {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = 0xff
+		}
+		msg := types.NewMessage(common.Address{}, &to, 0, new(big.Int), 21000, new(big.Int), data, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+		c, err := WrappingDataCost(evm, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	empty, one, word, overflow := cost(0), cost(1), cost(32), cost(33)
+	if empty == 0 {
+		t.Fatal("expected wrapping to add calldata cost")
+	}
+	// A single byte of data is padded to a full word of zero bytes.
+	if one-word != 31*params.TxDataZeroGas {
+		t.Fatalf("expected padding to cost %d, got %d", 31*params.TxDataZeroGas, one-word)
+	}
+	// The padding is the same for one byte and one byte past a full word.
+	if one != overflow {
+		t.Fatalf("expected equal cost for 1 and 33 bytes, got %d and %d", one, overflow)
+	}
+	// The data length word gains one nonzero byte.
+	if word-empty != params.TxDataNonZeroGasEIP2028-params.TxDataZeroGas {
+		t.Fatalf("expected length word to cost %d, got %d", params.TxDataNonZeroGasEIP2028-params.TxDataZeroGas, word-empty)
+	}
+}
+
+func TestAsOvmMessageSignatureType(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	tx, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEthSign), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, decompressor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Data()[0] != 2 {
+		t.Fatalf("expected eth_sign signature type, got %d", msg.Data()[0])
+	}
+
+	// An undefined signature hash type would otherwise be encoded with the
+	// signature type byte of another type.
+	mismatched, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SignatureHashType(5)), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := asOvmMessage(params.TestChainConfig, common.Big0, mismatched, signer, decompressor); !errors.Is(err, types.ErrSighashMismatch) {
+		t.Fatalf("expected %v, got %v", types.ErrSighashMismatch, err)
+	}
+
+	// Transactions that are already in the canonical transaction chain are
+	// executed as they are.
+	mismatched.SetIndex(0)
+	if _, err := asOvmMessage(params.TestChainConfig, common.Big0, mismatched, signer, decompressor); err != nil {
+		t.Fatalf("unexpected error for indexed transaction: %v", err)
+	}
+}
+
+func TestNewDepositMessage(t *testing.T) {
+	l1Sender := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	data := []byte{0x01, 0x02}
+
+	msg := types.NewDepositMessage(l1Sender, to, data, 1000000, 7, false)
+	if msg.From() != (common.Address{}) || *msg.To() != to || !bytes.Equal(msg.Data(), data) || msg.Gas() != 1000000 || msg.Nonce() != 7 {
+		t.Fatalf("unexpected message fields: %+v", msg)
+	}
+	if msg.CheckNonce() || msg.Value().Sign() != 0 || msg.GasPrice().Sign() != 0 {
+		t.Fatalf("unexpected message fields: %+v", msg)
+	}
+	if *msg.L1MessageSender() != l1Sender || msg.QueueOrigin().Uint64() != uint64(types.QueueOriginL1ToL2) || msg.SignatureHashType() != types.SighashEIP155 {
+		t.Fatalf("unexpected l1 metadata: %+v", msg)
+	}
+
+	// The message matches what asOvmMessage produces for the same deposit
+	// when it arrives as a transaction.
+	signer := types.NewOVMSigner(big.NewInt(1))
+	tx := types.NewTransaction(7, to, new(big.Int), 1000000, new(big.Int), data, &l1Sender, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	txMsg, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, common.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txMsg.From() != msg.From() || *txMsg.To() != *msg.To() || !bytes.Equal(txMsg.Data(), msg.Data()) || txMsg.Nonce() != msg.Nonce() || *txMsg.L1MessageSender() != *msg.L1MessageSender() || txMsg.QueueOrigin().Cmp(msg.QueueOrigin()) != 0 {
+		t.Fatalf("deposit message mismatch: have %+v, want %+v", msg, txMsg)
+	}
+
+	wrapped, err := toExecutionManagerRun(newTestOvmEVM(t), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *wrapped.To() != testExecutionManagerAddress || wrapped.QueueOrigin().Uint64() != uint64(types.QueueOriginL1ToL2) {
+		t.Fatalf("unexpected wrapped message: %+v", wrapped)
+	}
+}
+
+func TestNewDepositMessageCheckNonce(t *testing.T) {
+	l1Sender := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	evm := newTestOvmEVM(t)
+	evm.StateDB.SetNonce(common.Address{}, 7)
+
+	tests := []struct {
+		nonce      uint64
+		checkNonce bool
+		err        error
+	}{
+		{nonce: 7, checkNonce: true},
+		{nonce: 6, checkNonce: true, err: ErrNonceTooLow},
+		{nonce: 8, checkNonce: true, err: ErrNonceTooHigh},
+		{nonce: 6, checkNonce: false},
+		{nonce: 8, checkNonce: false},
+	}
+	for i, test := range tests {
+		msg := types.NewDepositMessage(l1Sender, to, nil, 1000000, test.nonce, test.checkNonce)
+		if msg.Nonce() != test.nonce || msg.CheckNonce() != test.checkNonce {
+			t.Fatalf("test %d: expected nonce %d and check %v, got %d and %v", i, test.nonce, test.checkNonce, msg.Nonce(), msg.CheckNonce())
+		}
+		st := NewStateTransition(evm, msg, new(GasPool).AddGas(math.MaxUint64))
+		if err := st.preCheck(); err != test.err {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestGetQueueOrigin(t *testing.T) {
+	tests := []struct {
+		queueOrigin *big.Int
+		want        types.QueueOrigin
+		err         error
+	}{
+		{queueOrigin: big.NewInt(0), want: types.QueueOriginSequencer},
+		{queueOrigin: big.NewInt(1), want: types.QueueOriginL1ToL2},
+		{queueOrigin: big.NewInt(3), err: ErrInvalidQueueOrigin},
+		{queueOrigin: nil, err: ErrInvalidQueueOrigin},
+	}
+	for i, test := range tests {
+		have, err := getQueueOrigin(test.queueOrigin)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("test %d: expected error %v, got %v", i, test.err, err)
+		}
+		if err == nil && have != test.want {
+			t.Fatalf("test %d: expected %d, got %d", i, test.want, have)
+		}
+	}
+}
+
+func TestSequencerDecompressorFork(t *testing.T) {
+	original := common.HexToAddress("0x4200000000000000000000000000000000000005")
+	upgraded := common.HexToAddress("0x4200000000000000000000000000000000000015")
+	config := *params.TestChainConfig
+	config.StateDump = &dump.OvmDump{
+		Accounts: map[string]dump.OvmDumpAccount{
+			"OVM_SequencerEntrypoint": {Address: original},
+		},
+	}
+	config.OvmDecompressorForks = []params.OvmDecompressorFork{
+		{Block: big.NewInt(50), Address: original},
+		{Block: big.NewInt(100), Address: upgraded},
+	}
+
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	tx := types.NewTransaction(0, common.HexToAddress("0x1111111111111111111111111111111111111111"), new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	tx, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		number int64
+		want   common.Address
+	}{
+		{1, original},
+		{99, original},
+		{100, upgraded},
+		{101, upgraded},
+	}
+	for _, test := range tests {
+		decompressor := sequencerDecompressor(&config, big.NewInt(test.number))
+		msg, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, decompressor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *msg.To() != test.want {
+			t.Fatalf("block %d: expected decompressor %s, got %s", test.number, test.want.Hex(), msg.To().Hex())
+		}
+	}
+}
+
+func TestGasPriceScalarFork(t *testing.T) {
+	config := *params.TestChainConfig
+	config.OvmDecompressorForks = []params.OvmDecompressorFork{
+		{Block: big.NewInt(10), GasPriceScalar: 1000},
+	}
+
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	tx := types.NewTransaction(0, common.HexToAddress("0x1111111111111111111111111111111111111111"), new(big.Int), 21000, big.NewInt(5000000), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	tx, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		number int64
+		want   uint64
+	}{
+		{9, 5},
+		{10, 5000},
+	}
+	for _, test := range tests {
+		msg, err := asOvmMessage(&config, big.NewInt(test.number), tx, signer, common.Address{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The gas price follows the sighash type, signature and gas limit
+		if have := new(big.Int).SetBytes(msg.Data()[69:72]).Uint64(); have != test.want {
+			t.Fatalf("block %d: expected encoded gas price %d, got %d", test.number, test.want, have)
+		}
+	}
+}
+
+func TestToExecutionManagerRunCreationGas(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	evm.ChainConfig().OvmCreationGasBlock = big.NewInt(1)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	creationCost := params.TxGasContractCreation - params.TxGas
+
+	tests := []struct {
+		number     int64
+		to         *common.Address
+		gas        uint64
+		entrypoint common.Address
+		gasLimit   uint64
+		err        error
+	}{
+		{number: 1, to: &to, gas: 100000, entrypoint: to, gasLimit: 100000},
+		{number: 1, to: nil, gas: 100000, gasLimit: 100000 - creationCost},
+		{number: 1, to: nil, gas: creationCost, err: ErrZeroGasLimit},
+		{number: 1, to: nil, gas: creationCost - 1, err: ErrIntrinsicGas},
+		// Before the fork the creation gas is not deducted
+		{number: 0, to: nil, gas: 100000, gasLimit: 100000},
+		{number: 0, to: nil, gas: creationCost - 1, gasLimit: creationCost - 1},
+	}
+	for i, test := range tests {
+		evm.Context.BlockNumber = big.NewInt(test.number)
+		msg := types.NewMessage(common.Address{}, test.to, 0, new(big.Int), test.gas, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+		wrapped, err := toExecutionManagerRun(evm, msg)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("test %d: expected error %v, got %v", i, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if wrapped.Gas() != evm.Context.GasLimit {
+			t.Fatalf("test %d: expected wrapped gas %d, got %d", i, evm.Context.GasLimit, wrapped.Gas())
+		}
+		args, err := evm.Context.OvmExecutionManager.ABI.Methods["run"].Inputs.UnpackValues(wrapped.Data()[4:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		run := reflect.ValueOf(args[0])
+		if gasLimit := run.FieldByName("GasLimit").Interface().(*big.Int); gasLimit.Uint64() != test.gasLimit {
+			t.Fatalf("test %d: expected run gas limit %d, got %d", i, test.gasLimit, gasLimit)
+		}
+		if entrypoint := run.FieldByName("Entrypoint").Interface().(common.Address); entrypoint != test.entrypoint {
+			t.Fatalf("test %d: expected entrypoint %s, got %s", i, test.entrypoint.Hex(), entrypoint.Hex())
+		}
+	}
+}
+
+func TestAsOvmMessageGodSelfCall(t *testing.T) {
+	config := *params.TestChainConfig
+	key, _ := crypto.GenerateKey()
+	god := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.NewOVMSigner(big.NewInt(1))
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+
+	tx := types.NewTransaction(0, god, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	tx, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a god address the transaction is an ordinary self transfer
+	if _, err := asOvmMessage(&config, common.Big0, tx, signer, decompressor); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config.OvmGodAddressBlock = common.Big0
+	config.OvmGodAddress = &god
+	if _, err := asOvmMessage(&config, common.Big0, tx, signer, decompressor); !errors.Is(err, ErrGodSelfCall) {
+		t.Fatalf("expected %v, got %v", ErrGodSelfCall, err)
+	}
+
+	config.OvmAllowGodSelfCall = true
+	if _, err := asOvmMessage(&config, common.Big0, tx, signer, decompressor); err != nil {
+		t.Fatalf("unexpected error with self calls allowed: %v", err)
+	}
+}
+
+func TestToExecutionManagerRunDepositBlockNumber(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	evm.ChainConfig().OvmL1BlockNumberBlock = big.NewInt(1)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	tests := []struct {
+		number        int64
+		queueOrigin   types.QueueOrigin
+		l1BlockNumber *big.Int
+		want          *big.Int
+	}{
+		{number: 1, queueOrigin: types.QueueOriginL1ToL2, l1BlockNumber: big.NewInt(1234), want: big.NewInt(1234)},
+		{number: 1, queueOrigin: types.QueueOriginL1ToL2, l1BlockNumber: nil, want: big.NewInt(1)},
+		{number: 1, queueOrigin: types.QueueOriginSequencer, l1BlockNumber: big.NewInt(1234), want: big.NewInt(1)},
+		// Before the fork the block number of the context is used
+		{number: 0, queueOrigin: types.QueueOriginL1ToL2, l1BlockNumber: big.NewInt(1234), want: big.NewInt(0)},
+	}
+	for i, test := range tests {
+		evm.Context.BlockNumber = big.NewInt(test.number)
+		msg := types.NewMessage(common.Address{}, &to, 0, new(big.Int), 100000, new(big.Int), nil, false, &l1Sender, test.l1BlockNumber, test.queueOrigin, types.SighashEIP155)
+		wrapped, err := toExecutionManagerRun(evm, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args, err := evm.Context.OvmExecutionManager.ABI.Methods["run"].Inputs.UnpackValues(wrapped.Data()[4:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		blockNumber := reflect.ValueOf(args[0]).FieldByName("BlockNumber").Interface().(*big.Int)
+		if blockNumber.Cmp(test.want) != 0 {
+			t.Fatalf("test %d: expected block number %d, got %d", i, test.want, blockNumber)
+		}
+	}
+}
+
+func TestDecompressorGasOverhead(t *testing.T) {
+	// The fixed cost of recovering the signer, calling the target and hashing
+	base := params.EcrecoverGas + params.CallGasEIP150 + params.Sha3Gas
+	// Each word is copied, hashed and expanded into memory
+	word := params.CopyGas + params.Sha3WordGas + params.MemoryGas
+
+	tests := []struct {
+		size int
+		want uint64
+	}{
+		{size: 0, want: base},
+		{size: 1, want: base + word},
+		{size: 32, want: base + word},
+		{size: 33, want: base + 2*word},
+		{size: 95, want: base + 3*word},
+		// The quadratic memory cost kicks in for large payloads
+		{size: 32 * 1024, want: base + 1024*word + 1024*1024/params.QuadCoeffDiv},
+	}
+	for _, test := range tests {
+		if got := DecompressorGasOverhead(make([]byte, test.size)); got != test.want {
+			t.Errorf("size %d: expected overhead %d, got %d", test.size, test.want, got)
+		}
+	}
+}
+
+func TestAsOvmMessageZeroTarget(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+
+	for _, sighashType := range []types.SignatureHashType{types.SighashEIP155, types.SighashEthSign} {
+		// An explicit zero address recipient is rejected
+		tx := types.NewTransaction(0, common.Address{}, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, sighashType)
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, decompressor); err != ErrZeroTarget {
+			t.Fatalf("%s: expected %v, got %v", sighashType, ErrZeroTarget, err)
+		}
+		// unless it is already in the canonical transaction chain
+		tx.SetIndex(0)
+		if _, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, decompressor); err != nil {
+			t.Fatalf("%s: unexpected error for ctc transaction: %v", sighashType, err)
+		}
+
+		// A creation without a recipient is encoded with the zero target
+		creation := types.NewContractCreation(0, new(big.Int), 100000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer)
+		creation.SetSignatureHashType(sighashType)
+		creation, err = types.SignTx(creation, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := asOvmMessage(params.TestChainConfig, common.Big0, creation, signer, decompressor)
+		if err != nil {
+			t.Fatalf("%s: unexpected error for creation: %v", sighashType, err)
+		}
+		if target := common.BytesToAddress(msg.Data()[75:95]); target != (common.Address{}) {
+			t.Fatalf("%s: expected zero target for creation, got %s", sighashType, target.Hex())
+		}
+	}
+}
+
+func TestCheckZeroTarget(t *testing.T) {
+	config := *params.TestChainConfig
+	userKey, _ := crypto.GenerateKey()
+	godKey, _ := crypto.GenerateKey()
+	god := crypto.PubkeyToAddress(godKey.PublicKey)
+	config.OvmGodAddressBlock = common.Big0
+	config.OvmGodAddress = &god
+
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	sign := func(key *ecdsa.PrivateKey, tx *types.Transaction) *types.Transaction {
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	call := func(to common.Address) *types.Transaction {
+		return types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	}
+	creation := types.NewContractCreation(0, new(big.Int), 100000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer)
+	deposit := types.NewTransaction(0, common.Address{}, new(big.Int), 21000, new(big.Int), nil, &to, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+
+	tests := []struct {
+		name string
+		tx   *types.Transaction
+		err  error
+	}{
+		{"zero recipient", sign(userKey, call(common.Address{})), ErrZeroTarget},
+		{"creation", sign(userKey, creation), nil},
+		{"recipient", sign(userKey, call(to)), nil},
+		{"god zero recipient", sign(godKey, call(common.Address{})), nil},
+		{"deposit", deposit, nil},
+	}
+	for _, test := range tests {
+		if err := checkZeroTarget(&config, common.Big0, test.tx, signer); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestWouldWrap(t *testing.T) {
+	config := *params.TestChainConfig
+	godKey, _ := crypto.GenerateKey()
+	god := crypto.PubkeyToAddress(godKey.PublicKey)
+	userKey, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	sign := func(key *ecdsa.PrivateKey) *types.Transaction {
+		tx := types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	godTx, userTx := sign(godKey), sign(userKey)
+
+	config.OvmGodAddressBlock = common.Big0
+	config.OvmGodAddress = &god
+
+	tests := []struct {
+		tx   *types.Transaction
+		wrap bool
+	}{
+		{tx: godTx, wrap: false},
+		{tx: userTx, wrap: true},
+	}
+	for i, test := range tests {
+		wrap, err := WouldWrap(&config, common.Big0, test.tx, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wrap != test.wrap {
+			t.Fatalf("test %d: expected wrap %v, got %v", i, test.wrap, wrap)
+		}
+		// The result agrees with what asOvmMessage does
+		msg, err := asOvmMessage(&config, common.Big0, test.tx, signer, decompressor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wrapped := *msg.To() == decompressor; wrapped != test.wrap {
+			t.Fatalf("test %d: expected asOvmMessage to wrap %v, got %v", i, test.wrap, wrapped)
+		}
+	}
+
+	unsigned := types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	if _, err := WouldWrap(&config, common.Big0, unsigned, signer); err == nil {
+		t.Fatal("expected error for an unsigned transaction")
+	}
+
+	// The god address is wrapped like any sender before its fork block
+	config.OvmGodAddressBlock = big.NewInt(10)
+	if wrap, err := WouldWrap(&config, big.NewInt(9), godTx, signer); err != nil || !wrap {
+		t.Fatalf("expected the god address to be wrapped before the fork block, got %v, %v", wrap, err)
+	}
+	if wrap, err := WouldWrap(&config, big.NewInt(10), godTx, signer); err != nil || wrap {
+		t.Fatalf("expected the god address not to be wrapped from the fork block, got %v, %v", wrap, err)
+	}
+}
+
+func TestAssertWrappingInvariant(t *testing.T) {
+	config := *params.TestChainConfig
+	godKey, _ := crypto.GenerateKey()
+	god := crypto.PubkeyToAddress(godKey.PublicKey)
+	userKey, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+
+	sign := func(key *ecdsa.PrivateKey) *types.Transaction {
+		tx := types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	godTx, userTx := sign(godKey), sign(userKey)
+	deposit := types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), []byte{1, 2, 3}, &to, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+
+	config.OvmGodAddressBlock = common.Big0
+	config.OvmGodAddress = &god
+
+	for i, tx := range []*types.Transaction{godTx, userTx, deposit} {
+		if err := AssertWrappingInvariant(&config, common.Big0, tx, signer, decompressor); err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+	}
+
+	// A user transaction that is passed through, as it would be if the god
+	// address check matched every sender, and a god transaction that is
+	// wrapped, as it would be if the check matched none.
+	passed, err := userTx.AsMessage(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWrappingInvariant(&config, common.Big0, userTx, signer, passed, decompressor); !errors.Is(err, ErrWrappingInvariant) {
+		t.Fatalf("expected %v, got %v", ErrWrappingInvariant, err)
+	}
+	config.OvmGodAddress = nil
+	wrapped, err := asOvmMessage(&config, common.Big0, godTx, signer, decompressor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.OvmGodAddress = &god
+	if err := checkWrappingInvariant(&config, common.Big0, godTx, signer, wrapped, decompressor); !errors.Is(err, ErrWrappingInvariant) {
+		t.Fatalf("expected %v, got %v", ErrWrappingInvariant, err)
+	}
+}
+
+func TestToExecutionManagerRunZeroGasLimit(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	tests := []struct {
+		gas         uint64
+		queueOrigin types.QueueOrigin
+		err         error
+	}{
+		{gas: 0, queueOrigin: types.QueueOriginSequencer, err: ErrZeroGasLimit},
+		{gas: 1, queueOrigin: types.QueueOriginSequencer},
+		// Deposits are executed regardless of their gas limit
+		{gas: 0, queueOrigin: types.QueueOriginL1ToL2},
+	}
+	for i, test := range tests {
+		msg := types.NewMessage(common.Address{}, &to, 0, new(big.Int), test.gas, new(big.Int), nil, false, &common.Address{}, nil, test.queueOrigin, types.SighashEIP155)
+		if _, err := toExecutionManagerRun(evm, msg); err != test.err {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestExecutionManagerRunCalldata(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	for i, msg := range []Message{
+		newTestSequencerMessage(to),
+		types.NewMessage(common.Address{}, nil, 0, new(big.Int), 100000, new(big.Int), []byte{1, 2, 3}, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155),
+		types.NewMessage(common.Address{}, &to, 0, new(big.Int), 100000, new(big.Int), nil, false, &to, big.NewInt(1234), types.QueueOriginL1ToL2, types.SighashEIP155),
+	} {
+		calldata, err := ExecutionManagerRunCalldata(evm, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrapped, err := toExecutionManagerRun(evm, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(calldata, wrapped.Data()) {
+			t.Fatalf("test %d: calldata mismatch, want %x, got %x", i, wrapped.Data(), calldata)
+		}
+	}
+
+	msg := types.NewMessage(common.Address{}, &to, 0, new(big.Int), 0, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	if _, err := ExecutionManagerRunCalldata(evm, msg); err != ErrZeroGasLimit {
+		t.Fatalf("expected %v, got %v", ErrZeroGasLimit, err)
+	}
+}
+
+func TestCanAffordWrapping(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	price, value := big.NewInt(1000000000), big.NewInt(1000000000000000000)
+
+	tx, err := types.SignTx(types.NewTransaction(0, to, value, 100000, price, []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intrinsic, err := IntrinsicGas(tx.Data(), false, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, common.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapping, err := WrappingDataCost(evm, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gas := intrinsic + wrapping + DecompressorGasOverhead(msg.Data())
+	need := new(big.Int).Add(new(big.Int).Mul(new(big.Int).SetUint64(gas), price), value)
+
+	tests := []struct {
+		balance *big.Int
+		afford  bool
+	}{
+		{balance: need, afford: true},
+		{balance: new(big.Int).Add(need, common.Big1), afford: true},
+		{balance: new(big.Int).Sub(need, common.Big1), afford: false},
+		// Covering the intrinsic gas alone is not enough
+		{balance: new(big.Int).Add(new(big.Int).Mul(new(big.Int).SetUint64(intrinsic), price), value), afford: false},
+	}
+	for i, test := range tests {
+		afford, err := CanAffordWrapping(evm, tx, signer, test.balance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if afford != test.afford {
+			t.Fatalf("test %d: expected afford %v with balance %d of %d, got %v", i, test.afford, test.balance, need, afford)
+		}
+	}
+
+	// Deposits are not wrapped and only pay their intrinsic gas
+	deposit := types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), nil, &to, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	if afford, err := CanAffordWrapping(evm, deposit, signer, new(big.Int)); err != nil || !afford {
+		t.Fatalf("expected a free deposit to be affordable, got %v, %v", afford, err)
+	}
+}
+
+func TestAllInCostEstimate(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	price, value, baseFee := big.NewInt(1000000000), big.NewInt(1000000000000000000), big.NewInt(100)
+
+	tx, err := types.SignTx(types.NewTransaction(0, to, value, 100000, price, []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, common.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapping, err := WrappingDataCost(evm, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gas := tx.Gas() + wrapping + DecompressorGasOverhead(msg.Data())
+	want := new(big.Int).Mul(new(big.Int).SetUint64(gas), price)
+	want.Add(want, value)
+	want.Add(want, tx.L1DataFee(baseFee))
+
+	have, err := AllInCostEstimate(evm, tx, signer, baseFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.Cmp(want) != 0 {
+		t.Fatalf("sequencer transaction cost mismatch: have %d, want %d", have, want)
+	}
+
+	// Deposits are not wrapped and pay no L1 data fee
+	deposit := types.NewTransaction(0, to, value, 100000, price, nil, &to, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	want = new(big.Int).Mul(new(big.Int).SetUint64(deposit.Gas()), price)
+	want.Add(want, value)
+	have, err = AllInCostEstimate(evm, deposit, signer, baseFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.Cmp(want) != 0 {
+		t.Fatalf("deposit cost mismatch: have %d, want %d", have, want)
+	}
+}
+
+func TestFeeBreakdown(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	price, value, baseFee := big.NewInt(1000000000), big.NewInt(1000000000000000000), big.NewInt(100)
+
+	tx, err := types.SignTx(types.NewTransaction(0, to, value, 100000, price, []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit := types.NewTransaction(0, to, value, 100000, price, nil, &to, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+
+	for i, tx := range []*types.Transaction{tx, deposit} {
+		l2Fee, l1Fee, overheadFee, err := FeeBreakdown(evm, tx, signer, baseFee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), price); l2Fee.Cmp(want) != 0 {
+			t.Fatalf("test %d: expected l2 fee %d, got %d", i, want, l2Fee)
+		}
+		total, err := AllInCostEstimate(evm, tx, signer, baseFee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := new(big.Int).Add(l2Fee, l1Fee)
+		sum.Add(sum, overheadFee)
+		sum.Add(sum, tx.Value())
+		if sum.Cmp(total) != 0 {
+			t.Fatalf("test %d: components sum to %d, expected %d", i, sum, total)
+		}
+	}
+
+	// Deposits are not wrapped and pay no L1 data fee
+	_, l1Fee, overheadFee, err := FeeBreakdown(evm, deposit, signer, baseFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l1Fee.Sign() != 0 || overheadFee.Sign() != 0 {
+		t.Fatalf("expected no l1 or overhead fee for a deposit, got %d and %d", l1Fee, overheadFee)
+	}
+	_, l1Fee, overheadFee, err = FeeBreakdown(evm, tx, signer, baseFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l1Fee.Sign() == 0 || overheadFee.Sign() == 0 {
+		t.Fatalf("expected l1 and overhead fees for a sequencer transaction, got %d and %d", l1Fee, overheadFee)
+	}
+}
+
+func TestValidateIntrinsicGas(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	// A fixed signature keeps the zero bytes of the wrapped calldata the same
+	// for every gas limit.
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	sig, err := crypto.Sign(crypto.Keccak256([]byte("fixed")), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTx := func(gas uint64) *types.Transaction {
+		tx, err := types.NewTransaction(0, to, new(big.Int), gas, big.NewInt(1000000), []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, types.SighashEIP155).WithSignature(signer, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	// The wrapped calldata encodes the gas limit, so the threshold is found
+	// for a gas limit with the same number of non zero bytes.
+	required, err := ovmIntrinsicGas(evm, newTx(30000), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := IntrinsicGas([]byte{1, 2, 3}, false, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if required <= raw {
+		t.Fatalf("expected the wrapping to add intrinsic gas, got %d for %d", required, raw)
+	}
+	for _, gas := range []uint64{required - 1, required, required + 1} {
+		if have, _ := ovmIntrinsicGas(evm, newTx(gas), signer); have != required {
+			t.Fatalf("intrinsic gas changed with gas limit %d: have %d, want %d", gas, have, required)
+		}
+	}
+
+	if err := ValidateIntrinsicGas(evm, newTx(required-1), signer); !errors.Is(err, ErrIntrinsicGas) {
+		t.Fatalf("expected %v just below the threshold, got %v", ErrIntrinsicGas, err)
+	}
+	if err := ValidateIntrinsicGas(evm, newTx(required), signer); err != nil {
+		t.Fatalf("expected the threshold to be accepted, got %v", err)
+	}
+	if err := ValidateIntrinsicGas(evm, newTx(required+1), signer); err != nil {
+		t.Fatalf("expected just above the threshold to be accepted, got %v", err)
+	}
+}
+
+func TestCheckExecutionManagerABI(t *testing.T) {
+	valid, err := abi.JSON(strings.NewReader(testExecutionManagerABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckExecutionManagerABI(valid); err != nil {
+		t.Fatalf("expected the test abi to be valid, got %v", err)
+	}
+
+	missing, err := abi.JSON(strings.NewReader(`[{"type": "function", "name": "ovmCALL", "inputs": []}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := abi.JSON(strings.NewReader(`[{"type": "function", "name": "run", "inputs": [{"name": "_data", "type": "bytes"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, emABI := range []abi.ABI{missing, wrong} {
+		if err := CheckExecutionManagerABI(emABI); !errors.Is(err, ErrInvalidRunABI) {
+			t.Fatalf("test %d: expected %v, got %v", i, ErrInvalidRunABI, err)
+		}
+	}
+
+	// The cached check agrees with the check for every copy of an ABI
+	for i := 0; i < 2; i++ {
+		if err := checkExecutionManagerABIOnce(valid); err != nil {
+			t.Fatalf("call %d: expected the test abi to be valid, got %v", i, err)
+		}
+		if err := checkExecutionManagerABIOnce(missing); !errors.Is(err, ErrInvalidRunABI) {
+			t.Fatalf("call %d: expected %v, got %v", i, ErrInvalidRunABI, err)
+		}
+	}
+
+	// The check runs before the calldata is packed
+	evm := newTestOvmEVM(t)
+	evm.Context.OvmExecutionManager.ABI = missing
+	msg := newTestSequencerMessage(common.HexToAddress("0x1111111111111111111111111111111111111111"))
+	if _, err := toExecutionManagerRun(evm, msg); !errors.Is(err, ErrInvalidRunABI) {
+		t.Fatalf("expected %v, got %v", ErrInvalidRunABI, err)
+	}
+}
+
+func TestDryRunAsOvmMessage(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	sign := func(nonce, gas uint64, gasPrice *big.Int) *types.Transaction {
+		tx := types.NewTransaction(nonce, to, new(big.Int), gas, gasPrice, nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	withMeta := func(tx *types.Transaction, modify func(meta *types.TransactionMeta)) *types.Transaction {
+		meta := *tx.GetMeta()
+		modify(&meta)
+		tx.SetTransactionMeta(&meta)
+		return tx
+	}
+
+	tests := []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		{tx: sign(0, 21000, big.NewInt(1000000))},
+		{tx: sign(0, 1<<24, big.NewInt(1000000)), err: ErrCompressedFieldOverflow},
+		{tx: sign(1<<24, 21000, big.NewInt(1000000)), err: ErrCompressedFieldOverflow},
+		{tx: sign(0, 21000, new(big.Int).Mul(big.NewInt(1<<24), big.NewInt(types.GasPriceScalar))), err: types.ErrGasPriceOutOfRange},
+		{tx: withMeta(sign(0, 21000, big.NewInt(1000000)), func(meta *types.TransactionMeta) {
+			meta.SignatureHashType = types.SignatureHashType(7)
+		}), err: types.ErrSighashMismatch},
+		{tx: withMeta(sign(0, 21000, big.NewInt(1000000)), func(meta *types.TransactionMeta) {
+			meta.QueueOrigin = big.NewInt(5)
+		}), err: ErrInvalidQueueOrigin},
+	}
+	for i, test := range tests {
+		err := DryRunAsOvmMessage(params.TestChainConfig, common.Big0, test.tx, signer)
+		if test.err == nil && err != nil {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// fixedSenderSigner is an OVMSigner that skips signature recovery, so that
+// the checks of asOvmMessage can be reached with invalid signatures.
+type fixedSenderSigner struct {
+	types.OVMSigner
+	from common.Address
+}
+
+func (s fixedSenderSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	return s.from, nil
+}
+
+func (s fixedSenderSigner) Equal(s2 types.Signer) bool {
+	other, ok := s2.(fixedSenderSigner)
+	return ok && other.from == s.from
+}
+
+func TestAsOvmMessageZeroSignatureValues(t *testing.T) {
+	chainID := big.NewInt(1)
+	signer := fixedSenderSigner{OVMSigner: types.NewOVMSigner(chainID), from: common.HexToAddress("0x2222222222222222222222222222222222222222")}
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	for i, sig := range [][]byte{
+		append(make([]byte, 32), append(bytes.Repeat([]byte{1}, 32), 0)...),
+		append(bytes.Repeat([]byte{1}, 32), append(make([]byte, 32), 0)...),
+	} {
+		tx := types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+		tx, err := tx.WithSignature(signer, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, common.Address{}); !errors.Is(err, types.ErrInvalidSig) {
+			t.Fatalf("test %d: expected %v, got %v", i, types.ErrInvalidSig, err)
+		}
+	}
+}
+
+// The compressed encoding carries no chain id, only the recovery id of the
+// signature, so a transaction signed for another chain must be rejected
+// before it is encoded.
+func TestAsOvmMessageChainIDMismatch(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), types.NewOVMSigner(big.NewInt(2)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, types.NewOVMSigner(big.NewInt(1)), common.Address{}); !errors.Is(err, types.ErrInvalidChainId) {
+		t.Fatalf("expected %v, got %v", types.ErrInvalidChainId, err)
+	}
+	if _, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, types.NewOVMSigner(big.NewInt(2)), common.Address{}); err != nil {
+		t.Fatalf("unexpected error for the signing chain: %v", err)
+	}
+}
+
+func TestEffectiveRecipient(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	call := types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	creation := types.NewContractCreation(0, new(big.Int), 100000, new(big.Int), []byte{1}, nil, nil, types.QueueOriginSequencer)
+	tests := []struct {
+		tx   *types.Transaction
+		want *common.Address
+	}{
+		{tx: call, want: &to},
+		{tx: creation, want: nil},
+	}
+	for i, test := range tests {
+		tx, err := types.SignTx(test.tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := EffectiveRecipient(tx)
+		if (have == nil) != (test.want == nil) || (have != nil && *have != *test.want) {
+			t.Fatalf("test %d: expected recipient %v, got %v", i, test.want, have)
+		}
+
+		// The wrapped message is sent to the execution manager, while the
+		// entrypoint of the run is the effective recipient.
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrapped, err := toExecutionManagerRun(evm, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *wrapped.To() != testExecutionManagerAddress {
+			t.Fatalf("test %d: expected the wrapped message to target the execution manager, got %x", i, *wrapped.To())
+		}
+		args, err := evm.Context.OvmExecutionManager.ABI.Methods["run"].Inputs.UnpackValues(wrapped.Data()[4:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		entrypoint := reflect.ValueOf(args[0]).FieldByName("Entrypoint").Interface().(common.Address)
+		if have == nil && entrypoint != (common.Address{}) || have != nil && entrypoint != *have {
+			t.Fatalf("test %d: entrypoint %x does not match the effective recipient %v", i, entrypoint, have)
+		}
+	}
+}
+
+func TestNewGodMessage(t *testing.T) {
+	vm.UsingOVM = true
+	defer func() { vm.UsingOVM = false }()
+
+	evm := newTestOvmEVM(t)
+	target := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	if _, err := NewGodMessage(evm.ChainConfig(), evm.BlockNumber, target, nil, 100000); err != ErrNoGodAddress {
+		t.Fatalf("expected %v, got %v", ErrNoGodAddress, err)
+	}
+
+	god := common.HexToAddress("0x4200000000000000000000000000000000000042")
+	evm.ChainConfig().OvmGodAddressBlock = common.Big0
+	evm.ChainConfig().OvmGodAddress = &god
+	// The execution manager always fails, so the message only succeeds if
+	// it is not wrapped.
+	evm.StateDB.SetCode(target, []byte{0x00})
+	evm.StateDB.SetCode(testExecutionManagerAddress, []byte{0xfe})
+
+	msg, err := NewGodMessage(evm.ChainConfig(), evm.BlockNumber, target, nil, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.From() != god || *msg.To() != target || msg.CheckNonce() {
+		t.Fatalf("unexpected god message: %+v", msg)
+	}
+	_, _, failed, err := ApplyMessage(evm, msg, new(GasPool).AddGas(evm.Context.GasLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed {
+		t.Fatal("expected the god message to be executed without wrapping")
+	}
+
+	// A message from another sender is wrapped and fails
+	other := types.NewMessage(common.Address{}, &target, 0, new(big.Int), 100000, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	if _, _, failed, err = ApplyMessage(evm, other, new(GasPool).AddGas(evm.Context.GasLimit)); err != nil {
+		t.Fatal(err)
+	}
+	if !failed {
+		t.Fatal("expected the wrapped message to fail in the execution manager")
+	}
+}
+
+func TestOvmDirectCall(t *testing.T) {
+	vm.UsingOVM = true
+	defer func() { vm.UsingOVM = false }()
+
+	evm := newTestOvmEVM(t)
+	target := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	evm.StateDB.SetCode(target, []byte{0x00})
+
+	// A root call that bypasses the execution manager is not executed as a
+	// plain call unless it is marked as direct
+	if _, _, err := evm.Call(vm.AccountRef(common.Address{}), target, nil, 100000, new(big.Int)); err != vm.ErrOvmExecutionFailed {
+		t.Fatalf("expected %v, got %v", vm.ErrOvmExecutionFailed, err)
+	}
+	evm.Context.OvmDirectCall = true
+	if _, _, err := evm.Call(vm.AccountRef(common.Address{}), target, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("unexpected error for a direct call: %v", err)
+	}
+}
+
+func TestIsDecompressorMessage(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	god := common.HexToAddress("0x4200000000000000000000000000000000000042")
+	evm.ChainConfig().OvmGodAddressBlock = common.Big0
+	evm.ChainConfig().OvmGodAddress = &god
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+	evm.ChainConfig().StateDump.Accounts["OVM_SequencerEntrypoint"] = dump.OvmDumpAccount{Address: decompressor}
+
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	target := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := types.SignTx(types.NewTransaction(0, target, new(big.Int), 100000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := asOvmMessage(evm.ChainConfig(), evm.BlockNumber, tx, signer, decompressor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsDecompressorMessage(evm.ChainConfig(), evm.BlockNumber, wrapped) {
+		t.Fatal("expected a wrapped sequencer message to be sent to the decompressor")
+	}
+
+	msg, err := NewGodMessage(evm.ChainConfig(), evm.BlockNumber, target, nil, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsDecompressorMessage(evm.ChainConfig(), evm.BlockNumber, msg) {
+		t.Fatal("expected a god message not to be sent to the decompressor")
+	}
+}
+
+func TestDepositGasFloor(t *testing.T) {
+	const floor = 200000
+	vm.UsingOVM = true
+	defer func() { vm.UsingOVM = false }()
+
+	target := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	tests := []struct {
+		msg  Message
+		used uint64
+	}{
+		// A deposit below the floor is bumped to it
+		{msg: types.NewDepositMessage(l1Sender, target, nil, 30000, 0, false), used: floor},
+		// A deposit above the floor keeps its gas limit
+		{msg: types.NewDepositMessage(l1Sender, target, nil, 300000, 0, false), used: 300000},
+		// Sequencer transactions are unaffected
+		{msg: types.NewMessage(common.Address{}, &target, 0, new(big.Int), 100000, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155), used: 100000},
+	}
+	for i, test := range tests {
+		evm := newTestOvmEVM(t)
+		evm.ChainConfig().OvmDepositGasFloorBlock = big.NewInt(0)
+		evm.ChainConfig().OvmDepositGasFloor = floor
+		// The execution manager consumes all of the gas it is given
+		evm.StateDB.SetCode(testExecutionManagerAddress, []byte{0xfe})
+		_, used, _, err := ApplyMessage(evm, test.msg, new(GasPool).AddGas(evm.Context.GasLimit))
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if used != test.used {
+			t.Fatalf("test %d: expected %d gas used, got %d", i, test.used, used)
+		}
+	}
+
+	// Before the activation block deposits keep their gas limit
+	evm := newTestOvmEVM(t)
+	evm.ChainConfig().OvmDepositGasFloorBlock = big.NewInt(2)
+	evm.ChainConfig().OvmDepositGasFloor = floor
+	evm.StateDB.SetCode(testExecutionManagerAddress, []byte{0xfe})
+	_, used, _, err := ApplyMessage(evm, types.NewDepositMessage(l1Sender, target, nil, 30000, 0, false), new(GasPool).AddGas(evm.Context.GasLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 30000 {
+		t.Fatalf("expected %d gas used before the activation block, got %d", 30000, used)
+	}
+}
+
+func TestRevertReasonThroughWrapping(t *testing.T) {
+	vm.UsingOVM = true
+	defer func() { vm.UsingOVM = false }()
+
+	// The revert data of require(false, "insufficient balance")
+	stringType, _ := abi.NewType("string", "", nil)
+	packed, err := (abi.Arguments{{Type: stringType}}).Pack("insufficient balance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revert := append(append([]byte{}, revertSelector...), packed...)
+
+	// The target returns the abi encoding of (false, revert), which is how
+	// the account contracts report a reverted inner call.
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	boolType, _ := abi.NewType("bool", "", nil)
+	result, err := (abi.Arguments{{Type: boolType}, {Type: bytesType}}).Pack(false, revert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// CODECOPY the result that follows the 12 byte prologue and RETURN it
+	size := byte(len(result))
+	code := append([]byte{
+		0x60, size, 0x60, 0x0c, 0x60, 0x00, 0x39, // CODECOPY(0, 12, size)
+		0x60, size, 0x60, 0x00, 0xf3, // RETURN(0, size)
+	}, result...)
+
+	evm := newTestOvmEVM(t)
+	target := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	evm.StateDB.SetCode(target, code)
+	// The execution manager calls the target and stops
+	evm.StateDB.SetCode(testExecutionManagerAddress, append(append([]byte{
+		0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, // out, outsize, in, insize, value
+		0x73}, target.Bytes()...), 0x5a, 0xf1, 0x00)) // PUSH20 target, GAS, CALL, STOP
+
+	msg := types.NewMessage(common.Address{}, &target, 0, new(big.Int), 1000000, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	ret, _, failed, err := ApplyMessage(evm, msg, new(GasPool).AddGas(evm.Context.GasLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !failed {
+		t.Fatal("expected the inner revert to fail the message")
+	}
+	reason, ok := RevertReason(ret)
+	if !ok {
+		t.Fatalf("expected a revert reason in %x", ret)
+	}
+	if reason != "insufficient balance" {
+		t.Fatalf("expected reason %q, got %q", "insufficient balance", reason)
+	}
+
+	if _, ok := RevertReason([]byte{0xde, 0xad, 0xbe, 0xef}); ok {
+		t.Fatal("expected no reason for other return data")
+	}
+}
+
+func TestValidateEntrypointAllowed(t *testing.T) {
+	config := *params.TestChainConfig
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	allowed := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	disallowed := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	allow := map[common.Address]bool{allowed: true}
+	newTx := func(key *ecdsa.PrivateKey, to common.Address) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	if err := ValidateEntrypointAllowed(&config, common.Big0, newTx(key, allowed), signer, allow); err != nil {
+		t.Fatalf("expected an allowed entrypoint to be accepted, got %v", err)
+	}
+	if err := ValidateEntrypointAllowed(&config, common.Big0, newTx(key, disallowed), signer, allow); !errors.Is(err, ErrEntrypointNotAllowed) {
+		t.Fatalf("expected %v, got %v", ErrEntrypointNotAllowed, err)
+	}
+	creation, err := types.SignTx(types.NewContractCreation(0, new(big.Int), 100000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateEntrypointAllowed(&config, common.Big0, creation, signer, allow); !errors.Is(err, ErrEntrypointNotAllowed) {
+		t.Fatalf("expected %v for a contract creation, got %v", ErrEntrypointNotAllowed, err)
+	}
+	deposit := types.NewTransaction(0, disallowed, new(big.Int), 21000, new(big.Int), nil, &disallowed, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	if err := ValidateEntrypointAllowed(&config, common.Big0, deposit, signer, allow); err != nil {
+		t.Fatalf("expected a deposit to be accepted, got %v", err)
+	}
+
+	// The god address is exempt
+	godKey, _ := crypto.GenerateKey()
+	god := crypto.PubkeyToAddress(godKey.PublicKey)
+	config.OvmGodAddressBlock = common.Big0
+	config.OvmGodAddress = &god
+	if err := ValidateEntrypointAllowed(&config, common.Big0, newTx(godKey, disallowed), signer, allow); err != nil {
+		t.Fatalf("expected the god address to be exempt, got %v", err)
+	}
+}
+
+func TestEthSignOverhead(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	// A fixed signature keeps the wrapped calldata of both transactions the
+	// same.
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	sig, err := crypto.Sign(crypto.Keccak256([]byte("fixed")), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overhead := func(sighashType types.SignatureHashType) uint64 {
+		tx, err := types.NewTransaction(0, to, new(big.Int), 100000, big.NewInt(1000000), []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, sighashType).WithSignature(signer, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gas, err := wrappingOverheadGas(evm, tx, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gas
+	}
+	eip155, ethSign := overhead(types.SighashEIP155), overhead(types.SighashEthSign)
+	if have, want := ethSign-eip155, EthSignOverhead(); have != want {
+		t.Fatalf("expected EthSign overhead %d over EIP155, got %d", want, have)
+	}
+	if EthSignOverhead() == 0 {
+		t.Fatal("expected a non zero EthSign overhead")
+	}
+}
+
+func TestFromExecutionManagerRunCalldata(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	emABI := evm.Context.OvmExecutionManager.ABI
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	tests := []struct {
+		to          *common.Address
+		queueOrigin types.QueueOrigin
+	}{
+		{&to, types.QueueOriginSequencer},
+		{&to, types.QueueOriginL1ToL2},
+		{nil, types.QueueOriginSequencer},
+	}
+	for i, test := range tests {
+		data := []byte{0xde, 0xad, 0xbe, 0xef}
+		msg := types.NewMessage(common.Address{}, test.to, 0, new(big.Int), 100000, new(big.Int), data, false, &sender, nil, test.queueOrigin, types.SighashEIP155)
+		calldata, err := ExecutionManagerRunCalldata(evm, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gasLimit, err := executionManagerGasLimit(evm, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx, err := FromExecutionManagerRunCalldata(emABI, calldata)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if (tx.To() == nil) != (test.to == nil) || (test.to != nil && *tx.To() != *test.to) {
+			t.Fatalf("test %d: expected recipient %v, got %v", i, test.to, tx.To())
+		}
+		if !bytes.Equal(tx.Data(), data) {
+			t.Fatalf("test %d: expected data %x, got %x", i, data, tx.Data())
+		}
+		if tx.Gas() != gasLimit {
+			t.Fatalf("test %d: expected gas %d, got %d", i, gasLimit, tx.Gas())
+		}
+		if tx.QueueOrigin().Uint64() != uint64(test.queueOrigin) {
+			t.Fatalf("test %d: expected queue origin %d, got %d", i, test.queueOrigin, tx.QueueOrigin())
+		}
+		if *tx.L1MessageSender() != sender {
+			t.Fatalf("test %d: expected L1 message sender %s, got %s", i, sender.Hex(), tx.L1MessageSender().Hex())
+		}
+	}
+
+	if _, err := FromExecutionManagerRunCalldata(emABI, []byte{1, 2, 3, 4}); !errors.Is(err, ErrInvalidRunCalldata) {
+		t.Fatalf("expected %v, got %v", ErrInvalidRunCalldata, err)
+	}
+}
+
+func TestResolveTimestamp(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	evm.ChainConfig().OvmL1TimestampBlock = big.NewInt(1)
+	evm.Context.Time = big.NewInt(100)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	tests := []struct {
+		number      int64
+		queueOrigin types.QueueOrigin
+		timestamp   uint64
+		want        uint64
+	}{
+		{1, types.QueueOriginSequencer, 0, 100},
+		{1, types.QueueOriginSequencer, 50, 50},
+		{1, types.QueueOriginL1ToL2, 0, 100},
+		{1, types.QueueOriginL1ToL2, 70, 70},
+		// Before the fork the context time is used
+		{0, types.QueueOriginSequencer, 50, 100},
+		{0, types.QueueOriginL1ToL2, 70, 100},
+	}
+	for i, test := range tests {
+		evm.Context.BlockNumber = big.NewInt(test.number)
+		msg := types.NewMessage(common.Address{}, &to, 0, new(big.Int), 100000, new(big.Int), nil, false, &common.Address{}, nil, test.queueOrigin, types.SighashEIP155).WithL1Timestamp(test.timestamp)
+		if have := ResolveTimestamp(evm.ChainConfig(), msg, evm.Context); have.Uint64() != test.want {
+			t.Fatalf("test %d: expected timestamp %d, got %d", i, test.want, have)
+		}
+
+		// The resolved timestamp is the one the execution manager runs with
+		wrapped, err := toExecutionManagerRun(evm, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args, err := evm.Context.OvmExecutionManager.ABI.Methods["run"].Inputs.UnpackValues(wrapped.Data()[4:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := reflect.ValueOf(args[0]).FieldByName("Timestamp").Interface().(*big.Int); have.Uint64() != test.want {
+			t.Fatalf("test %d: expected run timestamp %d, got %d", i, test.want, have)
+		}
+		if have := wrapped.(types.Message).L1Timestamp(); have != test.timestamp {
+			t.Fatalf("test %d: expected wrapped message timestamp %d, got %d", i, test.timestamp, have)
+		}
+	}
+}
+
+func TestMessageTxIndexPreserved(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	msg := newTestSequencerMessage(to).WithTxIndex(7)
+	wrapped, err := toExecutionManagerRun(evm, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := wrapped.(types.Message).TxIndex(); have != 7 {
+		t.Fatalf("expected wrapped message index 7, got %d", have)
+	}
+
+	evm.ChainConfig().OvmDepositGasFloorBlock = big.NewInt(0)
+	evm.ChainConfig().OvmDepositGasFloor = 50000
+	deposit := types.NewDepositMessage(common.Address{}, to, nil, 21000, 0, false).WithTxIndex(3)
+	floored := applyDepositGasFloor(evm, deposit)
+	if floored.Gas() != 50000 {
+		t.Fatalf("expected deposit gas %d, got %d", 50000, floored.Gas())
+	}
+	if have := floored.(types.Message).TxIndex(); have != 3 {
+		t.Fatalf("expected floored deposit index 3, got %d", have)
+	}
+}
+
+func TestAssertDeterministicWrapping(t *testing.T) {
+	evm := newTestOvmEVM(t)
+	signer := types.NewOVMSigner(big.NewInt(1))
+	userKey, _ := crypto.GenerateKey()
+	godKey, _ := crypto.GenerateKey()
+	god := crypto.PubkeyToAddress(godKey.PublicKey)
+	evm.ChainConfig().OvmGodAddressBlock = common.Big0
+	evm.ChainConfig().OvmGodAddress = &god
+
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	sign := func(key *ecdsa.PrivateKey, tx *types.Transaction) *types.Transaction {
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	creation := types.NewContractCreation(0, new(big.Int), 100000, new(big.Int), []byte{0x60, 0x00}, nil, nil, types.QueueOriginSequencer)
+
+	tests := []struct {
+		name string
+		tx   *types.Transaction
+	}{
+		{"eip155", sign(userKey, types.NewTransaction(0, to, big.NewInt(1), 100000, big.NewInt(1000000), []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, types.SighashEIP155))},
+		{"ethsign", sign(userKey, types.NewTransaction(1, to, new(big.Int), 100000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEthSign))},
+		{"creation", sign(userKey, creation)},
+		{"deposit", types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), []byte{1}, &to, big.NewInt(5), types.QueueOriginL1ToL2, types.SighashEIP155)},
+		{"god", sign(godKey, types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155))},
+	}
+	for _, test := range tests {
+		if err := AssertDeterministicWrapping(evm, test.tx, signer); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+
+	a := newTestSequencerMessage(to)
+	b := types.NewMessage(common.Address{}, &to, 0, new(big.Int), 21000, new(big.Int), []byte{1}, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	if field := messageDiff(a, a); field != "" {
+		t.Fatalf("expected equal messages, got difference in %s", field)
+	}
+	if field := messageDiff(a, b); field != "data" {
+		t.Fatalf("expected difference in data, got %q", field)
+	}
+}
+
+func TestWrappedMessageID(t *testing.T) {
+	signer := types.NewOVMSigner(big.NewInt(1))
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit := types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), []byte{1}, &to, big.NewInt(5), types.QueueOriginL1ToL2, types.SighashEIP155)
+
+	for _, tx := range []*types.Transaction{tx, deposit} {
+		// Two builds of the same block use separate EVMs
+		first, err := WrappedMessageID(newTestOvmEVM(t), tx, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := WrappedMessageID(newTestOvmEVM(t), tx, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first != second {
+			t.Fatalf("expected the same id across builds, got %x and %x", first, second)
+		}
+		// A different block context wraps the transaction differently
+		evm := newTestOvmEVM(t)
+		evm.Context.Time = big.NewInt(2)
+		other, err := WrappedMessageID(evm, tx, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other == first {
+			t.Fatal("expected a different id in another block context")
+		}
+	}
+
+	a, _ := WrappedMessageID(newTestOvmEVM(t), tx, signer)
+	b, _ := WrappedMessageID(newTestOvmEVM(t), deposit, signer)
+	if a == b {
+		t.Fatal("expected different transactions to have different ids")
+	}
+}
+
+func TestDefaultSignatureHashType(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	decompressor := common.HexToAddress("0x4200000000000000000000000000000000000005")
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	// A transaction with an undefined signature hash type that is already
+	// in the canonical transaction chain
+	undefined := types.SignatureHashType(0x7f)
+	tx, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.SetSignatureHashType(undefined)
+	tx.SetIndex(0)
+
+	// It is encoded as a CreateEOA transaction
+	wrapped, err := asOvmMessage(params.TestChainConfig, common.Big0, tx, signer, decompressor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have := wrapped.Data()[0]; have != 1 {
+		t.Fatalf("expected signature type 1, got %d", have)
+	}
+
+	// From the fork block of the chain config, it is encoded with the
+	// default of the chain config
+	config := *params.TestChainConfig
+	config.OvmDefaultSighashTypeBlock = big.NewInt(10)
+	config.OvmDefaultSighashType = uint8(types.SighashEIP155)
+	for _, test := range []struct {
+		number int64
+		want   byte
+	}{{9, 1}, {10, 0}} {
+		wrapped, err := asOvmMessage(&config, big.NewInt(test.number), tx, signer, decompressor)
+		if err != nil {
+			t.Fatalf("block %d: unexpected error: %v", test.number, err)
+		}
+		if have := wrapped.Data()[0]; have != test.want {
+			t.Fatalf("block %d: expected signature type %d, got %d", test.number, test.want, have)
+		}
+	}
+
+	// Defined signature hash types are not affected by the default
+	msg := types.NewMessage(common.Address{}, &to, 0, new(big.Int), 21000, new(big.Int), nil, false, &common.Address{}, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	if have := getSignatureType(msg, types.CreateEOA); have != 0 {
+		t.Fatalf("expected signature type 0 for EIP155, got %d", have)
+	}
+}
+
+func TestValidateDecompressorInputSize(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), make([]byte, 100), nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The compressed encoding has a 95 byte header before the data
+	size := 95 + 100
+
+	if err := ValidateDecompressorInputSize(params.TestChainConfig, common.Big0, tx, signer, size); err != nil {
+		t.Fatalf("expected payload at the limit to be accepted, got %v", err)
+	}
+	if err := ValidateDecompressorInputSize(params.TestChainConfig, common.Big0, tx, signer, size-1); !errors.Is(err, ErrDecompressorInputTooLarge) {
+		t.Fatalf("expected %v, got %v", ErrDecompressorInputTooLarge, err)
+	}
+	deposit := types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), make([]byte, 100), &to, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	if err := ValidateDecompressorInputSize(params.TestChainConfig, common.Big0, deposit, signer, 0); err != nil {
+		t.Fatalf("expected deposit to be accepted, got %v", err)
+	}
+}
+
+func TestCompressionSavings(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	for _, size := range []int{0, 1, 100, 1000} {
+		data := make([]byte, size)
+		tx, err := types.SignTx(types.NewTransaction(5, to, big.NewInt(1), 100000, big.NewInt(1000000), data, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uncompressed, compressed := CompressionSavings(params.TestChainConfig, common.Big0, tx, signer)
+		if uncompressed != int(tx.Size()) {
+			t.Fatalf("size %d: expected uncompressed size %d, got %d", size, int(tx.Size()), uncompressed)
+		}
+		// The compressed encoding has a 95 byte header before the data
+		if compressed != 95+size {
+			t.Fatalf("size %d: expected compressed size %d, got %d", size, 95+size, compressed)
+		}
+	}
+
+	deposit := types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), make([]byte, 10), &to, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	if uncompressed, compressed := CompressionSavings(params.TestChainConfig, common.Big0, deposit, signer); uncompressed != compressed {
+		t.Fatalf("expected no savings for a deposit, got %d and %d", uncompressed, compressed)
+	}
+}
+
+func TestAdmitTransaction(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	gwei := big.NewInt(1000000000)
+	cfg := OVMConfig{
+		Number:               big.NewInt(1),
+		MaxTxSize:            4096,
+		MaxDecompressorInput: 512,
+		MaxDepositDataSize:   256,
+	}
+
+	sign := func(tx *types.Transaction) *types.Transaction {
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	newTx := func(gas uint64, gasPrice *big.Int, data []byte) *types.Transaction {
+		return types.NewTransaction(0, to, new(big.Int), gas, gasPrice, data, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	}
+	newDeposit := func(data []byte) *types.Transaction {
+		return types.NewTransaction(0, to, new(big.Int), 100000, new(big.Int), data, &to, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	}
+
+	zeroR, err := newTx(100000, gwei, nil).WithSignature(signer, append(make([]byte, 32), append(bytes.Repeat([]byte{1}, 32), 0)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownSighash := sign(newTx(100000, gwei, nil))
+	unknownSighash.SetSignatureHashType(types.SignatureHashType(0x7f))
+	withQueueIndex := sign(newTx(100000, gwei, nil))
+	withQueueIndex.SetQueueIndex(1)
+
+	tests := []struct {
+		name string
+		tx   *types.Transaction
+		err  error
+	}{
+		{"valid", sign(newTx(100000, gwei, []byte{1, 2, 3})), nil},
+		{"valid deposit", newDeposit([]byte{1, 2, 3}), nil},
+		{"format", zeroR, types.ErrInvalidSig},
+		{"signature", unknownSighash, types.ErrSighashMismatch},
+		{"intrinsic gas", sign(newTx(20000, gwei, nil)), ErrIntrinsicGas},
+		// The intrinsic gas is checked before the gas price
+		{"intrinsic gas first", sign(newTx(20000, big.NewInt(1), nil)), ErrIntrinsicGas},
+		{"coherence", withQueueIndex, types.ErrUnexpectedRollupTxId},
+		{"representability", sign(newTx(100000, big.NewInt(1), nil)), ErrGasPriceNotRepresentable},
+		{"compressed field", sign(newTx(maxCompressedField+1, gwei, nil)), ErrCompressedFieldOverflow},
+		{"decompressor input", sign(newTx(100000, gwei, make([]byte, 1024))), ErrDecompressorInputTooLarge},
+		{"tx size", sign(newTx(200000, gwei, make([]byte, 8192))), ErrOversizedData},
+		{"deposit data", newDeposit(make([]byte, 512)), types.ErrDepositDataTooLarge},
+	}
+	for _, test := range tests {
+		if err := AdmitTransaction(params.TestChainConfig, test.tx, signer, cfg); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	// The size limits are disabled when zero
+	if err := AdmitTransaction(params.TestChainConfig, sign(newTx(200000, gwei, make([]byte, 8192))), signer, OVMConfig{Number: big.NewInt(1)}); err != nil {
+		t.Fatalf("unexpected error without size limits: %v", err)
+	}
+
+	// The intrinsic gas follows the fork rules of the chain config at the
+	// block number
+	config := *params.TestChainConfig
+	config.IstanbulBlock = big.NewInt(10)
+	calldata := sign(newTx(25000, gwei, bytes.Repeat([]byte{1}, 100)))
+	if err := AdmitTransaction(&config, calldata, signer, OVMConfig{Number: big.NewInt(10)}); err != nil {
+		t.Fatalf("unexpected error with istanbul calldata costs: %v", err)
+	}
+	if err := AdmitTransaction(&config, calldata, signer, OVMConfig{Number: big.NewInt(9)}); !errors.Is(err, ErrIntrinsicGas) {
+		t.Fatalf("expected %v before istanbul, got %v", ErrIntrinsicGas, err)
+	}
+}
@@ -371,6 +371,10 @@ func (h priceHeap) Len() int      { return len(h) }
 func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
 
 func (h priceHeap) Less(i, j int) bool {
+	// Free transactions are never the cheapest to discard
+	if h[i].IsFree() != h[j].IsFree() {
+		return h[j].IsFree()
+	}
 	// Sort primarily by price, returning the cheaper one
 	switch h[i].GasPrice().Cmp(h[j].GasPrice()) {
 	case -1:
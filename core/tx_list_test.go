@@ -17,6 +17,7 @@
 package core
 
 import (
+	"math/big"
 	"math/rand"
 	"testing"
 
@@ -49,3 +50,29 @@ func TestStrictTxListAdd(t *testing.T) {
 		}
 	}
 }
+
+// Tests that free transactions are discarded from the priced list only after
+// every priced transaction.
+func TestPricedListDiscardFree(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	free := pricedTransaction(0, 0, big.NewInt(0), key)
+	free.SetFree(true)
+	txs := types.Transactions{free, pricedTransaction(1, 0, big.NewInt(0), key), pricedTransaction(2, 0, big.NewInt(1), key)}
+
+	all := newTxLookup()
+	list := newTxPricedList(all)
+	for _, tx := range txs {
+		all.Add(tx)
+		list.Put(tx)
+	}
+	drop := list.Discard(len(txs)-1, newAccountSet(types.HomesteadSigner{}))
+	for _, tx := range drop {
+		if tx.IsFree() {
+			t.Fatalf("free transaction discarded ahead of priced transactions")
+		}
+	}
+	if len(*list.items) != 1 || (*list.items)[0] != free {
+		t.Fatalf("expected only the free transaction to remain")
+	}
+}
@@ -90,6 +90,15 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrGasPriceNotRepresentable is returned if the gas price of a transaction
+	// would be rounded by the compressed sequencer transaction encoding, so
+	// the sender would pay a different price than requested.
+	ErrGasPriceNotRepresentable = errors.New("gas price not representable in compressed encoding")
+
+	// ErrGasPriceTooHigh is returned if a transaction's gas price is above the
+	// configured ceiling, protecting senders from accidentally high fees.
+	ErrGasPriceTooHigh = errors.New("gas price above ceiling")
 )
 
 var (
@@ -152,12 +161,16 @@ type TxPoolConfig struct {
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
+	PriceCeiling *big.Int `toml:",omitempty"` // Maximum gas price to enforce for acceptance into the pool, unrestricted if nil
+
 	AccountSlots uint64 // Number of executable transaction slots guaranteed per account
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	EntrypointAllowlist map[common.Address]bool `toml:"-"` // Contracts sequencer transactions may call, unrestricted if nil
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -230,7 +243,8 @@ type TxPool struct {
 	mu          sync.RWMutex
 	rmu         sync.Mutex // Used for locking addRemotes for sequencer reorgs
 
-	istanbul bool // Fork indicator whether we are in the istanbul stage.
+	istanbul bool     // Fork indicator whether we are in the istanbul stage.
+	next     *big.Int // Number of the next pending block, which selects the OVM fork rules
 
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
@@ -566,6 +580,25 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
 		return ErrUnderpriced
 	}
+	if err := ValidateGasPriceCeiling(tx, pool.config.PriceCeiling); err != nil {
+		return err
+	}
+	// Sequencer transactions are executed at their compressed gas price
+	if vm.UsingOVM && !types.GasPriceRepresentableWithScalar(tx.GasPrice(), gasPriceScalar(pool.chainconfig, pool.next)) {
+		return ErrGasPriceNotRepresentable
+	}
+	// The zero address is reserved for creations in the compressed encoding
+	if vm.UsingOVM {
+		if err := checkZeroTarget(pool.chainconfig, pool.next, tx, pool.signer); err != nil {
+			return err
+		}
+	}
+	// Deployments may restrict the contracts that can be called directly
+	if vm.UsingOVM && pool.config.EntrypointAllowlist != nil {
+		if err := ValidateEntrypointAllowed(pool.chainconfig, pool.next, tx, pool.signer, pool.config.EntrypointAllowlist); err != nil {
+			return err
+		}
+	}
 	// Ensure the transaction adheres to nonce ordering
 	if pool.currentState.GetNonce(from) > tx.Nonce() {
 		return ErrNonceTooLow
@@ -592,6 +625,22 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	return nil
 }
 
+// ValidateGasPriceCeiling returns ErrGasPriceTooHigh if the gas price of the
+// transaction is above the ceiling. A nil ceiling disables the check. L1 to
+// L2 transactions are exempt, their gas price is not chosen by the sender.
+func ValidateGasPriceCeiling(tx *types.Transaction, ceiling *big.Int) error {
+	if ceiling == nil {
+		return nil
+	}
+	if qo := tx.QueueOrigin(); qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2) {
+		return nil
+	}
+	if tx.GasPrice().Cmp(ceiling) > 0 {
+		return ErrGasPriceTooHigh
+	}
+	return nil
+}
+
 // add validates a transaction and inserts it into the non-executable queue for later
 // pending promotion and execution. If the transaction is a replacement for an already
 // pending or queued one, it overwrites the previous transaction if its price is higher.
@@ -1199,6 +1248,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// Update all fork indicator by next pending block number.
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
+	pool.next = next
 }
 
 // promoteExecutables moves transactions that have become processable from the
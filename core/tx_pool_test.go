@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
@@ -266,6 +267,87 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+func TestInvalidTransactionsGasPriceRepresentable(t *testing.T) {
+	vm.UsingOVM = true
+	defer func() { vm.UsingOVM = false }()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	balanceKey := crypto.Keccak256Hash(common.LeftPadBytes(from.Bytes(), 32), common.LeftPadBytes([]byte{3}, 32))
+	pool.currentState.SetState(common.HexToAddress("0x4200000000000000000000000000000000000006"), balanceKey, common.BigToHash(big.NewInt(1e18)))
+
+	tests := []struct {
+		gasPrice *big.Int
+		err      error
+	}{
+		{gasPrice: big.NewInt(0)},
+		{gasPrice: big.NewInt(types.GasPriceScalar)},
+		{gasPrice: new(big.Int).Mul(big.NewInt(1000), big.NewInt(types.GasPriceScalar))},
+		{gasPrice: big.NewInt(1), err: ErrGasPriceNotRepresentable},
+		{gasPrice: new(big.Int).Add(big.NewInt(types.GasPriceScalar), common.Big1), err: ErrGasPriceNotRepresentable},
+		{gasPrice: new(big.Int).Mul(big.NewInt(1<<24), big.NewInt(types.GasPriceScalar)), err: ErrGasPriceNotRepresentable},
+	}
+	// Sequencer transactions cannot be sent to the zero address
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	for i, test := range tests {
+		tx, _ := types.SignTx(types.NewTransaction(0, to, big.NewInt(100), 100000, test.gasPrice, nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155), types.HomesteadSigner{}, key)
+		if err := pool.validateTx(tx, true); err != test.err {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+	if err := pool.validateTx(pricedTransaction(0, 100000, big.NewInt(0), key), true); err != ErrZeroTarget {
+		t.Errorf("expected %v for the zero address, got %v", ErrZeroTarget, err)
+	}
+}
+
+func TestValidateGasPriceCeiling(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	ceiling := big.NewInt(1000)
+
+	tests := []struct {
+		gasPrice *big.Int
+		err      error
+	}{
+		{gasPrice: big.NewInt(0)},
+		{gasPrice: big.NewInt(999)},
+		{gasPrice: big.NewInt(1000)},
+		{gasPrice: big.NewInt(1001), err: ErrGasPriceTooHigh},
+	}
+	for i, test := range tests {
+		tx := pricedTransaction(0, 100000, test.gasPrice, key)
+		if err := ValidateGasPriceCeiling(tx, ceiling); err != test.err {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+	if err := ValidateGasPriceCeiling(pricedTransaction(0, 100000, big.NewInt(1001), key), nil); err != nil {
+		t.Errorf("expected no ceiling to accept any price, got %v", err)
+	}
+	deposit := types.NewTransaction(0, common.Address{}, new(big.Int), 100000, new(big.Int), nil, &common.Address{}, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	if err := ValidateGasPriceCeiling(deposit, new(big.Int)); err != nil {
+		t.Errorf("expected deposit to be exempt, got %v", err)
+	}
+}
+
+func TestInvalidTransactionsGasPriceCeiling(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+	pool.config.PriceCeiling = big.NewInt(1000)
+
+	from, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(from, big.NewInt(1e18))
+
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1001), key)); err != ErrGasPriceTooHigh {
+		t.Errorf("expected %v, got %v", ErrGasPriceTooHigh, err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1000), key)); err != nil {
+		t.Errorf("expected transaction at the ceiling to be accepted, got %v", err)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
@@ -17,22 +17,35 @@
 package types
 
 import (
+	"bytes"
 	"container/heap"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"math/big"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/crypto/sha3"
 )
 
 //go:generate gencodec -type txdata -field-override txdataMarshaling -out gen_tx_json.go
 
 var (
-	ErrInvalidSig = errors.New("invalid transaction v, r, s values")
+	ErrInvalidSig          = errors.New("invalid transaction v, r, s values")
+	ErrUnsignedTransaction = errors.New("transaction is not signed")
+	ErrUnknownTxVersion    = errors.New("unknown transaction encoding version")
+	ErrInvalidDepositEnc   = errors.New("invalid deposit encoding")
+	ErrNonceGap            = errors.New("nonce gap within sender")
 )
 
 // TODO(mark): migrate from sighash type to type
@@ -44,6 +57,25 @@ const (
 	CreateEOA      SignatureHashType = 2
 )
 
+// ValidSignatureHashTypes returns every defined signature hash type.
+func ValidSignatureHashTypes() []SignatureHashType {
+	return []SignatureHashType{SighashEIP155, SighashEthSign, CreateEOA}
+}
+
+// String implements fmt.Stringer.
+func (t SignatureHashType) String() string {
+	switch t {
+	case SighashEIP155:
+		return "EIP155"
+	case SighashEthSign:
+		return "EthSign"
+	case CreateEOA:
+		return "CreateEOA"
+	default:
+		return fmt.Sprintf("SignatureHashType(%d)", uint8(t))
+	}
+}
+
 type Transaction struct {
 	data txdata
 	meta TransactionMeta
@@ -143,6 +175,35 @@ func (t *Transaction) SetIndex(index uint64) {
 	t.meta.Index = &index
 }
 
+func (t *Transaction) SetQueueIndex(queueIndex uint64) {
+	if &t.meta == nil {
+		return
+	}
+	t.meta.QueueIndex = &queueIndex
+}
+
+// QueueIndex returns the L1 queue index of the transaction. It is nil for
+// queue origin sequencer transactions.
+func (t *Transaction) QueueIndex() *uint64 {
+	if t.meta.QueueIndex == nil {
+		return nil
+	}
+	queueIndex := *t.meta.QueueIndex
+	return &queueIndex
+}
+
+// SetFree marks the transaction as intentionally free. Free transactions are
+// ordered ahead of priced sequencer transactions when building blocks and are
+// evicted from the pool last. The flag is stored in the transaction metadata.
+func (t *Transaction) SetFree(free bool) {
+	t.meta.Free = free
+}
+
+// IsFree returns true if the transaction was marked with SetFree.
+func (t *Transaction) IsFree() bool {
+	return t.meta.Free
+}
+
 func (t *Transaction) SetL1Timestamp(ts uint64) {
 	if &t.meta == nil {
 		return
@@ -169,6 +230,16 @@ func (tx *Transaction) ChainId() *big.Int {
 	return deriveChainId(tx.data.V)
 }
 
+// DeriveChainId returns the chain id encoded in the V value of an EIP155
+// protected transaction without requiring a signer. Unlike ChainId, it
+// returns zero for unprotected and unsigned transactions.
+func (tx *Transaction) DeriveChainId() *big.Int {
+	if !tx.Protected() || tx.data.V.Cmp(big.NewInt(35)) < 0 {
+		return new(big.Int)
+	}
+	return deriveChainId(tx.data.V)
+}
+
 // Protected returns whether the transaction is protected from replay protection.
 func (tx *Transaction) Protected() bool {
 	return isProtectedV(tx.data.V)
@@ -183,6 +254,32 @@ func isProtectedV(V *big.Int) bool {
 	return true
 }
 
+// RecoveryID returns the canonical signature recovery bit, 0 or 1, of the
+// transaction, independent of how V is encoded. V itself is kept as
+// received, 27 or 28 for Homestead signatures or 35 + 2*chainId + recid for
+// EIP155 signatures, since the encoding and the hash of the transaction
+// depend on it.
+func (tx *Transaction) RecoveryID() (byte, error) {
+	V := tx.data.V
+	if !isProtectedV(V) {
+		return byte(V.Uint64() - 27), nil
+	}
+	if V.Cmp(big.NewInt(35)) < 0 {
+		return 0, ErrInvalidSig
+	}
+	// 35 is odd, so the recovery bit is the inverse of the lowest bit of V
+	return byte(1 - V.Bit(0)), nil
+}
+
+// IsZero reports whether the transaction is the uninitialized zero value,
+// which has no fields and no signature set. A failed decode into a zero
+// Transaction leaves it in this state.
+func (tx *Transaction) IsZero() bool {
+	d := tx.data
+	return d.AccountNonce == 0 && d.GasLimit == 0 && d.Price == nil && d.Amount == nil &&
+		d.Recipient == nil && d.Payload == nil && d.V == nil && d.R == nil && d.S == nil
+}
+
 // EncodeRLP implements rlp.Encoder
 func (tx *Transaction) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, &tx.data)
@@ -199,11 +296,208 @@ func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
 	return err
 }
 
+// TxEncodingVersion0 is the version of the binary transaction encoding that
+// holds the RLP encoding of the current transaction layout.
+const TxEncodingVersion0 byte = 0
+
+// TxEncodingDepositVersion0 is the version of the binary transaction encoding
+// used for L1 to L2 deposits. Besides the transaction, it holds the L1
+// metadata of the deposit so that it can be decoded without the L1 context.
+const TxEncodingDepositVersion0 byte = 1
+
+// depositEncoding is the RLP layout of the deposit binary encoding. The
+// optional numbers are lists of zero or one element, so that an unset value
+// is not confused with zero.
+type depositEncoding struct {
+	Data            txdata
+	L1BlockNumber   []*big.Int
+	L1Timestamp     uint64
+	L1MessageSender *common.Address `rlp:"nil"`
+	QueueIndex      []uint64
+}
+
+// depositEncoding returns the deposit encoding of the transaction.
+func (tx *Transaction) depositEncoding() *depositEncoding {
+	deposit := &depositEncoding{
+		Data:            tx.data,
+		L1Timestamp:     tx.meta.L1Timestamp,
+		L1MessageSender: tx.meta.L1MessageSender,
+	}
+	if tx.meta.L1BlockNumber != nil {
+		deposit.L1BlockNumber = []*big.Int{tx.meta.L1BlockNumber}
+	}
+	if tx.meta.QueueIndex != nil {
+		deposit.QueueIndex = []uint64{*tx.meta.QueueIndex}
+	}
+	return deposit
+}
+
+// setDepositEncoding sets the transaction to the decoded deposit.
+func (tx *Transaction) setDepositEncoding(dec *depositEncoding) error {
+	if len(dec.L1BlockNumber) > 1 || len(dec.QueueIndex) > 1 {
+		return ErrInvalidDepositEnc
+	}
+	tx.data = dec.Data
+	tx.meta = TransactionMeta{
+		L1Timestamp:       dec.L1Timestamp,
+		L1MessageSender:   dec.L1MessageSender,
+		SignatureHashType: SighashEIP155,
+		QueueOrigin:       big.NewInt(int64(QueueOriginL1ToL2)),
+	}
+	if len(dec.L1BlockNumber) == 1 {
+		tx.meta.L1BlockNumber = dec.L1BlockNumber[0]
+	}
+	if len(dec.QueueIndex) == 1 {
+		queueIndex := dec.QueueIndex[0]
+		tx.meta.QueueIndex = &queueIndex
+	}
+	return nil
+}
+
+// MarshalBinary returns the binary encoding of the transaction, which is the
+// encoding version byte followed by the RLP encoding of the transaction.
+// Deposits use the deposit encoding, which includes their L1 metadata.
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	if isDeposit(tx) {
+		enc, err := rlp.EncodeToBytes(tx.depositEncoding())
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{TxEncodingDepositVersion0}, enc...), nil
+	}
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{TxEncodingVersion0}, enc...), nil
+}
+
+// UnmarshalBinary decodes the binary encoding of a transaction. The version
+// prefix is optional: an RLP list prefix in the first byte indicates a
+// transaction encoded without it, using the version 0 layout.
+func (tx *Transaction) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return rlp.EOL
+	}
+	if b[0] >= 0xc0 {
+		return rlp.DecodeBytes(b, tx)
+	}
+	switch b[0] {
+	case TxEncodingVersion0:
+		return rlp.DecodeBytes(b[1:], tx)
+	case TxEncodingDepositVersion0:
+		var dec depositEncoding
+		if err := rlp.DecodeBytes(b[1:], &dec); err != nil {
+			return err
+		}
+		return tx.setDepositEncoding(&dec)
+	default:
+		return fmt.Errorf("%w: %d", ErrUnknownTxVersion, b[0])
+	}
+}
+
 // MarshalJSON encodes the web3 RPC transaction format.
 func (tx *Transaction) MarshalJSON() ([]byte, error) {
 	return tx.data.TransactionMarshalJSON()
 }
 
+// MarshalJSONChecksummed encodes the web3 RPC transaction format like
+// MarshalJSON, but with the recipient as an EIP55 checksummed address
+// instead of lowercase hex. The fields are encoded in alphabetical order.
+func (tx *Transaction) MarshalJSONChecksummed() ([]byte, error) {
+	enc, err := tx.data.TransactionMarshalJSON()
+	if err != nil || tx.data.Recipient == nil {
+		return enc, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		return nil, err
+	}
+	if fields["to"], err = json.Marshal(tx.data.Recipient.Hex()); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// Diff returns a human readable description of each field that differs
+// between the transactions, including the OVM transaction metadata. It is
+// meant for debugging, e.g. to explain a failed round trip in a test.
+func (tx *Transaction) Diff(other *Transaction) []string {
+	var diffs []string
+	add := func(field, a, b string) {
+		if a != b {
+			diffs = append(diffs, fmt.Sprintf("%s: %s != %s", field, a, b))
+		}
+	}
+	add("nonce", fmt.Sprint(tx.data.AccountNonce), fmt.Sprint(other.data.AccountNonce))
+	add("gasPrice", diffBig(tx.data.Price), diffBig(other.data.Price))
+	add("gas", fmt.Sprint(tx.data.GasLimit), fmt.Sprint(other.data.GasLimit))
+	add("to", diffAddress(tx.data.Recipient), diffAddress(other.data.Recipient))
+	add("value", diffBig(tx.data.Amount), diffBig(other.data.Amount))
+	add("data", hexutil.Encode(tx.data.Payload), hexutil.Encode(other.data.Payload))
+	add("v", diffBig(tx.data.V), diffBig(other.data.V))
+	add("r", diffBig(tx.data.R), diffBig(other.data.R))
+	add("s", diffBig(tx.data.S), diffBig(other.data.S))
+
+	add("l1BlockNumber", diffBig(tx.meta.L1BlockNumber), diffBig(other.meta.L1BlockNumber))
+	add("l1Timestamp", fmt.Sprint(tx.meta.L1Timestamp), fmt.Sprint(other.meta.L1Timestamp))
+	add("l1MessageSender", diffAddress(tx.meta.L1MessageSender), diffAddress(other.meta.L1MessageSender))
+	add("signatureHashType", tx.meta.SignatureHashType.String(), other.meta.SignatureHashType.String())
+	add("queueOrigin", diffBig(tx.meta.QueueOrigin), diffBig(other.meta.QueueOrigin))
+	add("index", diffUint64(tx.meta.Index), diffUint64(other.meta.Index))
+	add("queueIndex", diffUint64(tx.meta.QueueIndex), diffUint64(other.meta.QueueIndex))
+	return diffs
+}
+
+// DeepEqual reports whether the transactions have the same fields, including
+// the signature and the OVM transaction metadata, see Diff. The cached hash,
+// size and sender are ignored, so transactions compare equal regardless of
+// which of their caches have been populated.
+func DeepEqual(a, b *Transaction) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return len(a.Diff(b)) == 0
+}
+
+func diffBig(x *big.Int) string {
+	if x == nil {
+		return "<nil>"
+	}
+	return x.String()
+}
+
+func diffAddress(addr *common.Address) string {
+	if addr == nil {
+		return "<nil>"
+	}
+	return addr.Hex()
+}
+
+func diffUint64(x *uint64) string {
+	if x == nil {
+		return "<nil>"
+	}
+	return fmt.Sprint(*x)
+}
+
+// UnmarshalTransactionHex decodes an RLP encoded transaction from a hex
+// string. The 0x prefix is optional.
+func UnmarshalTransactionHex(s string) (*Transaction, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		s = "0x" + s
+	}
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	tx := new(Transaction)
+	if err := rlp.DecodeBytes(b, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
 // UnmarshalJSON decodes the web3 RPC transaction format.
 func (tx *Transaction) UnmarshalJSON(input []byte) error {
 	err := tx.data.TransactionUnmarshalJSON(input)
@@ -243,6 +537,13 @@ func (tx *Transaction) IsEthSignSighash() bool {
 	return tx.SignatureHashType() == SighashEthSign
 }
 
+// EffectiveNonce returns the nonce of the transaction and whether it takes
+// part in the nonce ordering of the sender account. Deposits use the queue
+// index as the nonce and do not increment the account nonce, so they do not.
+func (tx *Transaction) EffectiveNonce() (uint64, bool) {
+	return tx.data.AccountNonce, !isDeposit(tx)
+}
+
 // To returns the recipient address of the transaction.
 // It returns nil if the transaction is a contract creation.
 func (tx *Transaction) To() *common.Address {
@@ -282,18 +583,110 @@ func (tx *Transaction) QueueOrigin() *big.Int {
 	return &queueOrigin
 }
 
+// ResolveQueueOrigin returns the queue origin of the transaction, inferring
+// it when it was never set, as for transactions stored before the queue
+// origin was tracked. Only L1 to L2 transactions have a queue index, so a
+// transaction with a queue index is inferred to be L1ToL2 and one without
+// is inferred to be Sequencer. The inferred origin is stored on the
+// transaction.
+func (tx *Transaction) ResolveQueueOrigin() QueueOrigin {
+	if tx.meta.QueueOrigin == nil {
+		origin := QueueOriginSequencer
+		if tx.meta.QueueIndex != nil {
+			origin = QueueOriginL1ToL2
+		}
+		tx.meta.QueueOrigin = big.NewInt(int64(origin))
+	}
+	return QueueOrigin(tx.meta.QueueOrigin.Int64())
+}
+
 // Hash hashes the RLP encoding of tx.
 // It uniquely identifies the transaction.
+//
+// There are no typed transactions yet, every transaction is hashed as a
+// legacy transaction. The encoding version of MarshalBinary is not part of
+// the hash, since the metadata that selects it can be set after the hash is
+// cached. Typed transactions must prefix their hash with the type byte so
+// that it cannot collide with the hash of a legacy transaction.
 func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
 
-	v := rlpHash(tx)
+	var v common.Hash
+	if len(tx.data.Payload) > streamingHashThreshold {
+		v = streamingRlpHash(&tx.data)
+	} else {
+		v = rlpHash(tx)
+	}
 	tx.hash.Store(v)
 	return v
 }
 
+// streamingHashThreshold is the calldata size above which Hash feeds the RLP
+// encoding of the transaction into keccak256 piece by piece instead of
+// encoding it into a single buffer first.
+const streamingHashThreshold = 64 * 1024
+
+// streamingRlpHash returns the same hash as rlpHash of the transaction data.
+// Only the small fields are encoded into buffers, the payload is written to
+// the hasher directly, so the peak memory use does not grow with the size of
+// the payload.
+func streamingRlpHash(data *txdata) (h common.Hash) {
+	var head []byte
+	for _, field := range []interface{}{data.AccountNonce, data.Price, data.GasLimit, data.Recipient, data.Amount} {
+		enc, _ := rlp.EncodeToBytes(field)
+		head = append(head, enc...)
+	}
+	var tail []byte
+	for _, field := range []*big.Int{data.V, data.R, data.S} {
+		enc, _ := rlp.EncodeToBytes(field)
+		tail = append(tail, enc...)
+	}
+	var payloadHeader []byte
+	if len(data.Payload) != 1 || data.Payload[0] >= 0x80 {
+		payloadHeader = rlpHeader(0x80, uint64(len(data.Payload)))
+	}
+	size := uint64(len(head)+len(payloadHeader)+len(data.Payload)) + uint64(len(tail))
+
+	hw := sha3.NewLegacyKeccak256()
+	hw.Write(rlpHeader(0xc0, size))
+	hw.Write(head)
+	hw.Write(payloadHeader)
+	hw.Write(data.Payload)
+	hw.Write(tail)
+	hw.Sum(h[:0])
+	return h
+}
+
+// rlpHeader returns the RLP header of a string (offset 0x80) or a list
+// (offset 0xc0) with content of the given size.
+func rlpHeader(offset byte, size uint64) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	var be []byte
+	for s := size; s > 0; s >>= 8 {
+		be = append([]byte{byte(s)}, be...)
+	}
+	return append([]byte{offset + 55 + byte(len(be))}, be...)
+}
+
+// ExplainHashInputs returns the RLP encoding that Hash feeds into keccak256.
+// The transaction metadata is not part of it. Diffing the hash inputs of two
+// transactions shows why their hashes differ.
+func ExplainHashInputs(tx *Transaction) []byte {
+	enc, _ := rlp.EncodeToBytes(tx)
+	return enc
+}
+
+// PoolKey returns the key of the transaction in the transaction pool. The
+// key must not depend on the OVM metadata, which does not change what the
+// transaction does, so that copies differing only in metadata share a key.
+func (tx *Transaction) PoolKey() common.Hash {
+	return tx.Hash()
+}
+
 // Size returns the true RLP encoded storage size of the transaction, either by
 // encoding and returning it, or returning a previsouly cached value.
 func (tx *Transaction) Size() common.StorageSize {
@@ -306,12 +699,97 @@ func (tx *Transaction) Size() common.StorageSize {
 	return common.StorageSize(c)
 }
 
+// EstimateRLPSize computes the RLP encoded size of the transaction from the
+// field lengths without encoding it. The result always equals the length of
+// the RLP encoding. The transaction meta is not part of the RLP encoding and
+// so does not contribute to the size.
+func (tx *Transaction) EstimateRLPSize() uint64 {
+	var recipient uint64 = 1
+	if tx.data.Recipient != nil {
+		recipient = 1 + common.AddressLength
+	}
+	size := rlpUintSize(tx.data.AccountNonce) +
+		rlpBigSize(tx.data.Price) +
+		rlpUintSize(tx.data.GasLimit) +
+		recipient +
+		rlpBigSize(tx.data.Amount) +
+		rlpBytesSize(tx.data.Payload) +
+		rlpBigSize(tx.data.V) +
+		rlpBigSize(tx.data.R) +
+		rlpBigSize(tx.data.S)
+	return rlpHeaderSize(size) + size
+}
+
+// rlpHeaderSize returns the size of the RLP string or list header for a
+// payload of the given length.
+func rlpHeaderSize(length uint64) uint64 {
+	if length < 56 {
+		return 1
+	}
+	var n uint64 = 1
+	for ; length > 0; length >>= 8 {
+		n++
+	}
+	return n
+}
+
+// rlpBytesSize returns the RLP encoded size of a byte string.
+func rlpBytesSize(b []byte) uint64 {
+	if len(b) == 1 && b[0] < 0x80 {
+		return 1
+	}
+	return rlpHeaderSize(uint64(len(b))) + uint64(len(b))
+}
+
+// rlpUintSize returns the RLP encoded size of an unsigned integer.
+func rlpUintSize(i uint64) uint64 {
+	if i < 0x80 {
+		return 1
+	}
+	var n uint64 = 1
+	for ; i > 0; i >>= 8 {
+		n++
+	}
+	return n
+}
+
+// rlpBigSize returns the RLP encoded size of a big integer, where nil is
+// encoded as zero.
+func rlpBigSize(i *big.Int) uint64 {
+	if i == nil || i.IsUint64() {
+		var u uint64
+		if i != nil {
+			u = i.Uint64()
+		}
+		return rlpUintSize(u)
+	}
+	return rlpHeaderSize(uint64((i.BitLen()+7)/8)) + uint64((i.BitLen()+7)/8)
+}
+
 // AsMessage returns the transaction as a core.Message.
 //
 // AsMessage requires a signer to derive the sender.
 //
 // XXX Rename message to something less arbitrary?
 func (tx *Transaction) AsMessage(s Signer) (Message, error) {
+	msg := tx.newMessage()
+	var err error
+	msg.from, err = recoverMessageSender(s, tx)
+	return msg, err
+}
+
+// AsLazyMessage returns the transaction as a core.Message like AsMessage,
+// but defers the recovery of the sender until From is first called. The
+// recovered sender is cached and shared by the copies of the message. A
+// failed recovery results in the zero address, see RecoverFrom.
+func (tx *Transaction) AsLazyMessage(s Signer) Message {
+	msg := tx.newMessage()
+	msg.lazyFrom = &lazySender{signer: s, tx: tx}
+	return msg
+}
+
+// newMessage returns the transaction as a Message without a sender.
+func (tx *Transaction) newMessage() Message {
 	msg := Message{
 		nonce:             tx.data.AccountNonce,
 		gasLimit:          tx.data.GasLimit,
@@ -324,19 +802,27 @@ func (tx *Transaction) AsMessage(s Signer) (Message, error) {
 		amount:            tx.data.Amount,
 		data:              tx.data.Payload,
 		checkNonce:        true,
+		originTxHash:      tx.Hash(),
+		l1Timestamp:       tx.meta.L1Timestamp,
 	}
 
-	var err error
-	msg.from, err = Sender(s, tx)
-
 	if tx.meta.L1MessageSender != nil {
 		msg.l1MessageSender = tx.meta.L1MessageSender
 	} else {
 		addr := common.Address{}
 		msg.l1MessageSender = &addr
 	}
+	return msg
+}
 
-	return msg, err
+// recoverMessageSender returns the sender of the message of the transaction.
+func recoverMessageSender(s Signer, tx *Transaction) (common.Address, error) {
+	// L1 to L2 transactions are not signed, their sender is set by L1
+	qo := tx.meta.QueueOrigin
+	if tx.IsSigned() || (qo != nil && qo.Uint64() == uint64(QueueOriginL1ToL2)) {
+		return Sender(s, tx)
+	}
+	return common.Address{}, ErrUnsignedTransaction
 }
 
 // WithSignature returns a new transaction with the given signature.
@@ -351,6 +837,20 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	return cpy, nil
 }
 
+// AsLegacy returns a copy of the transaction with the OVM metadata cleared,
+// leaving a plain sequencer transaction signed with EIP155. The metadata is
+// not part of the RLP encoding, so the copy has the same hash.
+func (tx *Transaction) AsLegacy() *Transaction {
+	return &Transaction{
+		data: tx.data,
+		meta: TransactionMeta{
+			SignatureHashType: SighashEIP155,
+			QueueOrigin:       big.NewInt(int64(QueueOriginSequencer)),
+			Free:              tx.meta.Free,
+		},
+	}
+}
+
 // Cost returns amount + gasprice * gaslimit.
 func (tx *Transaction) Cost() *big.Int {
 	total := new(big.Int).Mul(tx.data.Price, new(big.Int).SetUint64(tx.data.GasLimit))
@@ -358,12 +858,97 @@ func (tx *Transaction) Cost() *big.Int {
 	return total
 }
 
+// TraceFields returns the fields of the transaction as alternating keys and
+// values, to be passed to the logger as log.Trace("msg", tx.TraceFields()...).
+// The sender is only included if it has already been recovered and cached,
+// and the L1 message sender only if it is set.
+func (tx *Transaction) TraceFields() []interface{} {
+	fields := []interface{}{"hash", tx.Hash()}
+	if sc := tx.from.Load(); sc != nil {
+		fields = append(fields, "from", sc.(sigCache).from)
+	}
+	fields = append(fields,
+		"to", tx.data.Recipient,
+		"nonce", tx.data.AccountNonce,
+		"gas", tx.data.GasLimit,
+		"gasPrice", tx.data.Price,
+		"value", tx.data.Amount,
+		"queueOrigin", tx.meta.QueueOrigin,
+		"sighashType", tx.meta.SignatureHashType,
+		"dataLen", len(tx.data.Payload),
+	)
+	if tx.meta.L1MessageSender != nil {
+		fields = append(fields, "l1MessageSender", *tx.meta.L1MessageSender)
+	}
+	return fields
+}
+
+// HumanReadableIntent describes what the transaction does, such as
+// "Send 1.5 ETH to 0x...", for wallets to display before it is signed.
+func (tx *Transaction) HumanReadableIntent() string {
+	value := formatEther(tx.data.Amount)
+	switch {
+	case tx.data.Recipient == nil:
+		intent := fmt.Sprintf("Deploy a contract with %s of code", byteCount(len(tx.data.Payload)))
+		if tx.data.Amount.Sign() != 0 {
+			intent += fmt.Sprintf(", sending %s ETH", value)
+		}
+		return intent
+	case len(tx.data.Payload) == 0:
+		return fmt.Sprintf("Send %s ETH to %s", value, tx.data.Recipient.Hex())
+	default:
+		intent := fmt.Sprintf("Call contract %s with %s of data", tx.data.Recipient.Hex(), byteCount(len(tx.data.Payload)))
+		if tx.data.Amount.Sign() != 0 {
+			intent += fmt.Sprintf(", sending %s ETH", value)
+		}
+		return intent
+	}
+}
+
+// byteCount formats a number of bytes for display.
+func byteCount(n int) string {
+	if n == 1 {
+		return "1 byte"
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// formatEther formats an amount of wei in ether, without trailing zeros.
+func formatEther(wei *big.Int) string {
+	ether := new(big.Rat).SetFrac(wei, big.NewInt(params.Ether)).FloatString(18)
+	return strings.TrimSuffix(strings.TrimRight(ether, "0"), ".")
+}
+
+// IsSigned returns whether the transaction carries a signature, i.e. whether
+// any of its V, R, S signature values is nonzero.
+func (tx *Transaction) IsSigned() bool {
+	return tx.data.V.Sign() != 0 || tx.data.R.Sign() != 0 || tx.data.S.Sign() != 0
+}
+
 // RawSignatureValues returns the V, R, S signature values of the transaction.
 // The return values should not be modified by the caller.
 func (tx *Transaction) RawSignatureValues() (v, r, s *big.Int) {
 	return tx.data.V, tx.data.R, tx.data.S
 }
 
+// ReplacesSameNonce returns true if both transactions are sent by the same
+// account with the same nonce, making one a replacement candidate for the
+// other. The senders are recovered using the given signer.
+func (tx *Transaction) ReplacesSameNonce(other *Transaction, signer Signer) (bool, error) {
+	if tx.Nonce() != other.Nonce() {
+		return false, nil
+	}
+	from, err := Sender(signer, tx)
+	if err != nil {
+		return false, err
+	}
+	otherFrom, err := Sender(signer, other)
+	if err != nil {
+		return false, err
+	}
+	return from == otherFrom, nil
+}
+
 // Transactions is a Transaction slice type for basic sorting.
 type Transactions []*Transaction
 
@@ -379,6 +964,133 @@ func (s Transactions) GetRlp(i int) []byte {
 	return enc
 }
 
+// GroupBySender recovers the senders of the transactions and groups the
+// transactions by sender. Each group is sorted by nonce, keeping the
+// original order of transactions with the same nonce.
+func (s Transactions) GroupBySender(signer Signer) (map[common.Address]Transactions, error) {
+	senders, err := recoverSenders(signer, s)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[common.Address]Transactions)
+	for i, tx := range s {
+		groups[senders[i]] = append(groups[senders[i]], tx)
+	}
+	for _, txs := range groups {
+		txs.SortByNonce()
+	}
+	return groups, nil
+}
+
+// SortByNonce sorts the transactions by nonce in place, keeping the original
+// order of transactions with the same nonce. The transactions are assumed to
+// be from a single sender.
+func (s Transactions) SortByNonce() {
+	sort.Stable(TxByNonce(s))
+}
+
+// OriginCounts returns the number of transactions of each queue origin.
+// Transactions without a queue origin are counted with the zero value,
+// QueueOriginSequencer.
+func (s Transactions) OriginCounts() map[QueueOrigin]int {
+	counts := make(map[QueueOrigin]int)
+	for _, tx := range s {
+		var origin QueueOrigin
+		if qo := tx.meta.QueueOrigin; qo != nil {
+			origin = QueueOrigin(qo.Int64())
+		}
+		counts[origin]++
+	}
+	return counts
+}
+
+// SighashTypeCounts returns the number of transactions of each signature
+// hash type. Transactions with an undefined signature hash type are counted
+// under their own value, separate from the defined types.
+func (s Transactions) SighashTypeCounts() map[SignatureHashType]int {
+	counts := make(map[SignatureHashType]int)
+	for _, tx := range s {
+		counts[tx.meta.SignatureHashType]++
+	}
+	return counts
+}
+
+// DepositCountFrom returns the number of L1 to L2 transactions sent by the
+// given L1 sender.
+func (s Transactions) DepositCountFrom(l1Sender common.Address) int {
+	var count int
+	for _, tx := range s {
+		if isDeposit(tx) && tx.meta.L1MessageSender != nil && *tx.meta.L1MessageSender == l1Sender {
+			count++
+		}
+	}
+	return count
+}
+
+// L1Senders returns the distinct L1 message senders of the transactions, in
+// the order they first appear. Transactions without one are skipped.
+func (s Transactions) L1Senders() []common.Address {
+	var (
+		seen    = make(map[common.Address]struct{})
+		senders []common.Address
+	)
+	for _, tx := range s {
+		sender := tx.meta.L1MessageSender
+		if sender == nil {
+			continue
+		}
+		if _, ok := seen[*sender]; !ok {
+			seen[*sender] = struct{}{}
+			senders = append(senders, *sender)
+		}
+	}
+	return senders
+}
+
+// MerkleRoot returns the root of the binary Merkle tree over the hashes of
+// the transactions, in order. Each parent is the keccak256 hash of its two
+// children. A node without a sibling at the end of a level is paired with
+// the zero hash. The root of a single transaction is its hash and the root
+// of no transactions is the zero hash.
+func (s Transactions) MerkleRoot() common.Hash {
+	if len(s) == 0 {
+		return common.Hash{}
+	}
+	level := make([]common.Hash, len(s))
+	for i, tx := range s {
+		level[i] = tx.Hash()
+	}
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, common.Hash{})
+		}
+		next := make([]common.Hash, len(level)/2)
+		for i := range next {
+			next[i] = crypto.Keccak256Hash(level[2*i][:], level[2*i+1][:])
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// FindDuplicates returns the hashes of the transactions that appear more
+// than once, in the order their first duplicate appears. The OVM metadata is
+// not part of the hash, so copies that differ only in metadata are reported.
+func (s Transactions) FindDuplicates() []common.Hash {
+	var (
+		seen = make(map[common.Hash]int, len(s))
+		dups []common.Hash
+	)
+	for _, tx := range s {
+		hash := tx.Hash()
+		seen[hash]++
+		if seen[hash] == 2 {
+			dups = append(dups, hash)
+		}
+	}
+	return dups
+}
+
 // TxDifference returns a new set which is the difference between a and b.
 func TxDifference(a, b Transactions) Transactions {
 	keep := make(Transactions, 0, len(a))
@@ -406,6 +1118,60 @@ func (s TxByNonce) Len() int           { return len(s) }
 func (s TxByNonce) Less(i, j int) bool { return s[i].data.AccountNonce < s[j].data.AccountNonce }
 func (s TxByNonce) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
+// HasDuplicateNonces reports whether two transactions of the slice have the
+// same nonce. The transactions are assumed to be from a single sender, so a
+// duplicate is a replacement that was not resolved.
+func (s Transactions) HasDuplicateNonces() bool {
+	seen := make(map[uint64]struct{}, len(s))
+	for _, tx := range s {
+		if _, ok := seen[tx.data.AccountNonce]; ok {
+			return true
+		}
+		seen[tx.data.AccountNonce] = struct{}{}
+	}
+	return false
+}
+
+// DuplicateNonceSenders returns the senders whose transactions have
+// duplicate nonces, see HasDuplicateNonces, sorted by address. The groups
+// are in the form accepted by NewTransactionsByPriceAndNonce.
+func DuplicateNonceSenders(groups map[common.Address]Transactions) []common.Address {
+	var senders []common.Address
+	for from, txs := range groups {
+		if txs.HasDuplicateNonces() {
+			senders = append(senders, from)
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return bytes.Compare(senders[i].Bytes(), senders[j].Bytes()) < 0
+	})
+	return senders
+}
+
+// AssertSenderNonceContiguity checks that the transactions of every sender
+// appear in the slice with consecutive nonces, so that executing the slice in
+// order never runs a transaction ahead of its predecessor. Transactions of
+// other senders may be interleaved. Deposits are ordered by queue index and
+// are not checked. It returns ErrNonceGap for the first violation.
+func AssertSenderNonceContiguity(txs Transactions, signer Signer) error {
+	last := make(map[common.Address]uint64)
+	for i, tx := range txs {
+		if isDeposit(tx) {
+			continue
+		}
+		from, err := Sender(signer, tx)
+		if err != nil {
+			return err
+		}
+		nonce := tx.data.AccountNonce
+		if prev, ok := last[from]; ok && nonce != prev+1 {
+			return fmt.Errorf("%w: tx %d from %s has nonce %d after %d", ErrNonceGap, i, from.Hex(), nonce, prev)
+		}
+		last[from] = nonce
+	}
+	return nil
+}
+
 // TxByPrice implements both the sort and the heap interface, making it useful
 // for all at once sorting as well as individually adding and removing elements.
 type TxByPrice Transactions
@@ -430,21 +1196,44 @@ type TxByIndexAndPrice Transactions
 
 func (s TxByIndexAndPrice) Len() int { return len(s) }
 func (s TxByIndexAndPrice) Less(i, j int) bool {
-	metai, metaj := s[i].GetMeta(), s[j].GetMeta()
+	// The heap holds a single transaction per account, so the senders do
+	// not need to be compared.
+	return LessByPriceAndOrigin(nil, s[i], s[j])
+}
+
+// LessByPriceAndOrigin reports whether a should be executed before b, using
+// the ordering of TransactionsByPriceAndNonce. Transactions that already have
+// a canonical transaction chain index come first, in index order. The others
+// are sorted with free transactions first, then by descending gas price. If a
+// signer is given and both transactions are sent by the same account, the
+// lower nonce comes first regardless of the price.
+func LessByPriceAndOrigin(signer Signer, a, b *Transaction) bool {
+	if signer != nil {
+		froma, erra := Sender(signer, a)
+		fromb, errb := Sender(signer, b)
+		if erra == nil && errb == nil && froma == fromb {
+			return a.data.AccountNonce < b.data.AccountNonce
+		}
+	}
+	metaa, metab := a.GetMeta(), b.GetMeta()
 	// They should never be the same integer but they
-	// can both be nil. Sort by gasPrice in this case.
-	if metai.Index == nil && metaj.Index == nil {
-		return s[i].data.Price.Cmp(s[j].data.Price) > 0
+	// can both be nil. Sort by gasPrice in this case,
+	// with free transactions ahead of priced ones.
+	if metaa.Index == nil && metab.Index == nil {
+		if metaa.Free != metab.Free {
+			return metaa.Free
+		}
+		return a.data.Price.Cmp(b.data.Price) > 0
 	}
 	// When the index is nil, it means that it is unknown. This
 	// indicates queue origin sequencer.
-	if metai.Index == nil && metaj.Index != nil {
+	if metaa.Index == nil && metab.Index != nil {
 		return false
 	}
-	if metai.Index != nil && metaj.Index == nil {
+	if metaa.Index != nil && metab.Index == nil {
 		return true
 	}
-	return *metai.Index < *metaj.Index
+	return *metaa.Index < *metab.Index
 }
 func (s TxByIndexAndPrice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 func (s *TxByIndexAndPrice) Push(x interface{}) {
@@ -462,9 +1251,19 @@ func (s *TxByIndexAndPrice) Pop() interface{} {
 // transactions in a profit-maximizing sorted order, while supporting removing
 // entire batches of transactions for non-executable accounts.
 type TransactionsByPriceAndNonce struct {
-	txs    map[common.Address]Transactions // Per account nonce-sorted list of transactions
-	heads  TxByIndexAndPrice               // Next transaction for each unique account (price heap)
-	signer Signer                          // Signer for the set of transactions
+	txs     map[common.Address]Transactions // Per account nonce-sorted list of transactions
+	heads   TxByIndexAndPrice               // Next transaction for each unique account (price heap)
+	senders map[*Transaction]common.Address // Sender of each head, recovered once per account
+	signer  Signer                          // Signer for the set of transactions
+
+	maxDeposits int          // Maximum number of deposits to retrieve, negative if unlimited
+	deposits    int          // Number of deposits recorded as included
+	deferred    Transactions // Deposits held back by the deposit limit
+
+	// Initial state of the set, used to restore it on Reset
+	initTxs     map[common.Address]Transactions
+	initHeads   TxByIndexAndPrice
+	initSenders map[*Transaction]common.Address
 }
 
 // NewTransactionsByPriceAndNonce creates a transaction set that can retrieve
@@ -475,12 +1274,14 @@ type TransactionsByPriceAndNonce struct {
 func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transactions) *TransactionsByPriceAndNonce {
 	// Initialize a price based heap with the head transactions
 	heads := make(TxByIndexAndPrice, 0, len(txs))
+	senders := make(map[*Transaction]common.Address, len(txs))
 	for from, accTxs := range txs {
 		// This prevents a panic, not ideal.
 		if len(accTxs) > 0 {
 			heads = append(heads, accTxs[0])
 			// Ensure the sender address is from the signer
 			acc, _ := Sender(signer, accTxs[0])
+			senders[accTxs[0]] = acc
 			txs[acc] = accTxs[1:]
 			if from != acc {
 				delete(txs, from)
@@ -490,26 +1291,117 @@ func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transa
 	heap.Init(&heads)
 
 	// Assemble and return the transaction set
-	return &TransactionsByPriceAndNonce{
-		txs:    txs,
-		heads:  heads,
-		signer: signer,
+	t := &TransactionsByPriceAndNonce{
+		txs:         txs,
+		heads:       heads,
+		senders:     senders,
+		signer:      signer,
+		maxDeposits: -1,
+		initTxs:     make(map[common.Address]Transactions, len(txs)),
+		initHeads:   append(TxByIndexAndPrice(nil), heads...),
+		initSenders: make(map[*Transaction]common.Address, len(senders)),
+	}
+	for acc, accTxs := range txs {
+		t.initTxs[acc] = accTxs
 	}
+	for tx, acc := range senders {
+		t.initSenders[tx] = acc
+	}
+	return t
+}
+
+// Reset restores the set to the state it was created in, so that the same
+// transactions can be retrieved again, e.g. to retry building a block.
+func (t *TransactionsByPriceAndNonce) Reset() {
+	t.txs = make(map[common.Address]Transactions, len(t.initTxs))
+	for acc, accTxs := range t.initTxs {
+		t.txs[acc] = accTxs
+	}
+	t.senders = make(map[*Transaction]common.Address, len(t.initSenders))
+	for tx, acc := range t.initSenders {
+		t.senders[tx] = acc
+	}
+	// The initial heads are already heap ordered
+	t.heads = append(t.heads[:0], t.initHeads...)
+	t.deposits = 0
+	t.deferred = nil
+}
+
+// SetMaxDeposits limits the number of L1 to L2 deposits retrieved from the
+// set. Once the limit is reached, the remaining deposits are skipped by Peek
+// and can be retrieved with Deferred to be included in a later block. Only
+// deposits recorded with IncludeDeposit count towards the limit.
+func (t *TransactionsByPriceAndNonce) SetMaxDeposits(max int) {
+	t.maxDeposits = max
+}
+
+// IncludeDeposit records that the deposit returned by Peek was included in
+// the block. Deposits that are shifted out of the set without being
+// included, e.g. because their nonce is too low, do not use up the limit.
+func (t *TransactionsByPriceAndNonce) IncludeDeposit() {
+	t.deposits++
+}
+
+// Deposits returns the number of deposits recorded as included.
+func (t *TransactionsByPriceAndNonce) Deposits() int {
+	return t.deposits
+}
+
+// Deferred returns the deposits skipped because of the deposit limit, sorted
+// by queue index.
+func (t *TransactionsByPriceAndNonce) Deferred() Transactions {
+	deferred := append(Transactions(nil), t.deferred...)
+	sort.SliceStable(deferred, func(i, j int) bool {
+		qi, qj := deferred[i].QueueIndex(), deferred[j].QueueIndex()
+		if qi == nil || qj == nil {
+			return qi != nil
+		}
+		return *qi < *qj
+	})
+	return deferred
+}
+
+// isDeposit returns whether the transaction is an L1 to L2 deposit.
+func isDeposit(tx *Transaction) bool {
+	qo := tx.meta.QueueOrigin
+	return qo != nil && qo.Uint64() == uint64(QueueOriginL1ToL2)
 }
 
 // Peek returns the next transaction by price.
 func (t *TransactionsByPriceAndNonce) Peek() *Transaction {
+	// Hold back deposits beyond the deposit limit
+	for len(t.heads) > 0 && t.maxDeposits >= 0 && t.deposits >= t.maxDeposits && isDeposit(t.heads[0]) {
+		t.deferred = append(t.deferred, t.heads[0])
+		t.Shift()
+	}
 	if len(t.heads) == 0 {
 		return nil
 	}
 	return t.heads[0]
 }
 
+// PeekWithDeadline is like Peek, but returns nil once the context is done,
+// so that building a block can stop at a deadline.
+func (t *TransactionsByPriceAndNonce) PeekWithDeadline(ctx context.Context) *Transaction {
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+		return t.Peek()
+	}
+}
+
 // Shift replaces the current best head with the next one from the same account.
+//
+// The next transaction inherits the sender of the head it replaces, so the
+// signature is only recovered once per account.
 func (t *TransactionsByPriceAndNonce) Shift() {
-	acc, _ := Sender(t.signer, t.heads[0])
+	head := t.heads[0]
+	acc := t.senders[head]
+	delete(t.senders, head)
 	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
 		t.heads[0], t.txs[acc] = txs[0], txs[1:]
+		t.senders[txs[0]] = acc
 		heap.Fix(&t.heads, 0)
 	} else {
 		heap.Pop(&t.heads)
@@ -520,9 +1412,51 @@ func (t *TransactionsByPriceAndNonce) Shift() {
 // the same account. This should be used when a transaction cannot be executed
 // and hence all subsequent ones should be discarded from the same account.
 func (t *TransactionsByPriceAndNonce) Pop() {
+	delete(t.senders, t.heads[0])
 	heap.Pop(&t.heads)
 }
 
+// MinIncludedGasPrice simulates filling a block with the given gas limit from
+// the set and returns the lowest gas price of the included transactions, or
+// nil if none would be included. The gas limit of each transaction is
+// counted in full, and like the miner, an account is skipped once one of its
+// transactions does not fit. The set itself is left unchanged.
+func (t *TransactionsByPriceAndNonce) MinIncludedGasPrice(blockGasLimit uint64) *big.Int {
+	sim := &TransactionsByPriceAndNonce{
+		txs:     make(map[common.Address]Transactions, len(t.txs)),
+		heads:   append(TxByIndexAndPrice(nil), t.heads...),
+		senders: make(map[*Transaction]common.Address, len(t.senders)),
+		signer:  t.signer,
+
+		maxDeposits: t.maxDeposits,
+		deposits:    t.deposits,
+	}
+	for acc, accTxs := range t.txs {
+		sim.txs[acc] = accTxs
+	}
+	for tx, acc := range t.senders {
+		sim.senders[tx] = acc
+	}
+
+	var min *big.Int
+	gas := blockGasLimit
+	for tx := sim.Peek(); tx != nil && gas >= params.TxGas; tx = sim.Peek() {
+		if tx.Gas() > gas {
+			sim.Pop()
+			continue
+		}
+		gas -= tx.Gas()
+		if min == nil || tx.GasPrice().Cmp(min) < 0 {
+			min = tx.GasPrice()
+		}
+		if isDeposit(tx) {
+			sim.IncludeDeposit()
+		}
+		sim.Shift()
+	}
+	return min
+}
+
 // Message is a fully derived transaction and implements core.Message
 //
 // NOTE: In a future PR this will be removed.
@@ -539,6 +1473,28 @@ type Message struct {
 	gasPrice          *big.Int
 	data              []byte
 	checkNonce        bool
+	metadata          map[string]interface{}
+	originTxHash      common.Hash
+	l1Timestamp       uint64
+	txIndex           uint
+	lazyFrom          *lazySender
+}
+
+// lazySender recovers the sender of a message created by AsLazyMessage once,
+// when it is first needed.
+type lazySender struct {
+	once   sync.Once
+	signer Signer
+	tx     *Transaction
+	from   common.Address
+	err    error
+}
+
+func (l *lazySender) recover() (common.Address, error) {
+	l.once.Do(func() {
+		l.from, l.err = recoverMessageSender(l.signer, l.tx)
+	})
+	return l.from, l.err
 }
 
 func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, checkNonce bool, l1MessageSender *common.Address, l1BlockNumber *big.Int, queueOrigin QueueOrigin, signatureHashType SignatureHashType) Message {
@@ -558,7 +1514,30 @@ func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *b
 	}
 }
 
-func (m Message) From() common.Address                 { return m.from }
+// NewDepositMessage creates the message for an L1 to L2 deposit that was
+// read from an L1 event rather than decoded from a transaction. The
+// transactions built from enqueue events use the queue index as the nonce.
+// Deposits do not increment the nonce, so nonce checking is usually
+// disabled, but it can be enabled to enforce deterministic deposit nonces.
+func NewDepositMessage(l1Sender, to common.Address, data []byte, gasLimit uint64, nonce uint64, checkNonce bool) Message {
+	return NewMessage(common.Address{}, &to, nonce, new(big.Int), gasLimit, new(big.Int), data, checkNonce, &l1Sender, nil, QueueOriginL1ToL2, SighashEIP155)
+}
+
+func (m Message) From() common.Address {
+	from, _ := m.RecoverFrom()
+	return from
+}
+
+// RecoverFrom returns the sender of the message. For messages created by
+// AsLazyMessage the sender is recovered on the first call, and the error of
+// the recovery is returned.
+func (m Message) RecoverFrom() (common.Address, error) {
+	if m.lazyFrom != nil {
+		return m.lazyFrom.recover()
+	}
+	return m.from, nil
+}
+
 func (m Message) To() *common.Address                  { return m.to }
 func (m Message) L1MessageSender() *common.Address     { return m.l1MessageSender }
 func (m Message) L1BlockNumber() *big.Int              { return m.l1BlockNumber }
@@ -570,3 +1549,46 @@ func (m Message) Gas() uint64                          { return m.gasLimit }
 func (m Message) Nonce() uint64                        { return m.nonce }
 func (m Message) Data() []byte                         { return m.data }
 func (m Message) CheckNonce() bool                     { return m.checkNonce }
+
+// Metadata returns the opaque metadata attached to the message. It is not
+// used by the state transition and is preserved when the message is rewritten
+// for the OVM, so tracers can correlate it with the original transaction.
+func (m Message) Metadata() map[string]interface{} { return m.metadata }
+
+// WithMetadata returns a copy of the message with the given metadata.
+func (m Message) WithMetadata(metadata map[string]interface{}) Message {
+	m.metadata = metadata
+	return m
+}
+
+// OriginTxHash returns the hash of the transaction the message was created
+// from by AsMessage, or the zero hash if it was not created from one.
+func (m Message) OriginTxHash() common.Hash { return m.originTxHash }
+
+// WithOriginTxHash returns a copy of the message with the given originating
+// transaction hash.
+func (m Message) WithOriginTxHash(hash common.Hash) Message {
+	m.originTxHash = hash
+	return m
+}
+
+// L1Timestamp returns the timestamp assigned to the transaction the message
+// was created from, or zero if none was assigned.
+func (m Message) L1Timestamp() uint64 { return m.l1Timestamp }
+
+// WithL1Timestamp returns a copy of the message with the given timestamp.
+func (m Message) WithL1Timestamp(ts uint64) Message {
+	m.l1Timestamp = ts
+	return m
+}
+
+// TxIndex returns the position in the block of the transaction the message
+// was created from, as set by WithTxIndex.
+func (m Message) TxIndex() uint { return m.txIndex }
+
+// WithTxIndex returns a copy of the message with the given position in the
+// block.
+func (m Message) WithTxIndex(index uint) Message {
+	m.txIndex = index
+	return m
+}
@@ -0,0 +1,351 @@
+/**
+ * Optimism 2020 Copyright
+ */
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrInvalidCBOREnc is returned when decoding a transaction that is not in
+// the CBOR form produced by MarshalCBOR.
+var ErrInvalidCBOREnc = errors.New("invalid transaction cbor encoding")
+
+// CBOR major types used by the transaction encoding.
+const (
+	cborUint  byte = 0
+	cborBytes byte = 2
+	cborText  byte = 3
+	cborMap   byte = 5
+)
+
+// cborNull is the CBOR encoding of null.
+const cborNull byte = 0xf6
+
+// cborKeys are the keys of the CBOR map of a transaction, in the order they
+// are encoded.
+var cborKeys = []string{
+	"nonce", "gasPrice", "gas", "to", "value", "input", "v", "r", "s",
+	"l1BlockNumber", "l1Timestamp", "l1MessageSender", "signatureHashType",
+	"queueOrigin", "index", "queueIndex",
+}
+
+// MarshalCBOR returns the CBOR encoding of the transaction, including the
+// OVM metadata. The transaction is encoded as a map with the text keys of
+// cborKeys in a fixed order, so the encoding of a transaction is stable.
+// Integers are unsigned integers, big integers are big endian byte strings
+// and unset optional values are null.
+func (tx *Transaction) MarshalCBOR() ([]byte, error) {
+	w := new(cborWriter)
+	w.head(cborMap, uint64(len(cborKeys)))
+	for _, key := range cborKeys {
+		w.head(cborText, uint64(len(key)))
+		w.buf.WriteString(key)
+		var err error
+		switch key {
+		case "nonce":
+			w.head(cborUint, tx.data.AccountNonce)
+		case "gasPrice":
+			err = w.bigInt(tx.data.Price)
+		case "gas":
+			w.head(cborUint, tx.data.GasLimit)
+		case "to":
+			w.address(tx.data.Recipient)
+		case "value":
+			err = w.bigInt(tx.data.Amount)
+		case "input":
+			w.bytes(tx.data.Payload)
+		case "v":
+			err = w.bigInt(tx.data.V)
+		case "r":
+			err = w.bigInt(tx.data.R)
+		case "s":
+			err = w.bigInt(tx.data.S)
+		case "l1BlockNumber":
+			err = w.bigInt(tx.meta.L1BlockNumber)
+		case "l1Timestamp":
+			w.head(cborUint, tx.meta.L1Timestamp)
+		case "l1MessageSender":
+			w.address(tx.meta.L1MessageSender)
+		case "signatureHashType":
+			w.head(cborUint, uint64(tx.meta.SignatureHashType))
+		case "queueOrigin":
+			err = w.bigInt(tx.meta.QueueOrigin)
+		case "index":
+			w.optUint(tx.meta.Index)
+		case "queueIndex":
+			w.optUint(tx.meta.QueueIndex)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode %s: %w", key, err)
+		}
+	}
+	return w.buf.Bytes(), nil
+}
+
+// UnmarshalCBOR decodes a transaction encoded by MarshalCBOR. The keys must
+// appear in the order MarshalCBOR writes them.
+func (tx *Transaction) UnmarshalCBOR(b []byte) error {
+	r := &cborReader{data: b}
+	n, err := r.expect(cborMap)
+	if err != nil {
+		return err
+	}
+	if n != uint64(len(cborKeys)) {
+		return fmt.Errorf("%w: %d fields, want %d", ErrInvalidCBOREnc, n, len(cborKeys))
+	}
+	var (
+		data txdata
+		meta TransactionMeta
+	)
+	for _, key := range cborKeys {
+		have, err := r.text()
+		if err != nil {
+			return err
+		}
+		if have != key {
+			return fmt.Errorf("%w: key %q, want %q", ErrInvalidCBOREnc, have, key)
+		}
+		switch key {
+		case "nonce":
+			data.AccountNonce, err = r.expect(cborUint)
+		case "gasPrice":
+			data.Price, err = r.bigInt()
+		case "gas":
+			data.GasLimit, err = r.expect(cborUint)
+		case "to":
+			data.Recipient, err = r.address()
+		case "value":
+			data.Amount, err = r.bigInt()
+		case "input":
+			data.Payload, err = r.bytes()
+		case "v":
+			data.V, err = r.bigInt()
+		case "r":
+			data.R, err = r.bigInt()
+		case "s":
+			data.S, err = r.bigInt()
+		case "l1BlockNumber":
+			meta.L1BlockNumber, err = r.bigInt()
+		case "l1Timestamp":
+			meta.L1Timestamp, err = r.expect(cborUint)
+		case "l1MessageSender":
+			meta.L1MessageSender, err = r.address()
+		case "signatureHashType":
+			var sighashType uint64
+			sighashType, err = r.expect(cborUint)
+			if err == nil && sighashType > math.MaxUint8 {
+				err = fmt.Errorf("%w: signature hash type %d", ErrInvalidCBOREnc, sighashType)
+			}
+			meta.SignatureHashType = SignatureHashType(sighashType)
+		case "queueOrigin":
+			meta.QueueOrigin, err = r.bigInt()
+		case "index":
+			meta.Index, err = r.optUint()
+		case "queueIndex":
+			meta.QueueIndex, err = r.optUint()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if r.pos != len(r.data) {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidCBOREnc, len(r.data)-r.pos)
+	}
+	// The RLP decoder never leaves these unset, so neither does the CBOR one.
+	for _, v := range []**big.Int{&data.Price, &data.Amount, &data.V, &data.R, &data.S} {
+		if *v == nil {
+			return fmt.Errorf("%w: missing required value", ErrInvalidCBOREnc)
+		}
+	}
+	if data.Payload == nil {
+		data.Payload = []byte{}
+	}
+	tx.data = data
+	tx.meta = meta
+	return nil
+}
+
+// cborWriter writes the subset of CBOR used by the transaction encoding.
+type cborWriter struct {
+	buf bytes.Buffer
+}
+
+// head writes the initial byte of a data item with the given major type and
+// argument, followed by the argument if it does not fit in the initial byte.
+func (w *cborWriter) head(major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < 24:
+		w.buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		w.buf.Write([]byte{major | 24, byte(arg)})
+	case arg <= math.MaxUint16:
+		w.buf.WriteByte(major | 25)
+		binary.Write(&w.buf, binary.BigEndian, uint16(arg))
+	case arg <= math.MaxUint32:
+		w.buf.WriteByte(major | 26)
+		binary.Write(&w.buf, binary.BigEndian, uint32(arg))
+	default:
+		w.buf.WriteByte(major | 27)
+		binary.Write(&w.buf, binary.BigEndian, arg)
+	}
+}
+
+func (w *cborWriter) bytes(b []byte) {
+	w.head(cborBytes, uint64(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *cborWriter) bigInt(v *big.Int) error {
+	if v == nil {
+		w.buf.WriteByte(cborNull)
+		return nil
+	}
+	if v.Sign() < 0 {
+		return errors.New("negative value")
+	}
+	w.bytes(v.Bytes())
+	return nil
+}
+
+func (w *cborWriter) address(addr *common.Address) {
+	if addr == nil {
+		w.buf.WriteByte(cborNull)
+		return
+	}
+	w.bytes(addr.Bytes())
+}
+
+func (w *cborWriter) optUint(v *uint64) {
+	if v == nil {
+		w.buf.WriteByte(cborNull)
+		return
+	}
+	w.head(cborUint, *v)
+}
+
+// cborReader reads the subset of CBOR written by cborWriter.
+type cborReader struct {
+	data []byte
+	pos  int
+}
+
+// null consumes a null data item and reports whether there was one.
+func (r *cborReader) null() bool {
+	if r.pos < len(r.data) && r.data[r.pos] == cborNull {
+		r.pos++
+		return true
+	}
+	return false
+}
+
+// head reads the initial byte and argument of a data item.
+func (r *cborReader) head() (byte, uint64, error) {
+	if r.pos >= len(r.data) {
+		return 0, 0, fmt.Errorf("%w: unexpected end of input", ErrInvalidCBOREnc)
+	}
+	major, info := r.data[r.pos]>>5, r.data[r.pos]&0x1f
+	r.pos++
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	if info > 27 {
+		return 0, 0, fmt.Errorf("%w: unsupported additional information %d", ErrInvalidCBOREnc, info)
+	}
+	size := 1 << (info - 24)
+	if len(r.data)-r.pos < size {
+		return 0, 0, fmt.Errorf("%w: unexpected end of input", ErrInvalidCBOREnc)
+	}
+	var arg uint64
+	for _, b := range r.data[r.pos : r.pos+size] {
+		arg = arg<<8 | uint64(b)
+	}
+	r.pos += size
+	return major, arg, nil
+}
+
+// expect reads the head of a data item of the given major type and returns
+// its argument.
+func (r *cborReader) expect(major byte) (uint64, error) {
+	have, arg, err := r.head()
+	if err != nil {
+		return 0, err
+	}
+	if have != major {
+		return 0, fmt.Errorf("%w: major type %d, want %d", ErrInvalidCBOREnc, have, major)
+	}
+	return arg, nil
+}
+
+// payload reads a byte or text string of the given major type.
+func (r *cborReader) payload(major byte) ([]byte, error) {
+	n, err := r.expect(major)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, fmt.Errorf("%w: unexpected end of input", ErrInvalidCBOREnc)
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *cborReader) text() (string, error) {
+	b, err := r.payload(cborText)
+	return string(b), err
+}
+
+func (r *cborReader) bytes() ([]byte, error) {
+	b, err := r.payload(cborBytes)
+	if err != nil {
+		return nil, err
+	}
+	return common.CopyBytes(b), nil
+}
+
+func (r *cborReader) bigInt() (*big.Int, error) {
+	if r.null() {
+		return nil, nil
+	}
+	b, err := r.payload(cborBytes)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (r *cborReader) address() (*common.Address, error) {
+	if r.null() {
+		return nil, nil
+	}
+	b, err := r.payload(cborBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != common.AddressLength {
+		return nil, fmt.Errorf("%w: address of %d bytes", ErrInvalidCBOREnc, len(b))
+	}
+	addr := common.BytesToAddress(b)
+	return &addr, nil
+}
+
+func (r *cborReader) optUint() (*uint64, error) {
+	if r.null() {
+		return nil, nil
+	}
+	v, err := r.expect(cborUint)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
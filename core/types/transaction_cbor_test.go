@@ -0,0 +1,83 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTransactionCBORRoundTrip(t *testing.T) {
+	index, queueIndex := uint64(7), uint64(3)
+	deposit := NewTransaction(3, common.HexToAddress("b94f5374fce5edbc8e2a8697c15331677e6ebf0b"), big.NewInt(0), 2000, big.NewInt(0), common.FromHex("5544"), &sender, big.NewInt(12), QueueOriginL1ToL2, SighashEIP155)
+	deposit.meta.L1Timestamp = 1600000000
+	deposit.meta.Index = &index
+	deposit.meta.QueueIndex = &queueIndex
+	creation := NewContractCreation(1, big.NewInt(1), 100000, big.NewInt(1), common.FromHex("6000"), nil, nil, QueueOriginSequencer)
+
+	txs := []*Transaction{
+		emptyTx,
+		emptyTxEmptyL1Sender,
+		rightvrsTx,
+		rightvrsTxWithL1Sender,
+		rightvrsTxWithL1BlockNumber,
+		emptyTxSighashEthSign,
+		deposit,
+		creation,
+	}
+	for i, tx := range txs {
+		enc, err := tx.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("tx %d: encode failed: %v", i, err)
+		}
+		var dec Transaction
+		if err := dec.UnmarshalCBOR(enc); err != nil {
+			t.Fatalf("tx %d: decode failed: %v", i, err)
+		}
+		if dec.Hash() != tx.Hash() {
+			t.Errorf("tx %d: hash mismatch: have %x, want %x", i, dec.Hash(), tx.Hash())
+		}
+		if !reflect.DeepEqual(dec.meta, tx.meta) {
+			t.Errorf("tx %d: meta mismatch: have %+v, want %+v", i, dec.meta, tx.meta)
+		}
+		// The encoding is stable
+		again, err := dec.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("tx %d: re-encode failed: %v", i, err)
+		}
+		if !bytes.Equal(again, enc) {
+			t.Errorf("tx %d: re-encoding mismatch: have %x, want %x", i, again, enc)
+		}
+		// The encoding is distinct from RLP
+		if bin, _ := tx.MarshalBinary(); bytes.Equal(bin, enc) {
+			t.Errorf("tx %d: cbor encoding equals the binary encoding", i)
+		}
+	}
+}
+
+func TestTransactionCBORInvalid(t *testing.T) {
+	enc, err := rightvrsTx.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The encoding is a map of 16 entries starting with the "nonce" key
+	if !bytes.HasPrefix(enc, append([]byte{0xb0, 0x65}, "nonce"...)) {
+		t.Fatalf("unexpected encoding prefix %x", enc[:7])
+	}
+
+	tests := [][]byte{
+		nil,
+		enc[:len(enc)-1],
+		append(append([]byte{}, enc...), 0x00),
+		append([]byte{0xaf}, enc[1:]...),
+	}
+	for i, test := range tests {
+		var dec Transaction
+		if err := dec.UnmarshalCBOR(test); !errors.Is(err, ErrInvalidCBOREnc) {
+			t.Errorf("test %d: expected %v, got %v", i, ErrInvalidCBOREnc, err)
+		}
+	}
+}
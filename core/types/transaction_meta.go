@@ -7,6 +7,7 @@ package types
 import (
 	"bytes"
 	"encoding/binary"
+	"io"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -31,7 +32,10 @@ type TransactionMeta struct {
 	// The canonical transaction chain index
 	Index *uint64 `json:"index" gencodec:"required"`
 	// The queue index, nil for queue origin sequencer transactions
-	QueueIndex *uint64 `json:"queueIndex" gencodec:"required"`
+	QueueIndex *uint64 `json:"queueIndex,omitempty"`
+	// Free marks a transaction that is intentionally submitted with a zero
+	// gas price, such as a system transaction
+	Free bool `json:"free,omitempty"`
 }
 
 // NewTransactionMeta creates a TransactionMeta
@@ -51,7 +55,12 @@ func NewTransactionMeta(l1BlockNumber *big.Int, l1timestamp uint64, l1MessageSen
 //   varbytes(L1BlockNumber) ||
 //   varbytes(L1MessageSender) ||
 //   varbytes(QueueOrigin) ||
-//   varbytes(L1Timestamp)
+//   varbytes(L1Timestamp) ||
+//   varbytes(Index) ||
+//   varbytes(QueueIndex) ||
+//   varbytes(Free)
+// Free is optional so that metadata encoded before it was added can still
+// be decoded.
 func TxMetaDecode(input []byte) (*TransactionMeta, error) {
 	var err error
 	meta := TransactionMeta{}
@@ -119,6 +128,12 @@ func TxMetaDecode(input []byte) (*TransactionMeta, error) {
 		meta.QueueIndex = &queueIndex
 	}
 
+	f, err := common.ReadVarBytes(b, 0, 1024, "Free")
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	meta.Free = len(f) == 1 && f[0] == 1
+
 	return &meta, nil
 }
 
@@ -179,6 +194,12 @@ func TxMetaEncode(meta *TransactionMeta) []byte {
 		common.WriteVarBytes(b, 0, qi.Bytes())
 	}
 
+	if meta.Free {
+		common.WriteVarBytes(b, 0, []byte{1})
+	} else {
+		common.WriteVarBytes(b, 0, []byte{0})
+	}
+
 	return b.Bytes()
 }
 
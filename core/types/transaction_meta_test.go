@@ -2,6 +2,7 @@ package types
 
 import (
 	"bytes"
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
@@ -112,6 +113,99 @@ func TestTransactionSighashEncode(t *testing.T) {
 	}
 }
 
+func TestTransactionMetaQueueIndex(t *testing.T) {
+	tx := NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil, &addr, l1BlockNumber, QueueOriginL1ToL2, SighashEIP155)
+	if tx.QueueIndex() != nil {
+		t.Fatal("expected nil queue index")
+	}
+	hash := tx.Hash()
+
+	tx.SetQueueIndex(7)
+	if tx.QueueIndex() == nil || *tx.QueueIndex() != 7 {
+		t.Fatalf("expected queue index 7, got %v", tx.QueueIndex())
+	}
+	if have := rlpHash(tx); have != hash {
+		t.Fatalf("queue index changed the hash: have %s, want %s", have.Hex(), hash.Hex())
+	}
+
+	decoded, err := TxMetaDecode(TxMetaEncode(tx.GetMeta()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.QueueIndex == nil || *decoded.QueueIndex != 7 {
+		t.Fatalf("queue index encoding mismatch: got %v", decoded.QueueIndex)
+	}
+
+	index := uint64(3)
+	tx.GetMeta().Index = &index
+	enc, err := json.Marshal(tx.GetMeta())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta TransactionMeta
+	if err := json.Unmarshal(enc, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.QueueIndex == nil || *meta.QueueIndex != 7 {
+		t.Fatalf("queue index json mismatch: got %v", meta.QueueIndex)
+	}
+
+	// The queue index is omitted from the JSON encoding when it is nil.
+	meta.QueueIndex = nil
+	enc, err = json.Marshal(&meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(enc, []byte("queueIndex")) {
+		t.Fatalf("expected queueIndex to be omitted, got %s", enc)
+	}
+}
+
+func TestTransactionMetaFree(t *testing.T) {
+	tx := NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	tx.SetFree(true)
+
+	encoded := TxMetaEncode(tx.GetMeta())
+	decoded, err := TxMetaDecode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Free {
+		t.Fatal("expected the free flag to be persisted")
+	}
+	restored := NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	restored.SetTransactionMeta(decoded)
+	if !restored.IsFree() {
+		t.Fatal("expected the restored transaction to be free")
+	}
+
+	// Metadata encoded before the flag was added decodes as not free.
+	tx.SetFree(false)
+	encoded = TxMetaEncode(tx.GetMeta())
+	decoded, err = TxMetaDecode(encoded[:len(encoded)-2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Free {
+		t.Fatal("expected legacy metadata to decode as not free")
+	}
+
+	tx.SetFree(true)
+	tx.GetMeta().L1MessageSender = &addr
+	tx.SetIndex(3)
+	enc, err := json.Marshal(tx.GetMeta())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta TransactionMeta
+	if err := json.Unmarshal(enc, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.Free {
+		t.Fatalf("free json mismatch: got %s", enc)
+	}
+}
+
 func isTxMetaEqual(meta1 *TransactionMeta, meta2 *TransactionMeta) bool {
 	// Maybe can just return this
 	if !reflect.DeepEqual(meta1, meta2) {
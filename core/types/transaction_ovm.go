@@ -0,0 +1,626 @@
+/**
+ * Optimism 2020 Copyright
+ */
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// ErrGasPriceOutOfRange is returned when a gas price does not fit in the
+	// compressed sequencer transaction encoding.
+	ErrGasPriceOutOfRange = errors.New("gas price out of range for compressed encoding")
+
+	// ErrDepositNonzeroValue is returned when an L1 to L2 deposit carries a
+	// value. Deposits move value through the bridge, not the L2 transaction.
+	ErrDepositNonzeroValue = errors.New("l1 to l2 deposit with nonzero value")
+
+	// ErrNotDeposit is returned when a deposit-only operation is applied to
+	// a transaction that is not an L1 to L2 deposit.
+	ErrNotDeposit = errors.New("transaction is not an l1 to l2 deposit")
+
+	// ErrNoL1MessageSender is returned when an L1 to L2 deposit has no L1
+	// message sender.
+	ErrNoL1MessageSender = errors.New("l1 to l2 deposit without l1 message sender")
+
+	// ErrNoQueueIndex is returned when an L1 to L2 deposit has no queue
+	// index.
+	ErrNoQueueIndex = errors.New("l1 to l2 deposit without queue index")
+
+	// ErrUnexpectedRollupTxId is returned when a sequencer transaction has a
+	// queue index. Queue indices identify L1 to L2 messages in the L1 queue
+	// and are never assigned to sequencer transactions.
+	ErrUnexpectedRollupTxId = errors.New("sequencer transaction with queue index")
+
+	// ErrUnexpectedL1MessageSender is returned when a sequencer transaction
+	// is constructed with an L1 message sender. Only L1 to L2 deposits are
+	// sent from L1.
+	ErrUnexpectedL1MessageSender = errors.New("sequencer transaction with l1 message sender")
+
+	// ErrQueueOriginOutOfRange is returned when a queue origin is not one of
+	// the defined queue origins.
+	ErrQueueOriginOutOfRange = errors.New("queue origin out of range")
+
+	// ErrQueueOriginLength is returned when an encoded queue origin is not
+	// QueueOriginWidth bytes long.
+	ErrQueueOriginLength = errors.New("invalid encoded queue origin length")
+
+	// ErrNoEvictionCandidate is returned when there is no sequencer
+	// transaction that can be evicted, because only deposits remain.
+	ErrNoEvictionCandidate = errors.New("no sequencer transaction to evict")
+
+	// ErrSighashNotAllowed is returned when a transaction uses a signature
+	// hash type that is disabled at the current fork.
+	ErrSighashNotAllowed = errors.New("signature hash type not allowed")
+
+	// ErrRollupMetadataMismatch is returned when rollup metadata is applied
+	// to a transaction other than the one it was recorded for.
+	ErrRollupMetadataMismatch = errors.New("rollup metadata of another transaction")
+
+	// ErrInvalidRollupMetadata is returned when decoding a malformed rollup
+	// metadata record.
+	ErrInvalidRollupMetadata = errors.New("invalid rollup metadata")
+
+	// ErrGasOverflow is returned when a sum of gas limits does not fit in a
+	// uint64.
+	ErrGasOverflow = errors.New("gas overflow")
+
+	// ErrDepositDataTooLarge is returned when the calldata of an L1 to L2
+	// deposit exceeds the configured maximum size.
+	ErrDepositDataTooLarge = errors.New("l1 to l2 deposit data too large")
+
+	// ErrQueueOrder is returned when deposits are not ordered by their L1
+	// queue index.
+	ErrQueueOrder = errors.New("deposits out of queue order")
+
+	// ErrFutureDepositTimestamp is returned when an L1 to L2 deposit has an
+	// L1 timestamp too far ahead of the L2 context time.
+	ErrFutureDepositTimestamp = errors.New("l1 to l2 deposit timestamp in the future")
+
+	// ErrInvalidDepositTarget is returned when an L1 to L2 deposit targets
+	// an address that is not a registered bridge.
+	ErrInvalidDepositTarget = errors.New("l1 to l2 deposit to unregistered target")
+)
+
+// GasPriceScalar is the default divisor applied to gas prices before they
+// are written to the compressed sequencer transaction encoding. It must
+// match the divisor used by the sequencer entrypoint contract; chains whose
+// decompressor uses another divisor configure it in their decompressor forks.
+const GasPriceScalar = 1000000
+
+// maxEncodedGasPrice is the largest value that fits in the 3 byte gas price
+// field of the compressed sequencer transaction encoding.
+const maxEncodedGasPrice = 1<<24 - 1
+
+// EncodeGasPrice scales the gas price down by GasPriceScalar so that it can
+// be written to the 3 byte gas price field of the compressed sequencer
+// transaction encoding. Any remainder is truncated.
+func EncodeGasPrice(price *big.Int) (uint32, error) {
+	return EncodeGasPriceWithScalar(price, GasPriceScalar)
+}
+
+// EncodeGasPriceWithScalar is EncodeGasPrice with the given divisor instead
+// of GasPriceScalar.
+func EncodeGasPriceWithScalar(price *big.Int, scalar uint64) (uint32, error) {
+	if price.Sign() < 0 || scalar == 0 {
+		return 0, ErrGasPriceOutOfRange
+	}
+	scaled := new(big.Int).Div(price, new(big.Int).SetUint64(scalar))
+	if !scaled.IsUint64() || scaled.Uint64() > maxEncodedGasPrice {
+		return 0, ErrGasPriceOutOfRange
+	}
+	return uint32(scaled.Uint64()), nil
+}
+
+// DecodeGasPrice reverses EncodeGasPrice, scaling the encoded gas price back
+// up by GasPriceScalar.
+func DecodeGasPrice(encoded uint32) *big.Int {
+	return DecodeGasPriceWithScalar(encoded, GasPriceScalar)
+}
+
+// DecodeGasPriceWithScalar reverses EncodeGasPriceWithScalar.
+func DecodeGasPriceWithScalar(encoded uint32, scalar uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(uint64(encoded)), new(big.Int).SetUint64(scalar))
+}
+
+// GasPriceRepresentable reports whether the gas price survives the
+// compressed sequencer transaction encoding unchanged, which requires it to
+// be in range and a multiple of GasPriceScalar.
+func GasPriceRepresentable(price *big.Int) bool {
+	return GasPriceRepresentableWithScalar(price, GasPriceScalar)
+}
+
+// GasPriceRepresentableWithScalar is GasPriceRepresentable with the given
+// divisor instead of GasPriceScalar.
+func GasPriceRepresentableWithScalar(price *big.Int, scalar uint64) bool {
+	encoded, err := EncodeGasPriceWithScalar(price, scalar)
+	return err == nil && DecodeGasPriceWithScalar(encoded, scalar).Cmp(price) == 0
+}
+
+// EffectiveOVMGasPrice returns the gas price a sequencer transaction is
+// executed with after its gas price went through the compressed sequencer
+// transaction encoding, which truncates it to a multiple of GasPriceScalar.
+// Deposits are not compressed and keep their gas price. It returns nil if
+// the gas price cannot be encoded.
+func (tx *Transaction) EffectiveOVMGasPrice() *big.Int {
+	if isDeposit(tx) {
+		return tx.GasPrice()
+	}
+	encoded, err := EncodeGasPrice(tx.data.Price)
+	if err != nil {
+		return nil
+	}
+	return DecodeGasPrice(encoded)
+}
+
+// NormalizeGasPriceToBucket returns the representable gas price nearest to
+// price, see GasPriceRepresentable. Prices halfway between two multiples of
+// GasPriceScalar are rounded up. Prices out of range are clamped to the
+// smallest or largest representable gas price.
+func NormalizeGasPriceToBucket(price *big.Int) *big.Int {
+	if price.Sign() <= 0 {
+		return new(big.Int)
+	}
+	half := big.NewInt(GasPriceScalar / 2)
+	scaled := new(big.Int).Add(price, half)
+	scaled.Div(scaled, big.NewInt(GasPriceScalar))
+	if !scaled.IsUint64() || scaled.Uint64() > maxEncodedGasPrice {
+		return DecodeGasPrice(maxEncodedGasPrice)
+	}
+	return DecodeGasPrice(uint32(scaled.Uint64()))
+}
+
+// GasPriceBucketCount returns the number of distinct gas prices that the 3
+// byte gas price field of the compressed sequencer transaction encoding can
+// carry. Sequencer transactions are executed at one of these discrete
+// prices, see GasPriceForBucket.
+func GasPriceBucketCount() int {
+	return maxEncodedGasPrice + 1
+}
+
+// GasPriceForBucket returns the gas price of the bucket with the given
+// encoded value, which is the bucket index times GasPriceScalar. It returns
+// nil for a bucket at or above GasPriceBucketCount.
+func GasPriceForBucket(b uint32) *big.Int {
+	if uint64(b) >= uint64(GasPriceBucketCount()) {
+		return nil
+	}
+	return DecodeGasPrice(b)
+}
+
+// QueueOriginWidth is the number of bytes of an encoded queue origin. It
+// matches the uint8 l1QueueOrigin field of the execution manager transaction.
+const QueueOriginWidth = 1
+
+// EncodeQueueOrigin encodes a queue origin in QueueOriginWidth bytes.
+func EncodeQueueOrigin(qo QueueOrigin) ([]byte, error) {
+	if qo != QueueOriginSequencer && qo != QueueOriginL1ToL2 {
+		return nil, ErrQueueOriginOutOfRange
+	}
+	b := make([]byte, QueueOriginWidth)
+	b[QueueOriginWidth-1] = byte(qo)
+	return b, nil
+}
+
+// DecodeQueueOrigin reverses EncodeQueueOrigin.
+func DecodeQueueOrigin(b []byte) (QueueOrigin, error) {
+	if len(b) != QueueOriginWidth {
+		return 0, ErrQueueOriginLength
+	}
+	qo := QueueOrigin(b[QueueOriginWidth-1])
+	if qo != QueueOriginSequencer && qo != QueueOriginL1ToL2 {
+		return 0, ErrQueueOriginOutOfRange
+	}
+	return qo, nil
+}
+
+// ValidateOVMTransaction checks that a transaction is well formed under the
+// rules of the OVM that are not enforced by the transaction encoding itself.
+func ValidateOVMTransaction(tx *Transaction) error {
+	qo := tx.QueueOrigin()
+	if qo != nil && qo.Uint64() == uint64(QueueOriginL1ToL2) {
+		if tx.data.Amount != nil && tx.data.Amount.Sign() != 0 {
+			return ErrDepositNonzeroValue
+		}
+	} else if tx.meta.QueueIndex != nil {
+		return ErrUnexpectedRollupTxId
+	}
+	return nil
+}
+
+// NewTransactionValidated creates a new transaction like NewTransaction, but
+// rejects incoherent OVM fields instead of returning a transaction that fails
+// ValidateOVMTransaction later on. A nil to creates a contract. Sequencer
+// transactions must not carry an L1 message sender and deposits must.
+func NewTransactionValidated(nonce uint64, to *common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, l1MessageSender *common.Address, l1BlockNumber *big.Int, queueOrigin QueueOrigin, sighashType SignatureHashType) (*Transaction, error) {
+	switch queueOrigin {
+	case QueueOriginSequencer:
+		if l1MessageSender != nil {
+			return nil, ErrUnexpectedL1MessageSender
+		}
+	case QueueOriginL1ToL2:
+		if l1MessageSender == nil {
+			return nil, ErrNoL1MessageSender
+		}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrQueueOriginOutOfRange, queueOrigin)
+	}
+	tx := newTransaction(nonce, to, amount, gasLimit, gasPrice, data, l1MessageSender, l1BlockNumber, queueOrigin, sighashType)
+	if err := ValidateOVMTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// DepositSender returns the L1 message sender of an L1 to L2 deposit.
+// Deposits are not signed, so unlike Sender no signer is needed.
+func DepositSender(tx *Transaction) (common.Address, error) {
+	qo := tx.QueueOrigin()
+	if qo == nil || qo.Uint64() != uint64(QueueOriginL1ToL2) {
+		return common.Address{}, ErrNotDeposit
+	}
+	sender := tx.L1MessageSender()
+	if sender == nil {
+		return common.Address{}, ErrNoL1MessageSender
+	}
+	return *sender, nil
+}
+
+// DepositProvenance returns a short description of where an L1 to L2 deposit
+// came from, "L1 tx <queue index> from <l1 sender> to <target>", for cross
+// domain references in logs. It returns ErrNotDeposit for sequencer
+// transactions.
+func (tx *Transaction) DepositProvenance() (string, error) {
+	sender, err := DepositSender(tx)
+	if err != nil {
+		return "", err
+	}
+	if tx.meta.QueueIndex == nil {
+		return "", ErrNoQueueIndex
+	}
+	target := "contract creation"
+	if to := tx.To(); to != nil {
+		target = to.Hex()
+	}
+	return fmt.Sprintf("L1 tx %d from %s to %s", *tx.meta.QueueIndex, sender.Hex(), target), nil
+}
+
+// L1DataGas returns the L1 calldata gas of the RLP encoding of the
+// transaction, which is what posting the transaction to L1 costs.
+func (tx *Transaction) L1DataGas() uint64 {
+	enc, _ := rlp.EncodeToBytes(tx)
+	var gas uint64
+	for _, b := range enc {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+	return gas
+}
+
+// EstimatedVsLimitRatio returns the ratio of the gas limit of the transaction
+// to the gas that estimator expects it to use. A deposit with a large ratio
+// reserves far more block gas than it needs, so builders can deprioritize
+// it. A zero estimate gives an infinite ratio, unless the gas limit is zero
+// as well.
+func (tx *Transaction) EstimatedVsLimitRatio(estimator func(*Transaction) uint64) float64 {
+	estimate := estimator(tx)
+	if estimate == 0 {
+		if tx.data.GasLimit == 0 {
+			return 1
+		}
+		return math.Inf(1)
+	}
+	return float64(tx.data.GasLimit) / float64(estimate)
+}
+
+// L1DataFee returns the fee for posting the transaction to L1 at the given
+// L1 base fee.
+func (tx *Transaction) L1DataFee(baseFee *big.Int) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(tx.L1DataGas()), baseFee)
+}
+
+// TotalL1DataFee returns the sum of the L1 data fees of the transactions at
+// the given L1 base fee. Deposits are skipped, as they originate on L1 and
+// do not pay an L1 data fee on L2.
+func (s Transactions) TotalL1DataFee(baseFee *big.Int) *big.Int {
+	total := new(big.Int)
+	for _, tx := range s {
+		if isDeposit(tx) {
+			continue
+		}
+		total.Add(total, tx.L1DataFee(baseFee))
+	}
+	return total
+}
+
+// BlockFeeRevenue returns the L2 fees collected from the transactions of a
+// block and the L1 data fees the sequencer pays to post them at the given L1
+// base fee. The L2 fee of a transaction is its gas limit at its effective
+// gas price, see EffectiveOVMGasPrice. Deposits are paid for on L1 and
+// contribute to neither. It returns ErrGasPriceOutOfRange if the gas price
+// of a sequencer transaction cannot be encoded.
+func BlockFeeRevenue(txs Transactions, baseFee *big.Int) (l2Revenue, l1Cost *big.Int, err error) {
+	l2Revenue = new(big.Int)
+	for i, tx := range txs {
+		if isDeposit(tx) {
+			continue
+		}
+		price := tx.EffectiveOVMGasPrice()
+		if price == nil {
+			return nil, nil, fmt.Errorf("transaction %d: %w", i, ErrGasPriceOutOfRange)
+		}
+		l2Revenue.Add(l2Revenue, new(big.Int).Mul(new(big.Int).SetUint64(tx.data.GasLimit), price))
+	}
+	return l2Revenue, txs.TotalL1DataFee(baseFee), nil
+}
+
+// WorstCaseGas returns the sum of the gas limits of the deposits, which is
+// the gas to reserve in a block to execute all of them. It returns
+// ErrNotDeposit if a transaction is not a deposit and ErrGasOverflow if the
+// sum does not fit in a uint64.
+func (deposits Transactions) WorstCaseGas() (uint64, error) {
+	var total uint64
+	for i, tx := range deposits {
+		if !isDeposit(tx) {
+			return 0, fmt.Errorf("transaction %d: %w", i, ErrNotDeposit)
+		}
+		if total > math.MaxUint64-tx.data.GasLimit {
+			return 0, fmt.Errorf("transaction %d: %w", i, ErrGasOverflow)
+		}
+		total += tx.data.GasLimit
+	}
+	return total, nil
+}
+
+// ValidateQueueOrder returns ErrQueueOrder if the deposits are not in
+// strictly increasing order of their queue index, which is the order they
+// were enqueued in on L1 and must be executed in. The error identifies the
+// first pair out of order. It returns ErrNotDeposit if a transaction is not a
+// deposit and ErrNoQueueIndex if a deposit has no queue index.
+func (deposits Transactions) ValidateQueueOrder() error {
+	var prev *uint64
+	for i, tx := range deposits {
+		if !isDeposit(tx) {
+			return fmt.Errorf("transaction %d: %w", i, ErrNotDeposit)
+		}
+		queueIndex := tx.meta.QueueIndex
+		if queueIndex == nil {
+			return fmt.Errorf("transaction %d: %w", i, ErrNoQueueIndex)
+		}
+		if prev != nil && *queueIndex <= *prev {
+			return fmt.Errorf("%w: transaction %d has queue index %d after %d", ErrQueueOrder, i, *queueIndex, *prev)
+		}
+		prev = queueIndex
+	}
+	return nil
+}
+
+// EvictionCandidate returns the sequencer transaction that should be evicted
+// first when the pool is full, which is the one with the lowest gas price.
+// Of transactions with the same gas price, the last one in the slice is
+// returned, which is the latest nonce in slices ordered by nonce. Deposits
+// are never returned, since they cannot be queued again once dropped. It
+// returns ErrNoEvictionCandidate if there are no sequencer transactions.
+func EvictionCandidate(txs Transactions) (*Transaction, error) {
+	var candidate *Transaction
+	for _, tx := range txs {
+		if isDeposit(tx) {
+			continue
+		}
+		if candidate == nil || tx.data.Price.Cmp(candidate.data.Price) <= 0 {
+			candidate = tx
+		}
+	}
+	if candidate == nil {
+		return nil, ErrNoEvictionCandidate
+	}
+	return candidate, nil
+}
+
+// ValidateSighashForFork returns ErrSighashNotAllowed if the signature hash
+// type of the transaction is not in the allowed set of the current fork. A
+// nil set allows every type. Deposits are not signed and are not checked.
+func ValidateSighashForFork(tx *Transaction, allowed map[SignatureHashType]bool) error {
+	if allowed == nil || isDeposit(tx) {
+		return nil
+	}
+	if !allowed[tx.meta.SignatureHashType] {
+		return fmt.Errorf("%w: %s", ErrSighashNotAllowed, tx.meta.SignatureHashType)
+	}
+	return nil
+}
+
+// ValidateDepositDataSize returns ErrDepositDataTooLarge if the transaction is
+// an L1 to L2 deposit with more than maxBytes of calldata. Sequencer
+// transactions are not checked.
+func ValidateDepositDataSize(tx *Transaction, maxBytes int) error {
+	if !isDeposit(tx) {
+		return nil
+	}
+	if size := len(tx.data.Payload); size > maxBytes {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrDepositDataTooLarge, size, maxBytes)
+	}
+	return nil
+}
+
+// ValidateDepositTimestamp returns ErrFutureDepositTimestamp if the
+// transaction is an L1 to L2 deposit whose L1 timestamp is more than
+// tolerance seconds ahead of the L2 context time. Sequencer transactions are
+// not checked.
+func ValidateDepositTimestamp(tx *Transaction, ctxTime *big.Int, tolerance uint64) error {
+	if !isDeposit(tx) {
+		return nil
+	}
+	limit := new(big.Int).Add(ctxTime, new(big.Int).SetUint64(tolerance))
+	if timestamp := new(big.Int).SetUint64(tx.meta.L1Timestamp); timestamp.Cmp(limit) > 0 {
+		return fmt.Errorf("%w: %d, context time %d", ErrFutureDepositTimestamp, timestamp, ctxTime)
+	}
+	return nil
+}
+
+// ValidateDepositTarget returns ErrInvalidDepositTarget if the transaction is
+// an L1 to L2 deposit whose target is not in the allowed set, which holds the
+// L2 cross domain messenger and the registered bridges. Deposits that create
+// a contract have no target and are rejected as well. Sequencer transactions
+// are not checked.
+func ValidateDepositTarget(tx *Transaction, allowed map[common.Address]bool) error {
+	if !isDeposit(tx) {
+		return nil
+	}
+	to := tx.data.Recipient
+	if to == nil {
+		return fmt.Errorf("%w: contract creation", ErrInvalidDepositTarget)
+	}
+	if !allowed[*to] {
+		return fmt.Errorf("%w: %s", ErrInvalidDepositTarget, to.Hex())
+	}
+	return nil
+}
+
+// rollupMetadata is the RLP layout of the rollup metadata record. The
+// optional values are lists of zero or one element, so that an unset value
+// is not confused with zero.
+type rollupMetadata struct {
+	TxHash            common.Hash
+	L1MessageSender   *common.Address `rlp:"nil"`
+	QueueIndex        []uint64
+	QueueOrigin       []uint64
+	SignatureHashType SignatureHashType
+}
+
+// RollupMetadata returns a compact record of the rollup metadata of the
+// transaction that is not covered by its hash: the L1 message sender, the
+// queue index, the queue origin and the signature hash type. The record
+// includes the hash of the transaction, so it can be stored separately and
+// re-attached with ApplyRollupMetadata.
+func (tx *Transaction) RollupMetadata() ([]byte, error) {
+	rec := rollupMetadata{
+		TxHash:            tx.Hash(),
+		L1MessageSender:   tx.meta.L1MessageSender,
+		SignatureHashType: tx.meta.SignatureHashType,
+	}
+	if tx.meta.QueueIndex != nil {
+		rec.QueueIndex = []uint64{*tx.meta.QueueIndex}
+	}
+	if qo := tx.meta.QueueOrigin; qo != nil {
+		if !qo.IsUint64() {
+			return nil, fmt.Errorf("%w: %d", ErrQueueOriginOutOfRange, qo)
+		}
+		rec.QueueOrigin = []uint64{qo.Uint64()}
+	}
+	return rlp.EncodeToBytes(&rec)
+}
+
+// ApplyRollupMetadata sets the rollup metadata of the transaction from a
+// record created by RollupMetadata. It returns ErrRollupMetadataMismatch if
+// the record belongs to a transaction with a different hash. The metadata
+// that is not part of the record is left unchanged.
+func (tx *Transaction) ApplyRollupMetadata(b []byte) error {
+	var rec rollupMetadata
+	if err := rlp.DecodeBytes(b, &rec); err != nil {
+		return err
+	}
+	if rec.TxHash != tx.Hash() {
+		return fmt.Errorf("%w: %s", ErrRollupMetadataMismatch, rec.TxHash.Hex())
+	}
+	if len(rec.QueueIndex) > 1 || len(rec.QueueOrigin) > 1 {
+		return ErrInvalidRollupMetadata
+	}
+	tx.meta.L1MessageSender = rec.L1MessageSender
+	tx.meta.SignatureHashType = rec.SignatureHashType
+	tx.meta.QueueIndex = nil
+	if len(rec.QueueIndex) == 1 {
+		queueIndex := rec.QueueIndex[0]
+		tx.meta.QueueIndex = &queueIndex
+	}
+	tx.meta.QueueOrigin = nil
+	if len(rec.QueueOrigin) == 1 {
+		tx.meta.QueueOrigin = new(big.Int).SetUint64(rec.QueueOrigin[0])
+	}
+	return nil
+}
+
+// compactDeposit is the RLP layout of the compact deposit encoding. Unlike
+// depositEncoding it does not hold the signature values, which are always
+// zero for deposits. The optional values are lists of zero or one element,
+// so that an unset value is not confused with zero.
+type compactDeposit struct {
+	AccountNonce    uint64
+	Price           *big.Int
+	GasLimit        uint64
+	Recipient       *common.Address `rlp:"nil"`
+	Amount          *big.Int
+	Payload         []byte
+	L1MessageSender common.Address
+	QueueIndex      []uint64
+	L1BlockNumber   []*big.Int
+	L1Timestamp     uint64
+}
+
+// EncodeDeposit returns the compact encoding of an L1 to L2 deposit, which
+// is the RLP encoding of the transaction fields and the L1 metadata without
+// the signature values. It is smaller than the binary encoding of
+// MarshalBinary and is decoded with DecodeDeposit. Deposits without an L1
+// message sender and signed deposits cannot be encoded.
+func EncodeDeposit(tx *Transaction) ([]byte, error) {
+	if !isDeposit(tx) {
+		return nil, ErrNotDeposit
+	}
+	if tx.meta.L1MessageSender == nil {
+		return nil, ErrNoL1MessageSender
+	}
+	if tx.IsSigned() {
+		return nil, fmt.Errorf("%w: signed deposit", ErrInvalidDepositEnc)
+	}
+	enc := &compactDeposit{
+		AccountNonce:    tx.data.AccountNonce,
+		Price:           tx.data.Price,
+		GasLimit:        tx.data.GasLimit,
+		Recipient:       tx.data.Recipient,
+		Amount:          tx.data.Amount,
+		Payload:         tx.data.Payload,
+		L1MessageSender: *tx.meta.L1MessageSender,
+		L1Timestamp:     tx.meta.L1Timestamp,
+	}
+	if tx.meta.QueueIndex != nil {
+		enc.QueueIndex = []uint64{*tx.meta.QueueIndex}
+	}
+	if tx.meta.L1BlockNumber != nil {
+		enc.L1BlockNumber = []*big.Int{tx.meta.L1BlockNumber}
+	}
+	return rlp.EncodeToBytes(enc)
+}
+
+// DecodeDeposit decodes a deposit encoded by EncodeDeposit.
+func DecodeDeposit(b []byte) (*Transaction, error) {
+	var dec compactDeposit
+	if err := rlp.DecodeBytes(b, &dec); err != nil {
+		return nil, err
+	}
+	if len(dec.QueueIndex) > 1 || len(dec.L1BlockNumber) > 1 {
+		return nil, ErrInvalidDepositEnc
+	}
+	l1MessageSender := dec.L1MessageSender
+	tx := newTransaction(dec.AccountNonce, dec.Recipient, dec.Amount, dec.GasLimit, dec.Price, dec.Payload, &l1MessageSender, nil, QueueOriginL1ToL2, SighashEIP155)
+	tx.meta.L1Timestamp = dec.L1Timestamp
+	if len(dec.QueueIndex) == 1 {
+		queueIndex := dec.QueueIndex[0]
+		tx.meta.QueueIndex = &queueIndex
+	}
+	if len(dec.L1BlockNumber) == 1 {
+		tx.meta.L1BlockNumber = dec.L1BlockNumber[0]
+	}
+	return tx, nil
+}
@@ -0,0 +1,716 @@
+package types
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestGasPriceEncoding(t *testing.T) {
+	gwei := big.NewInt(1000000000)
+	tests := []struct {
+		price   *big.Int
+		encoded uint32
+		err     error
+	}{
+		{price: big.NewInt(0), encoded: 0},
+		{price: big.NewInt(1000000), encoded: 1},
+		{price: gwei, encoded: 1000},
+		{price: new(big.Int).Mul(gwei, big.NewInt(100)), encoded: 100000},
+		{price: new(big.Int).Mul(big.NewInt(GasPriceScalar), big.NewInt(maxEncodedGasPrice)), encoded: maxEncodedGasPrice},
+		{price: new(big.Int).Mul(big.NewInt(GasPriceScalar), big.NewInt(maxEncodedGasPrice+1)), err: ErrGasPriceOutOfRange},
+		{price: big.NewInt(-1), err: ErrGasPriceOutOfRange},
+	}
+
+	for i, test := range tests {
+		encoded, err := EncodeGasPrice(test.price)
+		if err != test.err {
+			t.Fatalf("test %d: expected error %v, got %v", i, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if encoded != test.encoded {
+			t.Fatalf("test %d: expected encoded %d, got %d", i, test.encoded, encoded)
+		}
+		if decoded := DecodeGasPrice(encoded); decoded.Cmp(test.price) != 0 {
+			t.Fatalf("test %d: round trip mismatch, expected %d, got %d", i, test.price, decoded)
+		}
+	}
+
+	// The remainder below the scalar is truncated
+	encoded, err := EncodeGasPrice(big.NewInt(1999999))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded != 1 {
+		t.Fatalf("expected truncation to 1, got %d", encoded)
+	}
+}
+
+func TestGasPriceEncodingWithScalar(t *testing.T) {
+	price := big.NewInt(5000000)
+	tests := []struct {
+		scalar        uint64
+		encoded       uint32
+		err           error
+		representable bool
+	}{
+		{scalar: GasPriceScalar, encoded: 5, representable: true},
+		{scalar: 1000, encoded: 5000, representable: true},
+		{scalar: 3000000, encoded: 1},
+		{scalar: 1, encoded: 5000000, representable: true},
+		{scalar: 0, err: ErrGasPriceOutOfRange},
+	}
+	for i, test := range tests {
+		encoded, err := EncodeGasPriceWithScalar(price, test.scalar)
+		if err != test.err {
+			t.Fatalf("test %d: expected error %v, got %v", i, test.err, err)
+		}
+		if have := GasPriceRepresentableWithScalar(price, test.scalar); have != test.representable {
+			t.Fatalf("test %d: expected representable %v, got %v", i, test.representable, have)
+		}
+		if err != nil {
+			continue
+		}
+		if encoded != test.encoded {
+			t.Fatalf("test %d: expected encoded %d, got %d", i, test.encoded, encoded)
+		}
+		if decoded := DecodeGasPriceWithScalar(encoded, test.scalar); test.representable && decoded.Cmp(price) != 0 {
+			t.Fatalf("test %d: round trip mismatch, expected %d, got %d", i, price, decoded)
+		}
+	}
+}
+
+func TestNormalizeGasPriceToBucket(t *testing.T) {
+	max := new(big.Int).Mul(big.NewInt(GasPriceScalar), big.NewInt(maxEncodedGasPrice))
+	tests := []struct {
+		price  *big.Int
+		bucket *big.Int
+	}{
+		{price: big.NewInt(0), bucket: big.NewInt(0)},
+		{price: big.NewInt(-1), bucket: big.NewInt(0)},
+		{price: big.NewInt(1000000), bucket: big.NewInt(1000000)},
+		// Rounded down below the halfway point
+		{price: big.NewInt(1499999), bucket: big.NewInt(1000000)},
+		{price: big.NewInt(499999), bucket: big.NewInt(0)},
+		// Rounded up from the halfway point
+		{price: big.NewInt(1500000), bucket: big.NewInt(2000000)},
+		{price: big.NewInt(1999999), bucket: big.NewInt(2000000)},
+		{price: big.NewInt(500000), bucket: big.NewInt(1000000)},
+		// Clamped to the largest representable price
+		{price: max, bucket: max},
+		{price: new(big.Int).Add(max, big.NewInt(499999)), bucket: max},
+		{price: new(big.Int).Add(max, big.NewInt(500000)), bucket: max},
+		{price: new(big.Int).Mul(max, big.NewInt(2)), bucket: max},
+	}
+	for i, test := range tests {
+		bucket := NormalizeGasPriceToBucket(test.price)
+		if bucket.Cmp(test.bucket) != 0 {
+			t.Fatalf("test %d: expected %d, got %d", i, test.bucket, bucket)
+		}
+		if !GasPriceRepresentable(bucket) {
+			t.Fatalf("test %d: bucket %d is not representable", i, bucket)
+		}
+	}
+}
+
+func TestValidateOVMTransactionDepositValue(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tests := []struct {
+		value       *big.Int
+		queueOrigin QueueOrigin
+		err         error
+	}{
+		{value: big.NewInt(0), queueOrigin: QueueOriginL1ToL2},
+		{value: big.NewInt(1), queueOrigin: QueueOriginL1ToL2, err: ErrDepositNonzeroValue},
+		{value: big.NewInt(1), queueOrigin: QueueOriginSequencer},
+	}
+	for i, test := range tests {
+		tx := NewTransaction(0, to, test.value, 21000, new(big.Int), nil, &to, nil, test.queueOrigin, SighashEIP155)
+		if err := ValidateOVMTransaction(tx); err != test.err {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestDepositSender(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &l1Sender, big.NewInt(1), QueueOriginL1ToL2, SighashEIP155)
+	sender, err := DepositSender(deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender != l1Sender {
+		t.Fatalf("expected sender %s, got %s", l1Sender.Hex(), sender.Hex())
+	}
+
+	noSender := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, big.NewInt(1), QueueOriginL1ToL2, SighashEIP155)
+	if _, err := DepositSender(noSender); err != ErrNoL1MessageSender {
+		t.Fatalf("expected %v, got %v", ErrNoL1MessageSender, err)
+	}
+
+	sequenced := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &l1Sender, nil, QueueOriginSequencer, SighashEIP155)
+	if _, err := DepositSender(sequenced); err != ErrNotDeposit {
+		t.Fatalf("expected %v, got %v", ErrNotDeposit, err)
+	}
+}
+
+func TestDepositProvenance(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &l1Sender, big.NewInt(1), QueueOriginL1ToL2, SighashEIP155)
+	if _, err := deposit.DepositProvenance(); err != ErrNoQueueIndex {
+		t.Fatalf("expected %v, got %v", ErrNoQueueIndex, err)
+	}
+	deposit.SetQueueIndex(5)
+	have, err := deposit.DepositProvenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "L1 tx 5 from 0x2222222222222222222222222222222222222222 to 0x1111111111111111111111111111111111111111"
+	if have != want {
+		t.Fatalf("expected %q, got %q", want, have)
+	}
+
+	tx := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if _, err := tx.DepositProvenance(); err != ErrNotDeposit {
+		t.Fatalf("expected %v, got %v", ErrNotDeposit, err)
+	}
+}
+
+func TestValidateOVMTransactionQueueIndex(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	index := uint64(3)
+	tests := []struct {
+		queueOrigin QueueOrigin
+		queueIndex  *uint64
+		err         error
+	}{
+		{queueOrigin: QueueOriginL1ToL2, queueIndex: &index},
+		{queueOrigin: QueueOriginL1ToL2},
+		{queueOrigin: QueueOriginSequencer},
+		{queueOrigin: QueueOriginSequencer, queueIndex: &index, err: ErrUnexpectedRollupTxId},
+	}
+	for i, test := range tests {
+		tx := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, test.queueOrigin, SighashEIP155)
+		if test.queueIndex != nil {
+			tx.SetQueueIndex(*test.queueIndex)
+		}
+		if err := ValidateOVMTransaction(tx); err != test.err {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestEffectiveOVMGasPrice(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	max := new(big.Int).Mul(big.NewInt(GasPriceScalar), big.NewInt(maxEncodedGasPrice))
+	tests := []struct {
+		price *big.Int
+		want  *big.Int
+	}{
+		{price: big.NewInt(0), want: big.NewInt(0)},
+		{price: big.NewInt(999999), want: big.NewInt(0)},
+		{price: big.NewInt(1000000), want: big.NewInt(1000000)},
+		{price: big.NewInt(1999999), want: big.NewInt(1000000)},
+		{price: big.NewInt(25000001), want: big.NewInt(25000000)},
+		{price: max, want: max},
+		{price: new(big.Int).Add(max, big.NewInt(GasPriceScalar))},
+	}
+	for i, test := range tests {
+		tx := NewTransaction(0, to, new(big.Int), 21000, test.price, nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+		have := tx.EffectiveOVMGasPrice()
+		if test.want == nil {
+			if have != nil {
+				t.Errorf("test %d: expected nil, got %d", i, have)
+			}
+			continue
+		}
+		if have == nil || have.Cmp(test.want) != 0 {
+			t.Errorf("test %d: expected %d, got %v", i, test.want, have)
+		}
+		encoded, _ := EncodeGasPrice(test.price)
+		if bucket := GasPriceForBucket(encoded); have.Cmp(bucket) != 0 {
+			t.Errorf("test %d: expected bucket %d, got %d", i, bucket, have)
+		}
+	}
+	// Deposits are not compressed
+	deposit := NewTransaction(0, to, new(big.Int), 21000, big.NewInt(1999999), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	if have := deposit.EffectiveOVMGasPrice(); have.Cmp(big.NewInt(1999999)) != 0 {
+		t.Errorf("deposit: expected %d, got %d", 1999999, have)
+	}
+}
+
+func TestValidateDepositDataSize(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tests := []struct {
+		size        int
+		queueOrigin QueueOrigin
+		err         error
+	}{
+		{size: 0, queueOrigin: QueueOriginL1ToL2},
+		{size: 32, queueOrigin: QueueOriginL1ToL2},
+		{size: 33, queueOrigin: QueueOriginL1ToL2, err: ErrDepositDataTooLarge},
+		{size: 33, queueOrigin: QueueOriginSequencer},
+	}
+	for i, test := range tests {
+		tx := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), make([]byte, test.size), &to, nil, test.queueOrigin, SighashEIP155)
+		if err := ValidateDepositDataSize(tx, 32); !errors.Is(err, test.err) {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestValidateDepositTimestamp(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	ctxTime := big.NewInt(1600000000)
+	tests := []struct {
+		timestamp   uint64
+		queueOrigin QueueOrigin
+		err         error
+	}{
+		{timestamp: 1599999000, queueOrigin: QueueOriginL1ToL2},
+		{timestamp: 1600000000, queueOrigin: QueueOriginL1ToL2},
+		{timestamp: 1600000300, queueOrigin: QueueOriginL1ToL2},
+		{timestamp: 1600000301, queueOrigin: QueueOriginL1ToL2, err: ErrFutureDepositTimestamp},
+		{timestamp: 1700000000, queueOrigin: QueueOriginL1ToL2, err: ErrFutureDepositTimestamp},
+		{timestamp: 1700000000, queueOrigin: QueueOriginSequencer},
+	}
+	for i, test := range tests {
+		tx := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, test.queueOrigin, SighashEIP155)
+		tx.SetL1Timestamp(test.timestamp)
+		if err := ValidateDepositTimestamp(tx, ctxTime, 300); !errors.Is(err, test.err) {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestValidateDepositTarget(t *testing.T) {
+	messenger := common.HexToAddress("0x4200000000000000000000000000000000000007")
+	bridge := common.HexToAddress("0x4200000000000000000000000000000000000010")
+	other := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	allowed := map[common.Address]bool{messenger: true, bridge: true}
+
+	tests := []struct {
+		tx  *Transaction
+		err error
+	}{
+		{tx: NewTransaction(0, messenger, new(big.Int), 21000, new(big.Int), nil, &other, nil, QueueOriginL1ToL2, SighashEIP155)},
+		{tx: NewTransaction(0, bridge, new(big.Int), 21000, new(big.Int), nil, &other, nil, QueueOriginL1ToL2, SighashEIP155)},
+		{tx: NewTransaction(0, other, new(big.Int), 21000, new(big.Int), nil, &other, nil, QueueOriginL1ToL2, SighashEIP155), err: ErrInvalidDepositTarget},
+		{tx: NewContractCreation(0, new(big.Int), 21000, new(big.Int), nil, &other, nil, QueueOriginL1ToL2), err: ErrInvalidDepositTarget},
+		// Sequencer transactions may target any address
+		{tx: NewTransaction(0, other, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)},
+	}
+	for i, test := range tests {
+		if err := ValidateDepositTarget(test.tx, allowed); !errors.Is(err, test.err) {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestNewTransactionValidated(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	tests := []struct {
+		to          *common.Address
+		value       *big.Int
+		l1Sender    *common.Address
+		queueOrigin QueueOrigin
+		err         error
+	}{
+		{to: &to, value: big.NewInt(1), queueOrigin: QueueOriginSequencer},
+		{value: big.NewInt(1), queueOrigin: QueueOriginSequencer},
+		{to: &to, value: new(big.Int), l1Sender: &l1Sender, queueOrigin: QueueOriginL1ToL2},
+		{to: &to, value: new(big.Int), l1Sender: &l1Sender, queueOrigin: QueueOriginSequencer, err: ErrUnexpectedL1MessageSender},
+		{to: &to, value: new(big.Int), queueOrigin: QueueOriginL1ToL2, err: ErrNoL1MessageSender},
+		{to: &to, value: big.NewInt(1), l1Sender: &l1Sender, queueOrigin: QueueOriginL1ToL2, err: ErrDepositNonzeroValue},
+		{to: &to, value: new(big.Int), queueOrigin: QueueOrigin(2), err: ErrQueueOriginOutOfRange},
+	}
+	for i, test := range tests {
+		tx, err := NewTransactionValidated(0, test.to, test.value, 21000, new(big.Int), nil, test.l1Sender, nil, test.queueOrigin, SighashEIP155)
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+			continue
+		}
+		if test.err != nil {
+			if tx != nil {
+				t.Errorf("test %d: expected no transaction on error", i)
+			}
+			continue
+		}
+		if (tx.To() == nil) != (test.to == nil) {
+			t.Errorf("test %d: recipient mismatch: have %v, want %v", i, tx.To(), test.to)
+		}
+		if qo := tx.QueueOrigin(); qo == nil || qo.Uint64() != uint64(test.queueOrigin) {
+			t.Errorf("test %d: queue origin mismatch: have %v, want %d", i, qo, test.queueOrigin)
+		}
+	}
+}
+
+func TestEstimatedVsLimitRatio(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newDeposit := func(gas uint64) *Transaction {
+		return NewTransaction(0, to, new(big.Int), gas, new(big.Int), []byte{1}, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	}
+	// The deposits only pay for a plain call
+	estimator := func(*Transaction) uint64 { return params.TxGas }
+
+	padded := newDeposit(8000000).EstimatedVsLimitRatio(estimator)
+	if padded < 100 {
+		t.Fatalf("expected a padded deposit to have a large ratio, got %f", padded)
+	}
+	if tight := newDeposit(params.TxGas).EstimatedVsLimitRatio(estimator); tight != 1 {
+		t.Fatalf("expected a tight deposit to have ratio 1, got %f", tight)
+	}
+
+	zero := func(*Transaction) uint64 { return 0 }
+	if ratio := newDeposit(params.TxGas).EstimatedVsLimitRatio(zero); !math.IsInf(ratio, 1) {
+		t.Fatalf("expected an infinite ratio for a zero estimate, got %f", ratio)
+	}
+	if ratio := newDeposit(0).EstimatedVsLimitRatio(zero); ratio != 1 {
+		t.Fatalf("expected ratio 1 for a zero limit and estimate, got %f", ratio)
+	}
+}
+
+func TestTotalL1DataFee(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	baseFee := big.NewInt(100)
+
+	sequenced := NewTransaction(0, to, new(big.Int), 21000, big.NewInt(1), []byte{0x00, 0x01}, nil, nil, QueueOriginSequencer, SighashEIP155)
+	// The calldata cost of the encoding: zero bytes are cheaper than others
+	enc, _ := rlp.EncodeToBytes(sequenced)
+	var gas uint64
+	for _, b := range enc {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+	if sequenced.L1DataGas() != gas {
+		t.Fatalf("expected L1 data gas %d, got %d", gas, sequenced.L1DataGas())
+	}
+	fee := sequenced.L1DataFee(baseFee)
+	if want := new(big.Int).SetUint64(gas * 100); fee.Cmp(want) != 0 {
+		t.Fatalf("expected L1 data fee %d, got %d", want, fee)
+	}
+
+	other := NewTransaction(1, to, new(big.Int), 21000, big.NewInt(1), make([]byte, 64), nil, nil, QueueOriginSequencer, SighashEIP155)
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), make([]byte, 64), &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	txs := Transactions{sequenced, deposit, other, deposit}
+
+	want := new(big.Int).Add(fee, other.L1DataFee(baseFee))
+	if total := txs.TotalL1DataFee(baseFee); total.Cmp(want) != 0 {
+		t.Fatalf("expected total L1 data fee %d, got %d", want, total)
+	}
+	if total := (Transactions{deposit}).TotalL1DataFee(baseFee); total.Sign() != 0 {
+		t.Fatalf("expected no L1 data fee for deposits, got %d", total)
+	}
+}
+
+func TestQueueOriginEncoding(t *testing.T) {
+	for _, qo := range []QueueOrigin{QueueOriginSequencer, QueueOriginL1ToL2} {
+		enc, err := EncodeQueueOrigin(qo)
+		if err != nil {
+			t.Fatalf("queue origin %d: %v", qo, err)
+		}
+		if len(enc) != QueueOriginWidth {
+			t.Fatalf("queue origin %d: expected %d bytes, got %d", qo, QueueOriginWidth, len(enc))
+		}
+		dec, err := DecodeQueueOrigin(enc)
+		if err != nil {
+			t.Fatalf("queue origin %d: %v", qo, err)
+		}
+		if dec != qo {
+			t.Fatalf("expected queue origin %d, got %d", qo, dec)
+		}
+	}
+
+	if _, err := EncodeQueueOrigin(QueueOrigin(2)); err != ErrQueueOriginOutOfRange {
+		t.Fatalf("expected %v, got %v", ErrQueueOriginOutOfRange, err)
+	}
+	if _, err := EncodeQueueOrigin(QueueOrigin(256)); err != ErrQueueOriginOutOfRange {
+		t.Fatalf("expected %v, got %v", ErrQueueOriginOutOfRange, err)
+	}
+	if _, err := DecodeQueueOrigin([]byte{2}); err != ErrQueueOriginOutOfRange {
+		t.Fatalf("expected %v, got %v", ErrQueueOriginOutOfRange, err)
+	}
+	if _, err := DecodeQueueOrigin([]byte{0, 1}); err != ErrQueueOriginLength {
+		t.Fatalf("expected %v, got %v", ErrQueueOriginLength, err)
+	}
+}
+
+func TestGasPriceBuckets(t *testing.T) {
+	if have, want := GasPriceBucketCount(), 1<<24; have != want {
+		t.Fatalf("expected %d buckets, got %d", want, have)
+	}
+	tests := []struct {
+		bucket uint32
+		price  *big.Int
+	}{
+		{bucket: 0, price: big.NewInt(0)},
+		{bucket: 1, price: big.NewInt(1000000)},
+		{bucket: 2, price: big.NewInt(2000000)},
+		{bucket: 1000, price: big.NewInt(1000000000)},
+		{bucket: maxEncodedGasPrice, price: new(big.Int).Mul(big.NewInt(maxEncodedGasPrice), big.NewInt(GasPriceScalar))},
+	}
+	for i, test := range tests {
+		price := GasPriceForBucket(test.bucket)
+		if price.Cmp(test.price) != 0 {
+			t.Fatalf("test %d: expected %d, got %d", i, test.price, price)
+		}
+		if !GasPriceRepresentable(price) {
+			t.Fatalf("test %d: price %d is not representable", i, price)
+		}
+		if encoded, err := EncodeGasPrice(price); err != nil || encoded != test.bucket {
+			t.Fatalf("test %d: expected price to encode to bucket %d, got %d (%v)", i, test.bucket, encoded, err)
+		}
+	}
+	if price := GasPriceForBucket(uint32(GasPriceBucketCount())); price != nil {
+		t.Fatalf("expected no price past the last bucket, got %d", price)
+	}
+}
+
+func TestEvictionCandidate(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	sequencer := func(nonce uint64, price int64) *Transaction {
+		return NewTransaction(nonce, to, new(big.Int), 21000, big.NewInt(price), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	}
+	deposit := func(nonce uint64) *Transaction {
+		return NewTransaction(nonce, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	}
+
+	// Deposits have a zero gas price, but are never evicted
+	cheap := sequencer(1, 5)
+	txs := Transactions{deposit(0), sequencer(0, 10), cheap, deposit(1), sequencer(2, 20)}
+	if tx, err := EvictionCandidate(txs); err != nil || tx != cheap {
+		t.Fatalf("expected the cheapest sequencer transaction, got %v (%v)", tx, err)
+	}
+
+	// Of equally priced transactions the last one is evicted
+	last := sequencer(3, 5)
+	if tx, err := EvictionCandidate(append(txs, last)); err != nil || tx != last {
+		t.Fatalf("expected the last of the cheapest transactions, got %v (%v)", tx, err)
+	}
+
+	for _, txs := range []Transactions{{deposit(0), deposit(1)}, nil} {
+		if tx, err := EvictionCandidate(txs); err != ErrNoEvictionCandidate {
+			t.Fatalf("expected %v, got %v (%v)", ErrNoEvictionCandidate, tx, err)
+		}
+	}
+}
+
+func TestValidateSighashForFork(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func(sighashType SignatureHashType) *Transaction {
+		return NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, sighashType)
+	}
+	// A fork that disables EthSign
+	allowed := map[SignatureHashType]bool{SighashEIP155: true, CreateEOA: true}
+
+	if err := ValidateSighashForFork(newTx(SighashEIP155), allowed); err != nil {
+		t.Fatalf("expected EIP155 to be allowed, got %v", err)
+	}
+	if err := ValidateSighashForFork(newTx(SighashEthSign), allowed); !errors.Is(err, ErrSighashNotAllowed) {
+		t.Fatalf("expected %v, got %v", ErrSighashNotAllowed, err)
+	}
+	if err := ValidateSighashForFork(newTx(SighashEthSign), nil); err != nil {
+		t.Fatalf("expected no restrictions without an allowed set, got %v", err)
+	}
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	if err := ValidateSighashForFork(deposit, map[SignatureHashType]bool{}); err != nil {
+		t.Fatalf("expected deposit to be accepted, got %v", err)
+	}
+}
+
+func TestRollupMetadataRoundTrip(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	// The L1 block number and timestamp are not part of the record
+	deposit := NewTransaction(7, to, new(big.Int), 21000, new(big.Int), []byte{1}, &sender, nil, QueueOriginL1ToL2, SighashEIP155)
+	deposit.SetQueueIndex(7)
+	ethSign := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEthSign)
+
+	for i, tx := range []*Transaction{deposit, ethSign, rightvrsTx} {
+		rec, err := tx.RollupMetadata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A hash equal copy without the metadata
+		enc, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bare := new(Transaction)
+		if err := rlp.DecodeBytes(enc, bare); err != nil {
+			t.Fatal(err)
+		}
+		if err := bare.ApplyRollupMetadata(rec); err != nil {
+			t.Fatalf("tx %d: unexpected error: %v", i, err)
+		}
+		if diff := tx.Diff(bare); len(diff) != 0 {
+			t.Fatalf("tx %d: metadata differs after re-attaching: %v", i, diff)
+		}
+	}
+
+	rec, err := deposit.RollupMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ethSign.ApplyRollupMetadata(rec); !errors.Is(err, ErrRollupMetadataMismatch) {
+		t.Fatalf("expected %v, got %v", ErrRollupMetadataMismatch, err)
+	}
+}
+
+func TestBlockFeeRevenue(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	gwei := big.NewInt(1000000000)
+	baseFee := big.NewInt(100)
+
+	first := NewTransaction(0, to, new(big.Int), 21000, gwei, []byte{1, 2, 3}, nil, nil, QueueOriginSequencer, SighashEIP155)
+	// The gas price below the scalar is truncated by the encoding
+	second := NewTransaction(1, to, new(big.Int), 50000, new(big.Int).Add(gwei, big.NewInt(1)), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	deposit := NewTransaction(0, to, new(big.Int), 100000, gwei, []byte{4, 5, 6}, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+
+	l2Revenue, l1Cost, err := BlockFeeRevenue(Transactions{first, deposit, second}, baseFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantL2 := new(big.Int).Mul(big.NewInt(21000+50000), gwei)
+	if l2Revenue.Cmp(wantL2) != 0 {
+		t.Fatalf("expected l2 revenue %d, got %d", wantL2, l2Revenue)
+	}
+	wantL1 := new(big.Int).Add(first.L1DataFee(baseFee), second.L1DataFee(baseFee))
+	if l1Cost.Cmp(wantL1) != 0 {
+		t.Fatalf("expected l1 cost %d, got %d", wantL1, l1Cost)
+	}
+
+	l2Revenue, l1Cost, err = BlockFeeRevenue(nil, baseFee)
+	if err != nil || l2Revenue.Sign() != 0 || l1Cost.Sign() != 0 {
+		t.Fatalf("expected zero revenue for an empty block, got %d, %d, %v", l2Revenue, l1Cost, err)
+	}
+
+	unencodable := NewTransaction(0, to, new(big.Int), 21000, big.NewInt(-1), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if _, _, err := BlockFeeRevenue(Transactions{first, unencodable}, baseFee); !errors.Is(err, ErrGasPriceOutOfRange) {
+		t.Fatalf("expected %v, got %v", ErrGasPriceOutOfRange, err)
+	}
+}
+
+func TestWorstCaseGas(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	deposit := func(gas uint64) *Transaction {
+		return NewTransaction(0, to, new(big.Int), gas, new(big.Int), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	}
+
+	tests := []struct {
+		deposits Transactions
+		gas      uint64
+		err      error
+	}{
+		{deposits: nil, gas: 0},
+		{deposits: Transactions{deposit(21000), deposit(100000)}, gas: 121000},
+		// Summing up to exactly the uint64 boundary
+		{deposits: Transactions{deposit(math.MaxUint64 - 1), deposit(1)}, gas: math.MaxUint64},
+		{deposits: Transactions{deposit(math.MaxUint64 - 1), deposit(1), deposit(1)}, err: ErrGasOverflow},
+		{deposits: Transactions{deposit(math.MaxUint64), deposit(math.MaxUint64)}, err: ErrGasOverflow},
+		{deposits: Transactions{deposit(21000), rightvrsTx}, err: ErrNotDeposit},
+	}
+	for i, test := range tests {
+		gas, err := test.deposits.WorstCaseGas()
+		if !errors.Is(err, test.err) {
+			t.Fatalf("test %d: expected error %v, got %v", i, test.err, err)
+		}
+		if gas != test.gas {
+			t.Fatalf("test %d: expected gas %d, got %d", i, test.gas, gas)
+		}
+	}
+}
+
+func TestEncodeDeposit(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	newDeposit := func(queueIndex uint64, l1BlockNumber *big.Int) *Transaction {
+		deposit := NewTransaction(queueIndex, to, new(big.Int), 21000, new(big.Int), []byte{1, 2, 3}, &l1Sender, l1BlockNumber, QueueOriginL1ToL2, SighashEIP155)
+		deposit.SetL1Timestamp(1600000000)
+		deposit.SetQueueIndex(queueIndex)
+		return deposit
+	}
+	deposits := []*Transaction{
+		newDeposit(0, big.NewInt(12)),
+		newDeposit(7, nil),
+		NewContractCreation(0, new(big.Int), 100000, new(big.Int), common.FromHex("6000"), &l1Sender, nil, QueueOriginL1ToL2),
+	}
+	for i, deposit := range deposits {
+		enc, err := EncodeDeposit(deposit)
+		if err != nil {
+			t.Fatalf("deposit %d: %v", i, err)
+		}
+		binary, err := deposit.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enc) >= len(binary) {
+			t.Fatalf("deposit %d: expected compact encoding smaller than %d bytes, got %d", i, len(binary), len(enc))
+		}
+		decoded, err := DecodeDeposit(enc)
+		if err != nil {
+			t.Fatalf("deposit %d: %v", i, err)
+		}
+		if diff := deposit.Diff(decoded); len(diff) != 0 {
+			t.Fatalf("deposit %d: decoded deposit differs: %s", i, strings.Join(diff, ", "))
+		}
+		if decoded.Hash() != deposit.Hash() {
+			t.Fatalf("deposit %d: hash mismatch", i)
+		}
+	}
+
+	if _, err := EncodeDeposit(rightvrsTx); err != ErrNotDeposit {
+		t.Fatalf("expected %v, got %v", ErrNotDeposit, err)
+	}
+	noSender := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginL1ToL2, SighashEIP155)
+	if _, err := EncodeDeposit(noSender); err != ErrNoL1MessageSender {
+		t.Fatalf("expected %v, got %v", ErrNoL1MessageSender, err)
+	}
+	if _, err := DecodeDeposit([]byte{0x01, 0x02}); err == nil {
+		t.Fatal("expected an error for malformed input")
+	}
+}
+
+func TestValidateQueueOrder(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	deposit := func(queueIndex uint64) *Transaction {
+		tx := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+		tx.SetQueueIndex(queueIndex)
+		return tx
+	}
+	noIndex := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+
+	tests := []struct {
+		deposits Transactions
+		err      error
+	}{
+		{deposits: nil},
+		{deposits: Transactions{deposit(0), deposit(1), deposit(2)}},
+		// Gaps are allowed, deposits may be split across blocks
+		{deposits: Transactions{deposit(3), deposit(7)}},
+		{deposits: Transactions{deposit(0), deposit(2), deposit(1)}, err: ErrQueueOrder},
+		{deposits: Transactions{deposit(4), deposit(4)}, err: ErrQueueOrder},
+		{deposits: Transactions{deposit(0), noIndex}, err: ErrNoQueueIndex},
+		{deposits: Transactions{deposit(0), rightvrsTx}, err: ErrNotDeposit},
+	}
+	for i, test := range tests {
+		if err := test.deposits.ValidateQueueOrder(); !errors.Is(err, test.err) {
+			t.Fatalf("test %d: expected error %v, got %v", i, test.err, err)
+		}
+	}
+}
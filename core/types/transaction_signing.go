@@ -22,20 +22,44 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
 	"golang.org/x/crypto/sha3"
 )
 
 var (
-	ErrInvalidChainId = errors.New("invalid chain id for signer")
+	ErrInvalidChainId  = errors.New("invalid chain id for signer")
+	ErrChainIDTooLarge = errors.New("chain id too large")
+	ErrNilSigner       = errors.New("nil signer")
+	ErrChainIDMismatch = errors.New("transaction chain id mismatch")
+	ErrUnprotectedTx   = errors.New("transaction is not replay protected")
+	ErrSighashMismatch = errors.New("signature does not match signature hash type")
+	ErrKeyMismatch     = errors.New("private key does not match sender")
+	ErrNoPreimage      = errors.New("signing preimage not available for signer")
+	ErrResignMismatch  = errors.New("re-signed transaction does not recover to the signer")
 )
 
+// maxChainID is the largest chain id that SignTx will sign for, which is the
+// largest chain id whose EIP155 V value fits in a uint64, as the compressed
+// sequencer transaction encoding expects.
+var maxChainID = new(big.Int).SetUint64((math.MaxUint64 - 36) / 2)
+
+// MaxChainID returns the largest chain id that SignTx will sign for.
+func MaxChainID() *big.Int {
+	return new(big.Int).Set(maxChainID)
+}
+
 // sigCache is used to cache the derived sender and contains
 // the signer used to derive it.
 type sigCache struct {
@@ -43,6 +67,107 @@ type sigCache struct {
 	from   common.Address
 }
 
+// ValidateChainID checks that the chain id derived from the V value of the
+// transaction is want. Deposits are not signed and are always accepted.
+// Transactions that are not replay protected carry no chain id and are
+// accepted if allowUnprotected is set, matching the OVMSigner, which recovers
+// them as Homestead transactions, and rejected with ErrUnprotectedTx
+// otherwise.
+func ValidateChainID(tx *Transaction, want *big.Int, allowUnprotected bool) error {
+	if isDeposit(tx) {
+		return nil
+	}
+	if !tx.Protected() {
+		if allowUnprotected {
+			return nil
+		}
+		return ErrUnprotectedTx
+	}
+	if have := tx.DeriveChainId(); have.Cmp(want) != 0 {
+		return fmt.Errorf("%w: have %d, want %d", ErrChainIDMismatch, have, want)
+	}
+	return nil
+}
+
+// AssertSameChainID returns ErrChainIDMismatch if the transactions were not
+// all signed for the same chain id, which in a single batch indicates an
+// error or a replay. Transactions that are not replay protected or not
+// signed, such as deposits, carry no chain id and are ignored.
+func (s Transactions) AssertSameChainID() error {
+	var (
+		chainId *big.Int
+		first   int
+	)
+	for i, tx := range s {
+		if isDeposit(tx) || !tx.IsSigned() || !tx.Protected() {
+			continue
+		}
+		id := tx.DeriveChainId()
+		if chainId == nil {
+			chainId, first = id, i
+			continue
+		}
+		if id.Cmp(chainId) != 0 {
+			return fmt.Errorf("%w: transaction %d has chain id %d, transaction %d has %d", ErrChainIDMismatch, i, id, first, chainId)
+		}
+	}
+	return nil
+}
+
+// ValidateSignatureFormat is a cheap check of the signature values of a
+// transaction that rejects obviously malformed signatures before the
+// expensive public key recovery. V must be a raw recovery id, 0 or 1, a
+// Homestead value, 27 or 28, or an EIP155 value of at least 35, and R and S
+// must be in range. It returns ErrInvalidSig otherwise. L1 to L2 transactions
+// are not signed and are not checked.
+func ValidateSignatureFormat(tx *Transaction) error {
+	if isDeposit(tx) {
+		return nil
+	}
+	V, R, S := tx.RawSignatureValues()
+	if V == nil || R == nil || S == nil {
+		return ErrInvalidSig
+	}
+	var recid byte
+	switch {
+	case V.Cmp(big.NewInt(35)) >= 0:
+		// 35 is odd, so the recovery id is the inverse of the lowest bit of V
+		recid = byte(1 - V.Bit(0))
+	case V.Cmp(big.NewInt(27)) == 0 || V.Cmp(big.NewInt(28)) == 0:
+		recid = byte(V.Uint64() - 27)
+	case V.Cmp(big.NewInt(0)) == 0 || V.Cmp(big.NewInt(1)) == 0:
+		recid = byte(V.Uint64())
+	default:
+		return ErrInvalidSig
+	}
+	if !crypto.ValidateSignatureValues(recid, R, S, true) {
+		return ErrInvalidSig
+	}
+	return nil
+}
+
+// ValidateEthSign checks that a SighashEthSign transaction was signed by from
+// over the personal message hash. The OVMSigner recovers any signature to
+// some address, so a transaction that declares SighashEthSign but was signed
+// over the EIP155 hash can only be told apart by the sender it recovers to.
+// Transactions of other signature hash types are not checked.
+func ValidateEthSign(signer OVMSigner, tx *Transaction, from common.Address) error {
+	if tx.SignatureHashType() != SighashEthSign {
+		return nil
+	}
+	if !tx.Protected() {
+		return ErrSighashMismatch
+	}
+	addr, err := signer.Sender(tx)
+	if err != nil {
+		return err
+	}
+	if addr != from {
+		return ErrSighashMismatch
+	}
+	return nil
+}
+
 // MakeSigner returns a Signer based on the given chain config and block number.
 func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 	return NewOVMSigner(config.ChainID)
@@ -50,6 +175,12 @@ func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 
 // SignTx signs the transaction using the given signer and private key
 func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	if s == nil {
+		return nil, ErrNilSigner
+	}
+	if chainId := signerChainId(s); chainId != nil && chainId.Cmp(maxChainID) > 0 {
+		return nil, ErrChainIDTooLarge
+	}
 	h := s.Hash(tx)
 	sig, err := crypto.Sign(h[:], prv)
 	if err != nil {
@@ -58,6 +189,64 @@ func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, err
 	return tx.WithSignature(s, sig)
 }
 
+// NewCancelTransaction creates a zero value self send from the given sender at
+// nonce, signed with prv. Sent with a higher gas price than a pending
+// transaction at the same nonce, it replaces and so cancels that transaction.
+// The key is required to sign and must belong to from.
+func NewCancelTransaction(nonce uint64, from common.Address, gasPrice *big.Int, signer Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	if crypto.PubkeyToAddress(prv.PublicKey) != from {
+		return nil, ErrKeyMismatch
+	}
+	tx := NewTransaction(nonce, from, new(big.Int), params.TxGas, gasPrice, nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	return SignTx(tx, signer, prv)
+}
+
+// ResignBatch re-signs the transactions under newSigner with prv, such as
+// when migrating them to a chain with a new chain id. Every transaction must
+// recover under oldSigner to the address of prv. The existing signature is
+// dropped before signing, and ErrResignMismatch is returned if a re-signed
+// transaction does not recover under newSigner to the address of prv.
+// Deposits are not signed and are returned unchanged. The OVM metadata of
+// the transactions is preserved.
+func ResignBatch(txs Transactions, oldSigner, newSigner Signer, prv *ecdsa.PrivateKey) (Transactions, error) {
+	addr := crypto.PubkeyToAddress(prv.PublicKey)
+	resigned := make(Transactions, len(txs))
+	for i, tx := range txs {
+		if isDeposit(tx) {
+			resigned[i] = tx
+			continue
+		}
+		from, err := Sender(oldSigner, tx)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		if from != addr {
+			return nil, fmt.Errorf("transaction %d: %w", i, ErrKeyMismatch)
+		}
+		unsigned := &Transaction{data: tx.data, meta: tx.meta}
+		unsigned.data.V, unsigned.data.R, unsigned.data.S = new(big.Int), new(big.Int), new(big.Int)
+		if resigned[i], err = SignTx(unsigned, newSigner, prv); err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		if from, err := Sender(newSigner, resigned[i]); err != nil || from != addr {
+			return nil, fmt.Errorf("transaction %d: %w", i, ErrResignMismatch)
+		}
+	}
+	return resigned, nil
+}
+
+// signerChainId returns the chain id of signers that are replay protected
+// and nil for all other signers.
+func signerChainId(s Signer) *big.Int {
+	switch s := s.(type) {
+	case OVMSigner:
+		return s.chainId
+	case EIP155Signer:
+		return s.chainId
+	}
+	return nil
+}
+
 // Sender returns the address derived from the signature (V, R, S) using secp256k1
 // elliptic curve and an error if it failed deriving or upon an incorrect
 // signature.
@@ -66,6 +255,9 @@ func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, err
 // signing method. The cache is invalidated if the cached signer does
 // not match the signer used in the current call.
 func Sender(signer Signer, tx *Transaction) (common.Address, error) {
+	if signer == nil {
+		return common.Address{}, ErrNilSigner
+	}
 	if sc := tx.from.Load(); sc != nil {
 		sigCache := sc.(sigCache)
 		// If the signer used to derive from in a previous
@@ -84,6 +276,151 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	return addr, nil
 }
 
+// Recoverable checks that the sender of the transaction can be derived,
+// without returning it. Deposits are unsigned and need an L1 message sender,
+// all other transactions need a signature that recovers under the signer.
+func Recoverable(signer Signer, tx *Transaction) error {
+	if isDeposit(tx) {
+		_, err := DepositSender(tx)
+		return err
+	}
+	if !tx.IsSigned() {
+		return ErrUnsignedTransaction
+	}
+	_, err := Sender(signer, tx)
+	return err
+}
+
+// RecoveryCache is a size bounded cache of recovered senders shared across
+// transactions, keyed by transaction hash. It avoids recovering the sender
+// again when the same transaction is received more than once.
+type RecoveryCache struct {
+	cache *lru.Cache
+}
+
+// recoveryKey identifies a cached sender. The hash does not cover the
+// signature hash type or the queue origin, which both affect the sender
+// under the OVMSigner, so they are part of the key.
+type recoveryKey struct {
+	hash        common.Hash
+	sighashType SignatureHashType
+	deposit     bool
+}
+
+// NewRecoveryCache creates a recovery cache holding at most size senders,
+// evicting the least recently used sender when full.
+func NewRecoveryCache(size int) (*RecoveryCache, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCache{cache: cache}, nil
+}
+
+// Len returns the number of cached senders.
+func (c *RecoveryCache) Len() int {
+	return c.cache.Len()
+}
+
+// SenderCached is like Sender, but also looks up and stores the sender in
+// the given recovery cache. A nil cache is the same as calling Sender.
+func SenderCached(cache *RecoveryCache, signer Signer, tx *Transaction) (common.Address, error) {
+	if cache == nil {
+		return Sender(signer, tx)
+	}
+	if signer == nil {
+		return common.Address{}, ErrNilSigner
+	}
+	key := recoveryKey{hash: tx.Hash(), sighashType: tx.SignatureHashType(), deposit: isDeposit(tx)}
+	if cached, ok := cache.cache.Get(key); ok {
+		if sc := cached.(sigCache); sc.signer.Equal(signer) {
+			return sc.from, nil
+		}
+	}
+	addr, err := Sender(signer, tx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	cache.cache.Add(key, sigCache{signer: signer, from: addr})
+	return addr, nil
+}
+
+// recoverSenders recovers the senders of the transactions concurrently,
+// caching each result like Sender does. The first error encountered in
+// transaction order is returned.
+func recoverSenders(signer Signer, txs Transactions) ([]common.Address, error) {
+	var (
+		senders = make([]common.Address, len(txs))
+		errs    = make([]error, len(txs))
+		workers = runtime.NumCPU()
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			for i := start; i < len(txs); i += workers {
+				senders[i], errs[i] = Sender(signer, txs[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return senders, nil
+}
+
+// VerifyBatchSignatures recovers the senders of the transactions concurrently
+// and returns the index of the first transaction whose sender cannot be
+// recovered along with the error, or -1 if all signatures are valid. Workers
+// stop picking up transactions past a failure, so a bad batch is rejected
+// without recovering the rest of it.
+func VerifyBatchSignatures(signer Signer, txs Transactions) (int, error) {
+	var (
+		next    int64 = -1
+		failed        = len(txs)
+		failErr error
+		workers = runtime.NumCPU()
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(txs) {
+					return
+				}
+				mu.Lock()
+				stop := i > failed
+				mu.Unlock()
+				if stop {
+					return
+				}
+				if _, err := Sender(signer, txs[i]); err != nil {
+					mu.Lock()
+					if i < failed {
+						failed, failErr = i, err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failErr != nil {
+		return failed, failErr
+	}
+	return -1, nil
+}
+
 // Signer encapsulates transaction signature handling. Note that this interface is not a
 // stable API and may change at any time to accommodate new protocol rules.
 type Signer interface {
@@ -100,6 +437,12 @@ type Signer interface {
 
 // OVMSigner implements Signers using the EIP155 rules along with a new
 // `eth_sign` based signature hash.
+//
+// Signatures produced by the OVMSigner always use the EIP155 V encoding,
+// V = recid + 35 + 2*chainId, regardless of the signature hash type. The
+// sequencer entrypoint only takes the recovery id, which is recovered as
+// V - 35 - 2*chainId. Unprotected signatures, V = recid + 27, are still
+// accepted by Sender and are recovered using the Homestead rules.
 type OVMSigner struct {
 	EIP155Signer
 }
@@ -116,17 +459,21 @@ func (s OVMSigner) Equal(s2 Signer) bool {
 
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
+//
+// Hash is always the hash that SignTx signs, which depends on the signature
+// hash type: SighashEthSign uses the eth_sign hash and all other types use
+// the EIP155 hash. It is not the hash that unprotected transactions are
+// recovered from, see Sender.
 func (s OVMSigner) Hash(tx *Transaction) common.Hash {
-	if tx.IsEthSignSighash() {
-		msg := s.OVMSignerTemplateSighashPreimage(tx)
-
-		hasher := sha3.NewLegacyKeccak256()
-		hasher.Write(msg[:])
-		digest := hasher.Sum(nil)
-
-		return common.BytesToHash(digest)
+	switch tx.SignatureHashType() {
+	case SighashEthSign:
+		return ethSignSighash(tx, s.chainId)
+	default:
+		return eip155Sighash(tx, s.chainId)
 	}
+}
 
+func eip155Sighash(tx *Transaction, chainId *big.Int) common.Hash {
 	return rlpHash([]interface{}{
 		tx.data.AccountNonce,
 		tx.data.Price,
@@ -134,15 +481,29 @@ func (s OVMSigner) Hash(tx *Transaction) common.Hash {
 		tx.data.Recipient,
 		tx.data.Amount,
 		tx.data.Payload,
-		s.chainId, uint(0), uint(0),
+		chainId, uint(0), uint(0),
 	})
 }
 
+func ethSignSighash(tx *Transaction, chainId *big.Int) common.Hash {
+	msg := ethSignSighashPreimage(tx, chainId)
+
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(msg[:])
+	digest := hasher.Sum(nil)
+
+	return common.BytesToHash(digest)
+}
+
 // Sender will ecrecover the public key that created the signature
 // and then hash the public key to create an address. In the
 // case of L1ToL2 transactions, Layer One did the authentication
 // for us so there is no signature involved. The concept of a "from"
-// is only required for bookkeeping within this codebase
+// is only required for bookkeeping within this codebase.
+//
+// Transactions signed without replay protection are recovered as Homestead
+// transactions, from the Homestead hash. This deliberately diverges from
+// Hash, which always returns the replay protected hash that SignTx signs.
 func (s OVMSigner) Sender(tx *Transaction) (common.Address, error) {
 	qo := tx.QueueOrigin()
 	if qo != nil && qo.Uint64() == uint64(QueueOriginL1ToL2) {
@@ -162,7 +523,12 @@ func (s OVMSigner) Sender(tx *Transaction) (common.Address, error) {
 // OVMSignerTemplateSighashPreimage creates the preimage for the `eth_sign` like
 // signature hash. The transaction is `ABI.encodePacked`.
 func (s OVMSigner) OVMSignerTemplateSighashPreimage(tx *Transaction) []byte {
-	const abidata = `
+	return ethSignSighashPreimage(tx, s.chainId)
+}
+
+// ethSignABIData describes the abi encoding of the fields signed by
+// SighashEthSign transactions.
+const ethSignABIData = `
 	[
 		{
 			"type": "function",
@@ -198,17 +564,46 @@ func (s OVMSigner) OVMSignerTemplateSighashPreimage(tx *Transaction) []byte {
 	]
 	`
 
-	codec, err := abi.JSON(strings.NewReader(abidata))
+var (
+	ethSignABIOnce sync.Once
+	ethSignABI     abi.ABI
+	ethSignABIErr  error
+)
+
+// EthSignABI returns the abi used to encode SighashEthSign transactions for
+// signing. It is parsed on first use, so the cost is only paid when such
+// transactions are hashed.
+func EthSignABI() (abi.ABI, error) {
+	ethSignABIOnce.Do(func() {
+		ethSignABI, ethSignABIErr = abi.JSON(strings.NewReader(ethSignABIData))
+	})
+	return ethSignABI, ethSignABIErr
+}
+
+// ethSignSighashPreimage builds the eth_sign message signed for SighashEthSign
+// transactions. The signed payload is the 32 byte keccak256 digest of
+//
+//	abi.encode(nonce, gasLimit, gasPrice, chainId, to, data)
+//
+// so wallets sign "\x19Ethereum Signed Message:\n32" followed by the digest.
+// The value is not included, and contract creations use the zero address as
+// the target.
+func ethSignSighashPreimage(tx *Transaction, chainId *big.Int) []byte {
+	codec, err := EthSignABI()
 	if err != nil {
 		panic(fmt.Errorf("unable to create Eth Sign abi reader: %v", err))
 	}
 
+	var to common.Address
+	if tx.data.Recipient != nil {
+		to = *tx.data.Recipient
+	}
 	data := []interface{}{
 		big.NewInt(int64(tx.data.AccountNonce)),
 		big.NewInt(int64(tx.data.GasLimit)),
 		tx.data.Price,
-		s.chainId,
-		*tx.data.Recipient,
+		chainId,
+		to,
 		tx.data.Payload,
 	}
 
@@ -229,6 +624,59 @@ func (s OVMSigner) OVMSignerTemplateSighashPreimage(tx *Transaction) []byte {
 	return preimage.Bytes()
 }
 
+// SigningPreimage returns the bytes that the signer hashes with keccak256 to
+// get the hash signed for the transaction, see Signer.Hash. For SighashEthSign
+// transactions of the OVMSigner it is the eth_sign message including its
+// prefix, otherwise it is the RLP encoded list of signed fields. This lets a
+// hardware wallet hash and sign the transaction independently. It returns
+// ErrNoPreimage for signers whose hash is not computed from one of these
+// preimages.
+func (tx *Transaction) SigningPreimage(signer Signer) ([]byte, error) {
+	fields := []interface{}{
+		tx.data.AccountNonce,
+		tx.data.Price,
+		tx.data.GasLimit,
+		tx.data.Recipient,
+		tx.data.Amount,
+		tx.data.Payload,
+	}
+	var (
+		preimage []byte
+		err      error
+	)
+	switch s := signer.(type) {
+	case OVMSigner:
+		switch {
+		case !tx.Protected():
+			preimage, err = rlp.EncodeToBytes(fields)
+		case tx.SignatureHashType() == SighashEthSign:
+			preimage = ethSignSighashPreimage(tx, s.chainId)
+		default:
+			preimage, err = rlp.EncodeToBytes(append(fields, s.chainId, uint(0), uint(0)))
+		}
+	case EIP155Signer:
+		preimage, err = rlp.EncodeToBytes(append(fields, s.chainId, uint(0), uint(0)))
+	case HomesteadSigner, FrontierSigner:
+		preimage, err = rlp.EncodeToBytes(fields)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrNoPreimage, signer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if crypto.Keccak256Hash(preimage) != signer.Hash(tx) {
+		return nil, fmt.Errorf("%w: signature hash type %s", ErrNoPreimage, tx.SignatureHashType())
+	}
+	return preimage, nil
+}
+
+// SigHashUnder returns the hash that the signer signs for the transaction,
+// see Signer.Hash. It is convenient for comparing the hashes of a
+// transaction under different signers.
+func (tx *Transaction) SigHashUnder(signer Signer) common.Hash {
+	return signer.Hash(tx)
+}
+
 // EIP155Transaction implements Signer using the EIP155 rules.
 type EIP155Signer struct {
 	chainId, chainIdMul *big.Int
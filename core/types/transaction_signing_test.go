@@ -17,7 +17,10 @@
 package types
 
 import (
+	"bytes"
+	"errors"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -76,6 +79,34 @@ func TestEIP155ChainId(t *testing.T) {
 	}
 }
 
+func TestDeriveChainId(t *testing.T) {
+	key, _ := defaultTestKey()
+
+	// Both fixtures are signed with the Homestead signer and are
+	// therefore unprotected.
+	if id := rightvrsTx.DeriveChainId(); id.Sign() != 0 {
+		t.Errorf("expected chain id 0 for rightvrsTx, got %d", id)
+	}
+	if id := rightvrsTxWithL1Sender.DeriveChainId(); id.Sign() != 0 {
+		t.Errorf("expected chain id 0 for rightvrsTxWithL1Sender, got %d", id)
+	}
+	// An unsigned transaction does not encode a chain id.
+	if id := emptyTx.DeriveChainId(); id.Sign() != 0 {
+		t.Errorf("expected chain id 0 for unsigned tx, got %d", id)
+	}
+
+	for _, chainId := range []*big.Int{big.NewInt(1), big.NewInt(420), big.NewInt(1 << 32)} {
+		tx := NewTransaction(3, common.HexToAddress("b94f5374fce5edbc8e2a8697c15331677e6ebf0b"), big.NewInt(10), 2000, big.NewInt(1), common.FromHex("5544"), &sender, nil, QueueOriginSequencer, SighashEIP155)
+		tx, err := SignTx(tx, NewOVMSigner(chainId), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id := tx.DeriveChainId(); id.Cmp(chainId) != 0 {
+			t.Errorf("expected chain id %d, got %d", chainId, id)
+		}
+	}
+}
+
 func TestEIP155SigningVitalik(t *testing.T) {
 	// Test vectors come from http://vitalik.ca/files/eip155_testvec.txt
 	for i, test := range []struct {
@@ -137,6 +168,139 @@ func TestChainId(t *testing.T) {
 	}
 }
 
+func TestSignerEqual(t *testing.T) {
+	tests := []struct {
+		a, b  Signer
+		equal bool
+	}{
+		{NewOVMSigner(big.NewInt(1)), NewOVMSigner(big.NewInt(1)), true},
+		{NewOVMSigner(big.NewInt(1)), NewOVMSigner(big.NewInt(2)), false},
+		{NewOVMSigner(big.NewInt(1)), NewEIP155Signer(big.NewInt(1)), false},
+		{NewEIP155Signer(big.NewInt(1)), NewEIP155Signer(big.NewInt(1)), true},
+		{NewEIP155Signer(big.NewInt(1)), NewEIP155Signer(big.NewInt(2)), false},
+		{HomesteadSigner{}, HomesteadSigner{}, true},
+		{HomesteadSigner{}, FrontierSigner{}, false},
+		{HomesteadSigner{}, NewOVMSigner(big.NewInt(1)), false},
+	}
+	for i, test := range tests {
+		if got := test.a.Equal(test.b); got != test.equal {
+			t.Errorf("test %d: expected Equal to be %t, got %t", i, test.equal, got)
+		}
+		if got := test.b.Equal(test.a); got != test.equal {
+			t.Errorf("test %d: expected Equal to be symmetric", i)
+		}
+	}
+
+	// An equal signer reuses the cached sender
+	key, addr := defaultTestKey()
+	tx, err := SignTx(NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), NewOVMSigner(big.NewInt(1)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Sender(NewOVMSigner(big.NewInt(1)), tx); err != nil {
+		t.Fatal(err)
+	}
+	if sc := tx.from.Load().(sigCache); sc.from != addr {
+		t.Fatalf("expected cached sender %x, got %x", addr, sc.from)
+	}
+	if _, err := Sender(NewOVMSigner(big.NewInt(2)), tx); err != ErrInvalidChainId {
+		t.Fatalf("expected %v for a different signer, got %v", ErrInvalidChainId, err)
+	}
+}
+
+func TestSignTxMaxChainID(t *testing.T) {
+	key, addr := defaultTestKey()
+
+	for _, chainId := range []*big.Int{new(big.Int).Sub(MaxChainID(), common.Big1), MaxChainID()} {
+		signer := NewOVMSigner(chainId)
+		tx, err := SignTx(NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		if err != nil {
+			t.Fatalf("chain id %d: %v", chainId, err)
+		}
+		v, _, _ := tx.RawSignatureValues()
+		if !v.IsUint64() {
+			t.Fatalf("chain id %d: V does not fit in a uint64", chainId)
+		}
+		from, err := Sender(signer, tx)
+		if err != nil {
+			t.Fatalf("chain id %d: %v", chainId, err)
+		}
+		if from != addr {
+			t.Fatalf("chain id %d: expected from %x, got %x", chainId, addr, from)
+		}
+	}
+
+	// The bound cannot be widened through the returned value
+	MaxChainID().Add(MaxChainID(), common.Big1)
+	tooLarge := new(big.Int).Add(MaxChainID(), common.Big1)
+	for _, signer := range []Signer{NewOVMSigner(tooLarge), NewEIP155Signer(tooLarge)} {
+		_, err := SignTx(NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		if err != ErrChainIDTooLarge {
+			t.Fatalf("expected %v, got %v", ErrChainIDTooLarge, err)
+		}
+	}
+}
+
+func TestNilSigner(t *testing.T) {
+	key, _ := defaultTestKey()
+	tx := NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+
+	if _, err := SignTx(tx, nil, key); err != ErrNilSigner {
+		t.Fatalf("SignTx: expected %v, got %v", ErrNilSigner, err)
+	}
+	if _, err := Sender(nil, rightvrsTx); err != ErrNilSigner {
+		t.Fatalf("Sender: expected %v, got %v", ErrNilSigner, err)
+	}
+}
+
+func TestOVMSignerVOffset(t *testing.T) {
+	key, addr := defaultTestKey()
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	for _, chainId := range []*big.Int{big.NewInt(1), big.NewInt(10), big.NewInt(69), big.NewInt(420), new(big.Int).SetUint64(1 << 32)} {
+		signer := NewOVMSigner(chainId)
+		offset := new(big.Int).Add(big.NewInt(35), new(big.Int).Mul(chainId, common.Big2))
+
+		for _, sighashType := range []SignatureHashType{SighashEIP155, SighashEthSign} {
+			tx, err := SignTx(NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, sighashType), signer, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, _, _ := tx.RawSignatureValues()
+			if recid := new(big.Int).Sub(v, offset); recid.Sign() < 0 || recid.Cmp(common.Big1) > 0 {
+				t.Fatalf("chain id %d, sighash %d: V %d is not offset by %d", chainId, sighashType, v, offset)
+			}
+			if tx.ChainId().Cmp(chainId) != 0 {
+				t.Fatalf("chain id %d, sighash %d: derived chain id %d", chainId, sighashType, tx.ChainId())
+			}
+			from, err := Sender(signer, tx)
+			if err != nil {
+				t.Fatalf("chain id %d, sighash %d: %v", chainId, sighashType, err)
+			}
+			if from != addr {
+				t.Fatalf("chain id %d, sighash %d: expected from %x, got %x", chainId, sighashType, addr, from)
+			}
+		}
+
+		// Unprotected signatures use the Homestead offset and recover to the
+		// same sender under the OVM signer.
+		tx, err := SignTx(NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), HomesteadSigner{}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _, _ := tx.RawSignatureValues(); v.Uint64() != 27 && v.Uint64() != 28 {
+			t.Fatalf("chain id %d: expected homestead V, got %d", chainId, v)
+		}
+		from, err := Sender(signer, tx)
+		if err != nil {
+			t.Fatalf("chain id %d: %v", chainId, err)
+		}
+		if from != addr {
+			t.Fatalf("chain id %d: expected from %x, got %x", chainId, addr, from)
+		}
+	}
+}
+
 func TestOVMSigner(t *testing.T) {
 	key, _ := defaultTestKey()
 
@@ -180,6 +344,30 @@ func TestOVMSignerHash(t *testing.T) {
 	}
 }
 
+func TestOVMSignerHashDispatch(t *testing.T) {
+	chainId := big.NewInt(1)
+	signer := NewOVMSigner(chainId)
+
+	txEIP155 := NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if signer.Hash(txEIP155) != eip155Sighash(txEIP155, chainId) {
+		t.Error("EIP155 transaction did not dispatch to the EIP155 hash")
+	}
+	if signer.Hash(txEIP155) != NewEIP155Signer(chainId).Hash(txEIP155) {
+		t.Error("EIP155 hash should match the EIP155Signer")
+	}
+
+	txEthSign := NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEthSign)
+	if signer.Hash(txEthSign) != ethSignSighash(txEthSign, chainId) {
+		t.Error("EthSign transaction did not dispatch to the EthSign hash")
+	}
+
+	// Other types use the EIP155 hash
+	txEOA := NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, CreateEOA)
+	if signer.Hash(txEOA) != eip155Sighash(txEOA, chainId) {
+		t.Error("CreateEOA transaction should use the EIP155 hash")
+	}
+}
+
 func TestOVMSignerSender(t *testing.T) {
 	// Create a keypair to sign transactions with and the corresponding address
 	// from the public key.
@@ -227,3 +415,581 @@ func TestOVMSignerSender(t *testing.T) {
 		t.Errorf("Recovered address doesn't match. Got %s, expected %s", recEthSign.Hex(), addr.Hex())
 	}
 }
+
+func TestOVMSignerEthSignWalletSignature(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	chainId := big.NewInt(420)
+	signer := NewOVMSigner(chainId)
+
+	to := common.HexToAddress("0x1212121212121212121212121212121212121212")
+	data := []byte{0xde, 0xad, 0xbe, 0xef}
+	tx := NewTransaction(7, to, big.NewInt(5), 100000, big.NewInt(2000000), data, nil, nil, QueueOriginSequencer, SighashEthSign)
+
+	// Build the documented message independently of the signer: the abi
+	// encoding of (nonce, gasLimit, gasPrice, chainId, to, data).
+	word := func(x *big.Int) []byte { return common.LeftPadBytes(x.Bytes(), 32) }
+	var encoded []byte
+	encoded = append(encoded, word(big.NewInt(7))...)
+	encoded = append(encoded, word(big.NewInt(100000))...)
+	encoded = append(encoded, word(big.NewInt(2000000))...)
+	encoded = append(encoded, word(chainId)...)
+	encoded = append(encoded, common.LeftPadBytes(to.Bytes(), 32)...)
+	encoded = append(encoded, word(big.NewInt(6*32))...)
+	encoded = append(encoded, word(big.NewInt(int64(len(data))))...)
+	encoded = append(encoded, common.RightPadBytes(data, 32)...)
+
+	// A wallet eth_sign signs the prefixed digest of the message
+	hash := crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n32"), crypto.Keccak256(encoded))
+	if signerHash := signer.Hash(tx); !bytes.Equal(signerHash.Bytes(), hash) {
+		t.Fatalf("signer hash %s does not match the documented message", signerHash.Hex())
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := tx.WithSignature(signer, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := Sender(signer, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != addr {
+		t.Fatalf("recovered %s, expected %s", from.Hex(), addr.Hex())
+	}
+
+	// The value is not part of the signed message
+	other := NewTransaction(7, to, big.NewInt(6), 100000, big.NewInt(2000000), data, nil, nil, QueueOriginSequencer, SighashEthSign)
+	if signer.Hash(other) != signer.Hash(tx) {
+		t.Fatal("expected the value to be excluded from the EthSign hash")
+	}
+	// Contract creations sign the zero address as the target
+	creation := NewContractCreation(0, new(big.Int), 100000, big.NewInt(2000000), data, nil, nil, QueueOriginSequencer)
+	creation.SetSignatureHashType(SighashEthSign)
+	zero := NewTransaction(0, common.Address{}, new(big.Int), 100000, big.NewInt(2000000), data, nil, nil, QueueOriginSequencer, SighashEthSign)
+	if signer.Hash(creation) != signer.Hash(zero) {
+		t.Fatal("expected a creation to hash like a transaction to the zero address")
+	}
+}
+
+func TestEthSignABIParsedOnce(t *testing.T) {
+	first, err := EthSignABI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := EthSignABI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := first.Methods["encode"]; !ok {
+		t.Fatal("expected the encode method in the eth sign abi")
+	}
+	// A second parse would allocate a new method map
+	if reflect.ValueOf(first.Methods).Pointer() != reflect.ValueOf(second.Methods).Pointer() {
+		t.Fatal("expected the eth sign abi to be parsed once")
+	}
+}
+
+func TestRecoveryCacheEviction(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	signer := NewOVMSigner(big.NewInt(420))
+
+	cache, err := NewRecoveryCache(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs Transactions
+	for i := uint64(0); i < 3; i++ {
+		tx, err := SignTx(NewTransaction(i, common.Address{}, new(big.Int), 0, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, err := SenderCached(cache, signer, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if from != addr {
+			t.Fatalf("tx %d: expected sender %x, got %x", i, addr, from)
+		}
+		txs = append(txs, tx)
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 cached senders, got %d", cache.Len())
+	}
+	keyOf := func(tx *Transaction) recoveryKey {
+		return recoveryKey{hash: tx.Hash(), sighashType: tx.SignatureHashType()}
+	}
+	if cache.cache.Contains(keyOf(txs[0])) {
+		t.Fatal("expected the least recently used sender to be evicted")
+	}
+	for _, tx := range txs[1:] {
+		if !cache.cache.Contains(keyOf(tx)) {
+			t.Fatalf("expected sender of tx %x to be cached", tx.Hash())
+		}
+	}
+
+	// A cached sender is returned without recovering it from the signature,
+	// even for a transaction that has not been seen before
+	fake := common.HexToAddress("0xdeadbeef")
+	cache.cache.Add(keyOf(txs[2]), sigCache{signer: signer, from: fake})
+	enc, err := rlp.EncodeToBytes(txs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup := new(Transaction)
+	if err := rlp.DecodeBytes(enc, dup); err != nil {
+		t.Fatal(err)
+	}
+	if from, _ := SenderCached(cache, signer, dup); from != fake {
+		t.Fatalf("expected the cached sender %x, got %x", fake, from)
+	}
+
+	if _, err := NewRecoveryCache(0); err == nil {
+		t.Fatal("expected an error for a zero cache size")
+	}
+}
+
+func TestRecoverable(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewOVMSigner(big.NewInt(420))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	tx, err := SignTx(NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Recoverable(signer, tx); err != nil {
+		t.Fatalf("expected a valid signature to be recoverable, got %v", err)
+	}
+
+	// An s value above the curve order is malformed
+	v, r, _ := tx.RawSignatureValues()
+	malformed := tx.AsLegacy()
+	malformed.data.V, malformed.data.R, malformed.data.S = v, r, new(big.Int).Lsh(common.Big1, 256)
+	if err := Recoverable(signer, malformed); err != ErrInvalidSig {
+		t.Fatalf("expected %v for a malformed signature, got %v", ErrInvalidSig, err)
+	}
+
+	unsigned := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if err := Recoverable(signer, unsigned); err != ErrUnsignedTransaction {
+		t.Fatalf("expected %v for an unsigned transaction, got %v", ErrUnsignedTransaction, err)
+	}
+
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &l1Sender, nil, QueueOriginL1ToL2, SighashEIP155)
+	if err := Recoverable(signer, deposit); err != nil {
+		t.Fatalf("expected a deposit to be recoverable, got %v", err)
+	}
+	deposit = NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginL1ToL2, SighashEIP155)
+	if err := Recoverable(signer, deposit); err != ErrNoL1MessageSender {
+		t.Fatalf("expected %v for a deposit without l1 sender, got %v", ErrNoL1MessageSender, err)
+	}
+}
+
+func TestValidateChainID(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func() *Transaction {
+		return NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	}
+
+	protected, err := SignTx(newTx(), NewEIP155Signer(big.NewInt(420)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateChainID(protected, big.NewInt(420), false); err != nil {
+		t.Fatalf("expected a matching chain id to be accepted, got %v", err)
+	}
+	if err := ValidateChainID(protected, big.NewInt(1), true); !errors.Is(err, ErrChainIDMismatch) {
+		t.Fatalf("expected %v, got %v", ErrChainIDMismatch, err)
+	}
+
+	unprotected, err := SignTx(newTx(), HomesteadSigner{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateChainID(unprotected, big.NewInt(420), true); err != nil {
+		t.Fatalf("expected an unprotected transaction to be accepted, got %v", err)
+	}
+	if err := ValidateChainID(unprotected, big.NewInt(420), false); err != ErrUnprotectedTx {
+		t.Fatalf("expected %v, got %v", ErrUnprotectedTx, err)
+	}
+}
+
+func TestOVMSignerHashUnprotected(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	signer := NewOVMSigner(big.NewInt(420))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	tx, err := SignTx(NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), HomesteadSigner{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Protected() {
+		t.Fatal("expected a homestead transaction to be unprotected")
+	}
+	// Unprotected transactions are recovered from the Homestead hash, but
+	// Hash is the replay protected hash that SignTx signs.
+	if signer.Hash(tx) == (HomesteadSigner{}).Hash(tx) {
+		t.Fatal("expected the replay protected hash for an unprotected transaction")
+	}
+	from, err := Sender(signer, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != addr {
+		t.Fatalf("expected sender %x, got %x", addr, from)
+	}
+	// Signing an unprotected transaction replaces its signature with a
+	// replay protected one that recovers to the same sender.
+	resigned, err := SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resigned.Protected() {
+		t.Fatal("expected the re-signed transaction to be protected")
+	}
+	if from, err := Sender(signer, resigned); err != nil || from != addr {
+		t.Fatalf("expected sender %x, got %x (%v)", addr, from, err)
+	}
+
+	// Before signing, the hash is the replay protected one that SignTx signs
+	unsigned := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if signer.Hash(unsigned) == (HomesteadSigner{}).Hash(unsigned) {
+		t.Fatal("expected an unsigned transaction to get the replay protected hash")
+	}
+	signed, err := SignTx(unsigned, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.Hash(signed) != signer.Hash(unsigned) {
+		t.Fatal("expected signing not to change the hash of a protected transaction")
+	}
+}
+
+func TestValidateEthSign(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	signer := NewOVMSigner(big.NewInt(420))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func(sighashType SignatureHashType) *Transaction {
+		return NewTransaction(0, to, new(big.Int), 21000, big.NewInt(1), nil, nil, nil, QueueOriginSequencer, sighashType)
+	}
+
+	signed, err := SignTx(newTx(SighashEthSign), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateEthSign(signer, signed, addr); err != nil {
+		t.Fatalf("expected a personal message signature to be accepted, got %v", err)
+	}
+
+	// Sign the EIP155 hash but declare SighashEthSign
+	tx := newTx(SighashEthSign)
+	h := eip155Sighash(tx, big.NewInt(420))
+	sig, err := crypto.Sign(h[:], key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	misSigned, err := tx.WithSignature(signer, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateEthSign(signer, misSigned, addr); err != ErrSighashMismatch {
+		t.Fatalf("expected %v, got %v", ErrSighashMismatch, err)
+	}
+
+	// Other signature hash types are not checked
+	eip155, err := SignTx(newTx(SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateEthSign(signer, eip155, common.Address{}); err != nil {
+		t.Fatalf("expected an EIP155 transaction to be skipped, got %v", err)
+	}
+}
+
+func TestNewCancelTransaction(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	signer := NewOVMSigner(big.NewInt(420))
+
+	tx, err := NewCancelTransaction(7, addr, big.NewInt(2000000000), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := Sender(signer, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != addr {
+		t.Fatalf("expected sender %x, got %x", addr, from)
+	}
+	if tx.Nonce() != 7 {
+		t.Fatalf("expected nonce 7, got %d", tx.Nonce())
+	}
+	if tx.To() == nil || *tx.To() != addr || tx.Value().Sign() != 0 || len(tx.Data()) != 0 {
+		t.Fatal("expected a zero value self send without data")
+	}
+	if tx.GasPrice().Cmp(big.NewInt(2000000000)) != 0 {
+		t.Fatalf("expected gas price 2000000000, got %d", tx.GasPrice())
+	}
+
+	other, _ := crypto.GenerateKey()
+	if _, err := NewCancelTransaction(7, addr, big.NewInt(1), signer, other); err != ErrKeyMismatch {
+		t.Fatalf("expected %v, got %v", ErrKeyMismatch, err)
+	}
+}
+
+func TestResignBatch(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	oldSigner, newSigner := NewOVMSigner(big.NewInt(420)), NewOVMSigner(big.NewInt(421))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	var txs Transactions
+	for i, sighashType := range []SignatureHashType{SighashEIP155, SighashEthSign} {
+		tx := NewTransaction(uint64(i), to, new(big.Int), 21000, big.NewInt(1), nil, nil, big.NewInt(10), QueueOriginSequencer, sighashType)
+		tx.SetL1Timestamp(100)
+		signed, err := SignTx(tx, oldSigner, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, signed)
+	}
+	// Transactions signed without replay protection recover under the OVM
+	// signer too and are re-signed with replay protection
+	homestead, err := SignTx(NewTransaction(2, to, new(big.Int), 21000, big.NewInt(1), nil, nil, big.NewInt(10), QueueOriginSequencer, SighashEIP155), HomesteadSigner{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs = append(txs, homestead)
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, big.NewInt(10), QueueOriginL1ToL2, SighashEIP155)
+	txs = append(txs, deposit)
+
+	resigned, err := ResignBatch(txs, oldSigner, newSigner, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resigned) != len(txs) {
+		t.Fatalf("expected %d transactions, got %d", len(txs), len(resigned))
+	}
+	for i, tx := range resigned[:3] {
+		from, err := Sender(newSigner, tx)
+		if err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+		if from != addr {
+			t.Fatalf("tx %d: expected sender %x, got %x", i, addr, from)
+		}
+		if tx.ChainId().Cmp(big.NewInt(421)) != 0 {
+			t.Fatalf("tx %d: expected chain id 421, got %d", i, tx.ChainId())
+		}
+		if !reflect.DeepEqual(tx.meta, txs[i].meta) {
+			t.Fatalf("tx %d: metadata not preserved", i)
+		}
+	}
+	if !resigned[2].Protected() {
+		t.Fatal("expected the homestead transaction to be re-signed with replay protection")
+	}
+	if resigned[3] != deposit {
+		t.Fatal("expected the deposit to be returned unchanged")
+	}
+
+	// Transactions of another sender cannot be re-signed
+	other, _ := crypto.GenerateKey()
+	if _, err := ResignBatch(txs, oldSigner, newSigner, other); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("expected %v, got %v", ErrKeyMismatch, err)
+	}
+	// Transactions must recover under the old signer
+	if _, err := ResignBatch(txs, newSigner, newSigner, key); !errors.Is(err, ErrInvalidChainId) {
+		t.Fatalf("expected %v, got %v", ErrInvalidChainId, err)
+	}
+	// Signatures that do not recover under the new signer are rejected
+	if _, err := ResignBatch(txs, oldSigner, homesteadHashSigner{newSigner}, key); !errors.Is(err, ErrResignMismatch) {
+		t.Fatalf("expected %v, got %v", ErrResignMismatch, err)
+	}
+}
+
+// homesteadHashSigner signs the Homestead hash but labels the signature as
+// replay protected, so its signatures do not recover to the signer.
+type homesteadHashSigner struct{ OVMSigner }
+
+func (s homesteadHashSigner) Hash(tx *Transaction) common.Hash {
+	return HomesteadSigner{}.Hash(tx)
+}
+
+func TestAssertSameChainID(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	sign := func(nonce uint64, signer Signer) *Transaction {
+		tx, err := SignTx(NewTransaction(nonce, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	signer := NewOVMSigner(big.NewInt(420))
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+
+	same := Transactions{sign(0, signer), deposit, sign(1, HomesteadSigner{}), sign(2, signer)}
+	if err := same.AssertSameChainID(); err != nil {
+		t.Fatalf("expected a single chain id to be accepted, got %v", err)
+	}
+	mixed := Transactions{sign(0, signer), deposit, sign(1, NewOVMSigner(big.NewInt(1))), sign(2, signer)}
+	if err := mixed.AssertSameChainID(); !errors.Is(err, ErrChainIDMismatch) {
+		t.Fatalf("expected %v, got %v", ErrChainIDMismatch, err)
+	}
+	if err := (Transactions{}).AssertSameChainID(); err != nil {
+		t.Fatalf("expected an empty batch to be accepted, got %v", err)
+	}
+}
+
+func TestValidateSignatureFormat(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := SignTx(NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), NewOVMSigner(big.NewInt(420)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, R, S := tx.RawSignatureValues()
+	N := crypto.S256().Params().N
+	highS := new(big.Int).Sub(N, S)
+
+	tests := []struct {
+		v, r, s *big.Int
+		err     error
+	}{
+		{v: big.NewInt(0), r: R, s: S},
+		{v: big.NewInt(1), r: R, s: S},
+		{v: big.NewInt(27), r: R, s: S},
+		{v: big.NewInt(28), r: R, s: S},
+		{v: big.NewInt(35), r: R, s: S},
+		{v: big.NewInt(36), r: R, s: S},
+		{v: big.NewInt(35 + 2*420), r: R, s: S},
+		{v: big.NewInt(2), r: R, s: S, err: ErrInvalidSig},
+		{v: big.NewInt(26), r: R, s: S, err: ErrInvalidSig},
+		{v: big.NewInt(29), r: R, s: S, err: ErrInvalidSig},
+		{v: big.NewInt(34), r: R, s: S, err: ErrInvalidSig},
+		{v: big.NewInt(27), r: new(big.Int), s: S, err: ErrInvalidSig},
+		{v: big.NewInt(27), r: R, s: new(big.Int), err: ErrInvalidSig},
+		{v: big.NewInt(27), r: N, s: S, err: ErrInvalidSig},
+		{v: big.NewInt(27), r: R, s: highS, err: ErrInvalidSig},
+	}
+	for i, test := range tests {
+		cpy := *tx
+		cpy.data.V, cpy.data.R, cpy.data.S = test.v, test.r, test.s
+		if err := ValidateSignatureFormat(&cpy); err != test.err {
+			t.Errorf("test %d: expected %v, got %v", i, test.err, err)
+		}
+	}
+	if err := ValidateSignatureFormat(tx); err != nil {
+		t.Fatalf("expected signed transaction to be accepted, got %v", err)
+	}
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	if err := ValidateSignatureFormat(deposit); err != nil {
+		t.Fatalf("expected unsigned deposit to be accepted, got %v", err)
+	}
+}
+
+func TestSigningPreimage(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func(sighashType SignatureHashType) *Transaction {
+		return NewTransaction(3, to, big.NewInt(10), 21000, big.NewInt(1000000), []byte{1, 2, 3}, nil, nil, QueueOriginSequencer, sighashType)
+	}
+	ovm := NewOVMSigner(big.NewInt(420))
+
+	tests := []struct {
+		name   string
+		tx     *Transaction
+		signer Signer
+	}{
+		{"ovm eip155", newTx(SighashEIP155), ovm},
+		{"ovm ethsign", newTx(SighashEthSign), ovm},
+		{"eip155", newTx(SighashEIP155), NewEIP155Signer(big.NewInt(420))},
+		{"homestead", newTx(SighashEIP155), HomesteadSigner{}},
+	}
+	for _, test := range tests {
+		preimage, err := test.tx.SigningPreimage(test.signer)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if crypto.Keccak256Hash(preimage) != test.signer.Hash(test.tx) {
+			t.Fatalf("%s: preimage does not hash to the signing hash", test.name)
+		}
+	}
+
+	// The EthSign preimage is the prefixed eth_sign message
+	preimage, _ := newTx(SighashEthSign).SigningPreimage(ovm)
+	prefix := []byte("\x19Ethereum Signed Message:\n32")
+	if len(preimage) != len(prefix)+32 || !bytes.HasPrefix(preimage, prefix) {
+		t.Fatalf("expected prefixed eth_sign message, got %x", preimage)
+	}
+	// The EIP155 preimage is the RLP list of the fields and the chain id
+	preimage, _ = newTx(SighashEIP155).SigningPreimage(ovm)
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(preimage, &fields); err != nil || len(fields) != 9 {
+		t.Fatalf("expected an RLP list of 9 fields, got %d (%v)", len(fields), err)
+	}
+
+	// Unknown signers have no known preimage
+	type wrappedSigner struct{ EIP155Signer }
+	if _, err := newTx(SighashEIP155).SigningPreimage(wrappedSigner{NewEIP155Signer(big.NewInt(1))}); !errors.Is(err, ErrNoPreimage) {
+		t.Fatalf("expected %v, got %v", ErrNoPreimage, err)
+	}
+}
+
+func TestVerifyBatchSignatures(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewEIP155Signer(big.NewInt(18))
+	other := NewEIP155Signer(big.NewInt(19))
+
+	newBatch := func(bad ...int) Transactions {
+		txs := make(Transactions, 20)
+		for i := range txs {
+			s := signer
+			for _, b := range bad {
+				if i == b {
+					s = other
+				}
+			}
+			tx, err := SignTx(NewTransaction(uint64(i), common.Address{1}, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), s, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			txs[i] = tx
+		}
+		return txs
+	}
+	if index, err := VerifyBatchSignatures(signer, newBatch()); index != -1 || err != nil {
+		t.Errorf("valid batch: expected -1, nil, got %d, %v", index, err)
+	}
+	if index, err := VerifyBatchSignatures(signer, newBatch(10)); index != 10 || err != ErrInvalidChainId {
+		t.Errorf("bad batch: expected 10, %v, got %d, %v", ErrInvalidChainId, index, err)
+	}
+	if index, err := VerifyBatchSignatures(signer, newBatch(7, 13)); index != 7 || err != ErrInvalidChainId {
+		t.Errorf("two bad transactions: expected 7, %v, got %d, %v", ErrInvalidChainId, index, err)
+	}
+	if index, err := VerifyBatchSignatures(signer, nil); index != -1 || err != nil {
+		t.Errorf("empty batch: expected -1, nil, got %d, %v", index, err)
+	}
+}
+
+func TestSigHashUnder(t *testing.T) {
+	homestead := rightvrsTx.SigHashUnder(HomesteadSigner{})
+	if homestead != (HomesteadSigner{}).Hash(rightvrsTx) {
+		t.Fatalf("expected the homestead signer hash, got %x", homestead)
+	}
+	// The OVM signer signs the replay protected hash, even for unprotected
+	// transactions
+	if ovm := rightvrsTx.SigHashUnder(NewOVMSigner(big.NewInt(1))); ovm == homestead {
+		t.Fatal("expected the OVM sighash to differ from the homestead sighash")
+	}
+	if eip155 := rightvrsTx.SigHashUnder(NewEIP155Signer(big.NewInt(1))); eip155 == homestead {
+		t.Fatal("expected the EIP155 sighash to differ from the homestead sighash")
+	}
+}
@@ -0,0 +1,144 @@
+/**
+ * Optimism 2020 Copyright
+ */
+
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// ErrUnknownTxTag is returned when decoding a tagged transaction with a
+	// tag that is neither TxTagSequencer nor TxTagDeposit.
+	ErrUnknownTxTag = errors.New("unknown transaction tag")
+
+	// ErrInvalidSequencerEnc is returned when decoding a tagged sequencer
+	// transaction with more than one L1 block number.
+	ErrInvalidSequencerEnc = errors.New("invalid sequencer transaction encoding")
+)
+
+// The tags of the tagged transaction encoding, which tell the two kinds of
+// transactions apart when they are stored in a single stream.
+const (
+	TxTagSequencer byte = 0
+	TxTagDeposit   byte = 1
+)
+
+// sequencerEncoding is the RLP layout of a tagged sequencer transaction. The
+// signature hash type is kept, since the sender of the transaction cannot be
+// recovered without it.
+type sequencerEncoding struct {
+	Data              txdata
+	SignatureHashType SignatureHashType
+	L1Timestamp       uint64
+	L1MessageSender   *common.Address `rlp:"nil"`
+	L1BlockNumber     []*big.Int
+}
+
+// MarshalTagged returns the tagged encoding of the transaction, which is a
+// tag byte followed by the RLP encoding of the transaction and the metadata
+// of its kind. Each encoding is self delimiting, so tagged sequencer
+// transactions and deposits can be concatenated into a single stream and read
+// back with DecodeTaggedTransactions. Deposits use the layout of the deposit
+// binary encoding, see MarshalBinary.
+func (tx *Transaction) MarshalTagged() ([]byte, error) {
+	var (
+		tag byte
+		val interface{}
+	)
+	if isDeposit(tx) {
+		tag, val = TxTagDeposit, tx.depositEncoding()
+	} else {
+		enc := &sequencerEncoding{
+			Data:              tx.data,
+			SignatureHashType: tx.meta.SignatureHashType,
+			L1Timestamp:       tx.meta.L1Timestamp,
+			L1MessageSender:   tx.meta.L1MessageSender,
+		}
+		if tx.meta.L1BlockNumber != nil {
+			enc.L1BlockNumber = []*big.Int{tx.meta.L1BlockNumber}
+		}
+		tag, val = TxTagSequencer, enc
+	}
+	enc, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tag}, enc...), nil
+}
+
+// UnmarshalTagged decodes a single tagged transaction encoded by
+// MarshalTagged.
+func (tx *Transaction) UnmarshalTagged(b []byte) error {
+	r := bytes.NewReader(b)
+	if err := tx.readTagged(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", rlp.ErrMoreThanOneValue, r.Len())
+	}
+	return nil
+}
+
+// DecodeTaggedTransactions decodes a stream of concatenated tagged
+// transactions, see MarshalTagged.
+func DecodeTaggedTransactions(b []byte) (Transactions, error) {
+	var (
+		txs Transactions
+		r   = bytes.NewReader(b)
+	)
+	for r.Len() > 0 {
+		tx := new(Transaction)
+		if err := tx.readTagged(r); err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", len(txs), err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// readTagged reads the next tagged transaction from r.
+func (tx *Transaction) readTagged(r *bytes.Reader) error {
+	tag, err := r.ReadByte()
+	if err == io.EOF {
+		return rlp.EOL
+	}
+	s := rlp.NewStream(r, uint64(r.Len()))
+	switch tag {
+	case TxTagSequencer:
+		var dec sequencerEncoding
+		if err := s.Decode(&dec); err != nil {
+			return err
+		}
+		if len(dec.L1BlockNumber) > 1 {
+			return ErrInvalidSequencerEnc
+		}
+		tx.data = dec.Data
+		tx.meta = TransactionMeta{
+			L1Timestamp:       dec.L1Timestamp,
+			L1MessageSender:   dec.L1MessageSender,
+			SignatureHashType: dec.SignatureHashType,
+			QueueOrigin:       big.NewInt(int64(QueueOriginSequencer)),
+		}
+		if len(dec.L1BlockNumber) == 1 {
+			tx.meta.L1BlockNumber = dec.L1BlockNumber[0]
+		}
+		return nil
+	case TxTagDeposit:
+		var dec depositEncoding
+		if err := s.Decode(&dec); err != nil {
+			return err
+		}
+		return tx.setDepositEncoding(&dec)
+	default:
+		return fmt.Errorf("%w: %d", ErrUnknownTxTag, tag)
+	}
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTransactionTaggedRoundTrip(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newDeposit := func(queueIndex uint64) *Transaction {
+		deposit := NewTransaction(queueIndex, to, new(big.Int), 21000, new(big.Int), []byte{1, 2, 3}, &sender, big.NewInt(12), QueueOriginL1ToL2, SighashEIP155)
+		deposit.SetL1Timestamp(1600000000)
+		deposit.SetQueueIndex(queueIndex)
+		return deposit
+	}
+	creation := NewContractCreation(1, big.NewInt(1), 100000, big.NewInt(1), common.FromHex("6000"), nil, nil, QueueOriginSequencer)
+
+	// Sequencer transactions and deposits interleaved in a single stream
+	txs := Transactions{
+		rightvrsTx,
+		newDeposit(0),
+		emptyTxSighashEthSign,
+		rightvrsTxWithL1BlockNumber,
+		newDeposit(1),
+		rightvrsTxWithL1Sender,
+		creation,
+	}
+	var stream []byte
+	for i, tx := range txs {
+		enc, err := tx.MarshalTagged()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag := TxTagSequencer
+		if isDeposit(tx) {
+			tag = TxTagDeposit
+		}
+		if enc[0] != tag {
+			t.Fatalf("tx %d: expected tag %d, got %d", i, tag, enc[0])
+		}
+		decoded := new(Transaction)
+		if err := decoded.UnmarshalTagged(enc); err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+		if diff := tx.Diff(decoded); len(diff) != 0 {
+			t.Fatalf("tx %d: decoded transaction differs: %s", i, strings.Join(diff, ", "))
+		}
+		stream = append(stream, enc...)
+	}
+
+	decoded, err := DecodeTaggedTransactions(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(txs) {
+		t.Fatalf("expected %d transactions, got %d", len(txs), len(decoded))
+	}
+	for i, tx := range txs {
+		if diff := tx.Diff(decoded[i]); len(diff) != 0 {
+			t.Fatalf("tx %d: decoded transaction differs: %s", i, strings.Join(diff, ", "))
+		}
+	}
+}
+
+func TestTransactionTaggedInvalid(t *testing.T) {
+	enc, err := rightvrsTx.MarshalTagged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := append([]byte{0x7f}, enc[1:]...)
+	if err := new(Transaction).UnmarshalTagged(unknown); !errors.Is(err, ErrUnknownTxTag) {
+		t.Fatalf("expected %v, got %v", ErrUnknownTxTag, err)
+	}
+	if _, err := DecodeTaggedTransactions(append(enc, unknown...)); !errors.Is(err, ErrUnknownTxTag) {
+		t.Fatalf("expected %v, got %v", ErrUnknownTxTag, err)
+	}
+	if err := new(Transaction).UnmarshalTagged(append(enc, 0)); err == nil {
+		t.Fatal("expected trailing bytes to be rejected")
+	}
+	if err := new(Transaction).UnmarshalTagged(enc[:len(enc)-1]); err == nil {
+		t.Fatal("expected truncated encoding to be rejected")
+	}
+	if txs, err := DecodeTaggedTransactions(nil); err != nil || len(txs) != 0 {
+		t.Fatalf("expected empty stream to decode to no transactions, got %d (%v)", len(txs), err)
+	}
+}
@@ -18,12 +18,18 @@ package types
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -63,6 +69,34 @@ func TestTransactionSigHash(t *testing.T) {
 	}
 }
 
+func TestTransactionStreamingHash(t *testing.T) {
+	to := common.HexToAddress("b94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	sizes := []int{0, 1, 55, 56, 1024, streamingHashThreshold, streamingHashThreshold + 1, 1 << 20}
+	for _, size := range sizes {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		tx, err := NewTransaction(3, to, big.NewInt(10), 2000, big.NewInt(1), data, nil, nil, QueueOriginSequencer, SighashEIP155).WithSignature(
+			HomesteadSigner{},
+			common.Hex2Bytes("98ff921201554726367d2be8c804a7ff89ccf285ebc57dff8ae4c44b9c19ac4a8887321be575c8095f789dd4c743dfe42c1820f9231f98a962b210e3ac2452a301"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := streamingRlpHash(&tx.data), rlpHash(tx); have != want {
+			t.Fatalf("size %d: streaming hash mismatch: have %x, want %x", size, have, want)
+		}
+		if have, want := tx.Hash(), rlpHash(tx); have != want {
+			t.Fatalf("size %d: hash mismatch: have %x, want %x", size, have, want)
+		}
+	}
+	creation := NewContractCreation(0, new(big.Int), 100000, new(big.Int), make([]byte, streamingHashThreshold+1), nil, nil, QueueOriginSequencer)
+	if have, want := creation.Hash(), rlpHash(creation); have != want {
+		t.Fatalf("creation hash mismatch: have %x, want %x", have, want)
+	}
+}
+
 func TestTransactionEncode(t *testing.T) {
 	txb, err := rlp.EncodeToBytes(rightvrsTx)
 	if err != nil {
@@ -149,6 +183,76 @@ func TestRecipientNormal(t *testing.T) {
 	}
 }
 
+func TestTransactionIsZero(t *testing.T) {
+	var zero Transaction
+	if !zero.IsZero() {
+		t.Error("expected zero value transaction to be zero")
+	}
+
+	tx, err := decodeTx(common.Hex2Bytes("f85d80808094000000000000000000000000000000000000000080011ca0527c0d8f5c63f7b9f41324a7c8a563ee1190bcbf0dac8ab446291bdbf32f5c79a0552c4ef0a09a04395074dab9ed34d3fbfb843c2f2546cc30fe89ec143ca94ca6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.IsZero() {
+		t.Error("expected decoded transaction not to be zero")
+	}
+
+	tx, err = decodeTx([]byte{0x01})
+	if err == nil {
+		t.Fatal("expected decode error")
+	}
+	if !tx.IsZero() {
+		t.Error("expected failed decode to leave a zero transaction")
+	}
+
+	if emptyTx.IsZero() {
+		t.Error("expected constructed transaction not to be zero")
+	}
+}
+
+// Tests that draining a price and nonce sorted set stops once the deadline
+// passes, leaving the remaining transactions in the set.
+func TestTransactionPriceNonceDeadline(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	signer := HomesteadSigner{}
+	groups := map[common.Address]Transactions{}
+	for i := 0; i < 10; i++ {
+		tx, _ := SignTx(NewTransaction(uint64(i), common.Address{}, big.NewInt(100), 100, big.NewInt(1), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		groups[addr] = append(groups[addr], tx)
+	}
+	txset := NewTransactionsByPriceAndNonce(signer, groups)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	txs := Transactions{}
+	for tx := txset.PeekWithDeadline(ctx); tx != nil; tx = txset.PeekWithDeadline(ctx) {
+		txs = append(txs, tx)
+		txset.Shift()
+		if len(txs) == 3 {
+			<-ctx.Done()
+		}
+	}
+	if len(txs) != 3 {
+		t.Fatalf("drained %d transactions, want 3", len(txs))
+	}
+	for i, tx := range txs {
+		if tx.Nonce() != uint64(i) {
+			t.Errorf("tx %d: nonce %d, want %d", i, tx.Nonce(), i)
+		}
+	}
+	if tx := txset.Peek(); tx == nil || tx.Nonce() != 3 {
+		t.Errorf("remaining head mismatch: have %v, want nonce 3", tx)
+	}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if tx := txset.PeekWithDeadline(expired); tx != nil {
+		t.Errorf("expired deadline returned transaction with nonce %d", tx.Nonce())
+	}
+}
+
 // Tests that transactions can be correctly sorted according to their price in
 // decreasing order, but at the same time with increasing nonces when issued by
 // the same account.
@@ -180,6 +284,9 @@ func TestTransactionPriceNonceSort(t *testing.T) {
 	if len(txs) != 25*25 {
 		t.Errorf("expected %d transactions, found %d", 25*25, len(txs))
 	}
+	if err := AssertSenderNonceContiguity(txs, signer); err != nil {
+		t.Error(err)
+	}
 	for i, txi := range txs {
 		fromi, _ := Sender(signer, txi)
 
@@ -203,6 +310,33 @@ func TestTransactionPriceNonceSort(t *testing.T) {
 	}
 }
 
+func TestAssertSenderNonceContiguity(t *testing.T) {
+	keys := []*ecdsa.PrivateKey{}
+	for i := 0; i < 2; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+	}
+	signer := HomesteadSigner{}
+	sign := func(key *ecdsa.PrivateKey, nonce uint64) *Transaction {
+		tx, _ := SignTx(NewTransaction(nonce, common.Address{}, big.NewInt(100), 100, big.NewInt(1), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		return tx
+	}
+	deposit := NewTransaction(9, common.Address{}, new(big.Int), 100, new(big.Int), nil, &common.Address{}, nil, QueueOriginL1ToL2, SighashEIP155)
+
+	interleaved := Transactions{sign(keys[0], 3), sign(keys[1], 0), deposit, sign(keys[0], 4), sign(keys[1], 1)}
+	if err := AssertSenderNonceContiguity(interleaved, signer); err != nil {
+		t.Errorf("interleaved senders: %v", err)
+	}
+	gap := Transactions{sign(keys[0], 0), sign(keys[1], 0), sign(keys[0], 2)}
+	if err := AssertSenderNonceContiguity(gap, signer); !errors.Is(err, ErrNonceGap) {
+		t.Errorf("nonce gap: expected %v, got %v", ErrNonceGap, err)
+	}
+	reordered := Transactions{sign(keys[0], 1), sign(keys[0], 0)}
+	if err := AssertSenderNonceContiguity(reordered, signer); !errors.Is(err, ErrNonceGap) {
+		t.Errorf("reordered nonces: expected %v, got %v", ErrNonceGap, err)
+	}
+}
+
 // TestTransactionJSON tests serializing/de-serializing to/from JSON.
 func TestTransactionJSON(t *testing.T) {
 	key, err := crypto.GenerateKey()
@@ -241,9 +375,20 @@ func TestTransactionJSON(t *testing.T) {
 			t.Fatalf("json.Unmarshal failed: %v", err)
 		}
 
+		// Legacy transactions have none of the typed transaction fields
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("json.Unmarshal failed: %v", err)
+		}
+		for _, field := range []string{"type", "maxFeePerGas", "maxPriorityFeePerGas", "accessList"} {
+			if _, ok := fields[field]; ok {
+				t.Errorf("legacy transaction json contains %q", field)
+			}
+		}
+
 		// compare nonce, price, gaslimit, recipient, amount, payload, V, R, S
 		if tx.Hash() != parsedTx.Hash() {
-			t.Errorf("parsed tx differs from original tx, want %v, got %v", tx, parsedTx)
+			t.Errorf("parsed tx differs from original tx: %s", strings.Join(tx.Diff(parsedTx), ", "))
 		}
 		if tx.ChainId().Cmp(parsedTx.ChainId()) != 0 {
 			t.Errorf("invalid chain id, want %d, got %d", tx.ChainId(), parsedTx.ChainId())
@@ -269,3 +414,1168 @@ func TestOVMMetaDataHash(t *testing.T) {
 		t.Errorf("SignatureHashType, should not affect the hash, want %x, got %x with SighashEthSign", emptyTx.Hash(), emptyTxSighashEthSign.Hash())
 	}
 }
+
+func TestExplainHashInputs(t *testing.T) {
+	pairs := [][2]*Transaction{
+		{rightvrsTx, rightvrsTxWithL1Sender},
+		{rightvrsTx, rightvrsTxWithL1BlockNumber},
+		{emptyTx, emptyTxEmptyL1Sender},
+		{emptyTx, emptyTxSighashEthSign},
+	}
+	for i, pair := range pairs {
+		a, b := ExplainHashInputs(pair[0]), ExplainHashInputs(pair[1])
+		if !bytes.Equal(a, b) {
+			t.Errorf("pair %d: hash inputs differ:\n%x\n%x", i, a, b)
+		}
+		if h := crypto.Keccak256Hash(a); h != pair[0].Hash() {
+			t.Errorf("pair %d: hash input mismatch: have %x, want %x", i, h, pair[0].Hash())
+		}
+	}
+}
+
+func TestAsLegacy(t *testing.T) {
+	tx := rightvrsTxWithL1Sender.AsLegacy()
+	tx.meta = rightvrsTxWithL1Sender.meta
+	tx.SetQueueIndex(7)
+	legacy := tx.AsLegacy()
+	if legacy.L1MessageSender() != nil {
+		t.Errorf("L1MessageSender not cleared, got %x", *legacy.L1MessageSender())
+	}
+	if legacy.QueueIndex() != nil {
+		t.Errorf("queue index not cleared, got %d", *legacy.QueueIndex())
+	}
+	if legacy.SignatureHashType() != SighashEIP155 {
+		t.Errorf("SignatureHashType mismatch, want %d, got %d", SighashEIP155, legacy.SignatureHashType())
+	}
+	if legacy.Hash() != rightvrsTx.Hash() {
+		t.Errorf("hash mismatch, want %x, got %x", rightvrsTx.Hash(), legacy.Hash())
+	}
+
+	want, err := rlp.EncodeToBytes(rightvrsTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := rlp.EncodeToBytes(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("RLP mismatch, want %x, got %x", want, have)
+	}
+
+	// The original transaction must not be modified.
+	if tx.L1MessageSender() == nil || tx.QueueIndex() == nil {
+		t.Error("AsLegacy cleared the metadata of the original transaction")
+	}
+}
+
+func TestTransactionEstimateRLPSize(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewOVMSigner(big.NewInt(420))
+	large, err := SignTx(NewContractCreation(1<<40, new(big.Int).Lsh(common.Big1, 100), 1<<24, big.NewInt(15000000), make([]byte, 1024), nil, nil, QueueOriginSequencer), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single := NewTransaction(0x7f, common.Address{}, big.NewInt(0x80), 0x80, big.NewInt(0x7f), []byte{0x7f}, nil, nil, QueueOriginSequencer, SighashEIP155)
+
+	for i, tx := range []*Transaction{
+		emptyTx,
+		emptyTxEmptyL1Sender,
+		rightvrsTx,
+		rightvrsTxWithL1Sender,
+		rightvrsTxWithL1BlockNumber,
+		emptyTxSighashEthSign,
+		large,
+		single,
+		new(Transaction),
+	} {
+		enc, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := tx.EstimateRLPSize(), uint64(len(enc)); have != want {
+			t.Errorf("tx %d: estimated size mismatch: have %d, want %d", i, have, want)
+		}
+	}
+}
+
+func BenchmarkTransactionEstimateRLPSize(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		rightvrsTx.EstimateRLPSize()
+	}
+}
+
+func BenchmarkTransactionEncodeRLPSize(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		c := writeCounter(0)
+		rlp.Encode(&c, &rightvrsTx.data)
+	}
+}
+
+func TestTransactionReplacesSameNonce(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	signer := NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	sign := func(key *ecdsa.PrivateKey, nonce uint64, gasPrice int64) *Transaction {
+		tx, err := SignTx(NewTransaction(nonce, to, new(big.Int), 21000, big.NewInt(gasPrice), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	tx := sign(key, 5, 1)
+	tests := []struct {
+		other *Transaction
+		want  bool
+	}{
+		{sign(key, 5, 2), true},
+		{sign(key, 6, 2), false},
+		{sign(otherKey, 5, 2), false},
+	}
+	for i, test := range tests {
+		have, err := tx.ReplacesSameNonce(test.other, signer)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if have != test.want {
+			t.Errorf("test %d: have %v, want %v", i, have, test.want)
+		}
+	}
+
+	if _, err := tx.ReplacesSameNonce(NewTransaction(5, to, new(big.Int), 21000, big.NewInt(2), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer); err == nil {
+		t.Fatal("expected error for unsigned transaction")
+	}
+}
+
+func BenchmarkTransactionsByPriceAndNonce(b *testing.B) {
+	const senders = 10000
+	signer := NewOVMSigner(big.NewInt(1))
+	accounts := make(map[common.Address]Transactions, senders)
+	for i := 0; i < senders; i++ {
+		key, _ := crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := SignTx(NewTransaction(nonce, common.Address{}, big.NewInt(100), 100, big.NewInt(int64(i)), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+			accounts[addr] = append(accounts[addr], tx)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		groups := make(map[common.Address]Transactions, len(accounts))
+		for addr, txs := range accounts {
+			groups[addr] = txs
+		}
+		txset := NewTransactionsByPriceAndNonce(signer, groups)
+		for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+			txset.Shift()
+		}
+	}
+}
+
+func TestTransactionJSONChecksumAddresses(t *testing.T) {
+	to := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	tx := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+
+	enc, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(enc, []byte(`"to":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"`)) {
+		t.Fatalf("expected lowercase address by default, got %s", enc)
+	}
+
+	checksummed, err := tx.MarshalJSONChecksummed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(checksummed, []byte(`"to":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"`)) {
+		t.Fatalf("expected checksummed address, got %s", checksummed)
+	}
+
+	// Decoding accepts the checksummed form and yields the same fields.
+	var decoded Transaction
+	if err := json.Unmarshal(checksummed, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	reencoded, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reencoded, enc) {
+		t.Fatalf("expected only the address casing to differ:\n%s\n%s", enc, checksummed)
+	}
+
+	// Contract creations have no recipient to checksum.
+	creation := NewContractCreation(0, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer)
+	plain, _ := json.Marshal(creation)
+	if checksummed, err := creation.MarshalJSONChecksummed(); err != nil || !bytes.Equal(checksummed, plain) {
+		t.Fatalf("expected the plain encoding for a creation, got %s (%v)", checksummed, err)
+	}
+}
+
+func TestUnmarshalTransactionHex(t *testing.T) {
+	const enc = "f8498080808080011ca09b16de9d5bdee2cf56c28d16275a4da68cd30273e2525f3959f5d62557489921a0372ebd8fb3345f7db7b5a86d42e24d36e983e259b0664ceb8c227ec9af572f3d"
+	want, err := decodeTx(common.Hex2Bytes(enc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{enc, "0x" + enc} {
+		tx, err := UnmarshalTransactionHex(input)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if tx.Hash() != want.Hash() {
+			t.Fatalf("%s: hash mismatch: have %x, want %x", input, tx.Hash(), want.Hash())
+		}
+	}
+
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{input: "0x" + enc[1:], err: hexutil.ErrOddLength},
+		{input: enc[1:], err: hexutil.ErrOddLength},
+		{input: "0xzz", err: hexutil.ErrSyntax},
+	}
+	for _, test := range tests {
+		if _, err := UnmarshalTransactionHex(test.input); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.input, test.err, err)
+		}
+	}
+	if _, err := UnmarshalTransactionHex("0x"); err == nil {
+		t.Error("expected error for empty input")
+	}
+}
+
+func TestValidSignatureHashTypes(t *testing.T) {
+	have := ValidSignatureHashTypes()
+	want := []SignatureHashType{SighashEIP155, SighashEthSign, CreateEOA}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+	names := []string{"EIP155", "EthSign", "CreateEOA"}
+	for i, sighashType := range have {
+		if sighashType.String() != names[i] {
+			t.Errorf("expected %s, got %s", names[i], sighashType)
+		}
+	}
+	if s := SignatureHashType(3).String(); s != "SignatureHashType(3)" {
+		t.Errorf("unexpected string for undefined type: %s", s)
+	}
+}
+
+// Tests that free transactions are ordered ahead of priced sequencer
+// transactions, but behind transactions that are already in the chain.
+func TestTransactionPriceNonceSortFree(t *testing.T) {
+	signer := NewOVMSigner(big.NewInt(1))
+	sign := func(gasPrice int64) *Transaction {
+		key, _ := crypto.GenerateKey()
+		tx, err := SignTx(NewTransaction(0, common.Address{}, new(big.Int), 21000, big.NewInt(gasPrice), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	free := NewTransaction(0, common.Address{}, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	free.SetFree(true)
+	key, _ := crypto.GenerateKey()
+	free, err := SignTx(free, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !free.IsFree() {
+		t.Fatal("expected the free flag to survive signing")
+	}
+	indexed := sign(0)
+	indexed.SetIndex(0)
+
+	want := Transactions{indexed, free, sign(10), sign(1), sign(0)}
+	groups := make(map[common.Address]Transactions)
+	for _, tx := range want {
+		from, _ := Sender(signer, tx)
+		groups[from] = Transactions{tx}
+	}
+	txset := NewTransactionsByPriceAndNonce(signer, groups)
+	for i, tx := range want {
+		if have := txset.Peek(); have != tx {
+			t.Fatalf("tx %d: unexpected transaction order", i)
+		}
+		txset.Shift()
+	}
+}
+
+// Tests that resetting a partially drained transaction set restores the full
+// original order.
+func TestTransactionPriceNonceSortReset(t *testing.T) {
+	signer := HomesteadSigner{}
+	groups := map[common.Address]Transactions{}
+	for start := 0; start < 10; start++ {
+		key, _ := crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for i := 0; i < 10; i++ {
+			tx, _ := SignTx(NewTransaction(uint64(i), common.Address{}, big.NewInt(100), 100, big.NewInt(int64(start+i)), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+			groups[addr] = append(groups[addr], tx)
+		}
+	}
+	txset := NewTransactionsByPriceAndNonce(signer, groups)
+
+	drain := func(limit int) Transactions {
+		var txs Transactions
+		for tx := txset.Peek(); tx != nil && len(txs) < limit; tx = txset.Peek() {
+			txs = append(txs, tx)
+			txset.Shift()
+		}
+		return txs
+	}
+	want := drain(100)
+	if len(want) != 100 {
+		t.Fatalf("expected 100 transactions, found %d", len(want))
+	}
+
+	txset.Reset()
+	drain(50)
+	txset.Reset()
+	have := drain(100)
+	if len(have) != len(want) {
+		t.Fatalf("expected %d transactions after reset, found %d", len(want), len(have))
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Fatalf("tx %d: order mismatch after reset", i)
+		}
+	}
+}
+
+func TestTransactionsGroupBySender(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	signer := HomesteadSigner{}
+
+	// Interleave the senders and submit the nonces in descending order
+	txs := Transactions{}
+	for nonce := 9; nonce >= 0; nonce-- {
+		for _, key := range keys {
+			tx, _ := SignTx(NewTransaction(uint64(nonce), common.Address{}, big.NewInt(100), 100, big.NewInt(1), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+			txs = append(txs, tx)
+		}
+	}
+	groups, err := txs.GroupBySender(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != len(keys) {
+		t.Fatalf("expected %d groups, got %d", len(keys), len(groups))
+	}
+	for _, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		group := groups[addr]
+		if len(group) != 10 {
+			t.Fatalf("expected 10 transactions for %s, got %d", addr.Hex(), len(group))
+		}
+		for i, tx := range group {
+			if from, _ := Sender(signer, tx); from != addr {
+				t.Errorf("transaction %d grouped under %s, sent by %s", i, addr.Hex(), from.Hex())
+			}
+			if tx.Nonce() != uint64(i) {
+				t.Errorf("transaction %d of %s has nonce %d", i, addr.Hex(), tx.Nonce())
+			}
+		}
+	}
+
+	// An unsigned transaction cannot be grouped
+	unsigned := NewTransaction(0, common.Address{}, big.NewInt(100), 100, big.NewInt(1), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if _, err := append(txs, unsigned).GroupBySender(signer); err == nil {
+		t.Fatal("expected error grouping an unsigned transaction")
+	}
+}
+
+func TestTransactionsMinIncludedGasPrice(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	signer := HomesteadSigner{}
+	sign := func(key *ecdsa.PrivateKey, nonce uint64, gas uint64, price int64) *Transaction {
+		tx, _ := SignTx(NewTransaction(nonce, common.Address{}, big.NewInt(100), gas, big.NewInt(price), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		return tx
+	}
+	// The first account pays well for its first transaction only, and the
+	// last account sends a transaction too large for the smaller blocks.
+	groups := map[common.Address]Transactions{
+		crypto.PubkeyToAddress(keys[0].PublicKey): {sign(keys[0], 0, 21000, 50), sign(keys[0], 1, 21000, 10)},
+		crypto.PubkeyToAddress(keys[1].PublicKey): {sign(keys[1], 0, 21000, 30), sign(keys[1], 1, 21000, 20)},
+		crypto.PubkeyToAddress(keys[2].PublicKey): {sign(keys[2], 0, 100000, 100)},
+	}
+	txset := NewTransactionsByPriceAndNonce(signer, groups)
+
+	tests := []struct {
+		gasLimit uint64
+		want     *big.Int
+	}{
+		{gasLimit: 20999, want: nil},
+		{gasLimit: 21000, want: big.NewInt(50)},
+		{gasLimit: 42000, want: big.NewInt(30)},
+		{gasLimit: 63000, want: big.NewInt(20)},
+		{gasLimit: 84000, want: big.NewInt(10)},
+		{gasLimit: 100000, want: big.NewInt(100)},
+		{gasLimit: 121000, want: big.NewInt(50)},
+	}
+	for _, test := range tests {
+		got := txset.MinIncludedGasPrice(test.gasLimit)
+		if (got == nil) != (test.want == nil) || (got != nil && got.Cmp(test.want) != 0) {
+			t.Errorf("gas limit %d: expected min price %v, got %v", test.gasLimit, test.want, got)
+		}
+	}
+
+	// The simulation does not consume the set
+	count := 0
+	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+		count++
+		txset.Shift()
+	}
+	if count != 5 {
+		t.Fatalf("expected 5 transactions after simulating, got %d", count)
+	}
+}
+
+func TestTransactionAsMessageUnsigned(t *testing.T) {
+	signer := NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	tx := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if tx.IsSigned() {
+		t.Fatal("expected new transaction to be unsigned")
+	}
+	if _, err := tx.AsMessage(signer); err != ErrUnsignedTransaction {
+		t.Fatalf("expected %v, got %v", ErrUnsignedTransaction, err)
+	}
+
+	key, _ := crypto.GenerateKey()
+	signed, err := SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signed.IsSigned() {
+		t.Fatal("expected signed transaction to be signed")
+	}
+	if _, err := signed.AsMessage(signer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Deposits are not signed and are converted without error
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	if _, err := deposit.AsMessage(signer); err != nil {
+		t.Fatalf("unexpected error for deposit: %v", err)
+	}
+}
+
+func TestTransactionPriceNonceSortMaxDeposits(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	// Deposits are not signed and are all grouped under the zero address
+	var deposits Transactions
+	for i := uint64(0); i < 5; i++ {
+		tx := NewTransaction(i, to, new(big.Int), 21000, new(big.Int), nil, &l1Sender, big.NewInt(1), QueueOriginL1ToL2, SighashEIP155)
+		tx.SetQueueIndex(i)
+		deposits = append(deposits, tx)
+	}
+	var sequenced Transactions
+	for i := uint64(0); i < 2; i++ {
+		tx, _ := SignTx(NewTransaction(i, to, new(big.Int), 21000, big.NewInt(1), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		sequenced = append(sequenced, tx)
+	}
+	groups := map[common.Address]Transactions{
+		{}:                                    deposits,
+		crypto.PubkeyToAddress(key.PublicKey): sequenced,
+	}
+	txset := NewTransactionsByPriceAndNonce(signer, groups)
+	txset.SetMaxDeposits(3)
+
+	// Deposit 0 is skipped without being included and does not count
+	// towards the limit
+	var included Transactions
+	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+		if qi := tx.QueueIndex(); qi == nil || *qi != 0 {
+			included = append(included, tx)
+			if qi != nil {
+				txset.IncludeDeposit()
+			}
+		}
+		txset.Shift()
+	}
+	if len(included) != 5 {
+		t.Fatalf("expected 5 included transactions, got %d", len(included))
+	}
+	if txset.Deposits() != 3 {
+		t.Fatalf("expected 3 included deposits, got %d", txset.Deposits())
+	}
+	var queueIndices []uint64
+	for _, tx := range included {
+		if qi := tx.QueueIndex(); qi != nil {
+			queueIndices = append(queueIndices, *qi)
+		}
+	}
+	if !reflect.DeepEqual(queueIndices, []uint64{1, 2, 3}) {
+		t.Fatalf("expected deposits 1, 2, 3 to be included, got %v", queueIndices)
+	}
+	deferred := txset.Deferred()
+	if len(deferred) != 1 || *deferred[0].QueueIndex() != 4 {
+		t.Fatalf("expected deposit 4 to be deferred, got %d deferred", len(deferred))
+	}
+
+	// Resetting the set lifts the deferral
+	txset.Reset()
+	if len(txset.Deferred()) != 0 || txset.Deposits() != 0 {
+		t.Fatal("expected reset to clear the deposit accounting")
+	}
+}
+
+func TestTransactionDiff(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx := NewTransaction(1, to, big.NewInt(10), 21000, big.NewInt(1), []byte{0x01}, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if diffs := tx.Diff(tx); len(diffs) != 0 {
+		t.Fatalf("expected no differences, got %v", diffs)
+	}
+
+	other := NewTransaction(2, to, big.NewInt(10), 21000, big.NewInt(1), []byte{0x02}, &to, nil, QueueOriginL1ToL2, SighashEIP155)
+	other.SetQueueIndex(5)
+	want := []string{
+		"nonce: 1 != 2",
+		"data: 0x01 != 0x02",
+		"l1MessageSender: <nil> != " + to.Hex(),
+		"queueOrigin: 0 != 1",
+		"queueIndex: <nil> != 5",
+	}
+	if diffs := tx.Diff(other); !reflect.DeepEqual(diffs, want) {
+		t.Fatalf("expected differences %v, got %v", want, diffs)
+	}
+}
+
+func TestTransactionDeepEqual(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newDeposit := func() *Transaction {
+		tx := NewTransaction(1, to, new(big.Int), 21000, new(big.Int), []byte{0x01}, &sender, big.NewInt(12), QueueOriginL1ToL2, SighashEIP155)
+		tx.SetL1Timestamp(1600000000)
+		tx.SetQueueIndex(5)
+		return tx
+	}
+	a, b := newDeposit(), newDeposit()
+	// Populate the caches of one of them only
+	a.Hash()
+	a.Size()
+	if !DeepEqual(a, b) {
+		t.Fatalf("expected equal transactions, got differences %v", a.Diff(b))
+	}
+	b.SetQueueIndex(6)
+	if DeepEqual(a, b) {
+		t.Fatal("expected transactions with different queue indices to differ")
+	}
+
+	key, _ := crypto.GenerateKey()
+	signer := NewOVMSigner(big.NewInt(1))
+	signed, err := SignTx(rightvrsTxWithL1Sender, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Sender(signer, signed); err != nil {
+		t.Fatal(err)
+	}
+	// Signatures are deterministic, so signing again yields an equal
+	// transaction without a cached sender
+	again, err := SignTx(rightvrsTxWithL1Sender, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !DeepEqual(signed, again) {
+		t.Fatalf("expected equal signed transactions, got differences %v", signed.Diff(again))
+	}
+	if DeepEqual(signed, rightvrsTxWithL1Sender) {
+		t.Fatal("expected transactions with different signatures to differ")
+	}
+	if DeepEqual(signed, nil) || !DeepEqual(nil, nil) {
+		t.Fatal("unexpected nil comparison result")
+	}
+}
+
+func TestTransactionBinaryVersion(t *testing.T) {
+	enc, err := rightvrsTx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc[0] != TxEncodingVersion0 {
+		t.Fatalf("expected version %d, got %d", TxEncodingVersion0, enc[0])
+	}
+
+	// Both the version 0 encoding and an unprefixed RLP encoding decode
+	unprefixed, _ := rlp.EncodeToBytes(rightvrsTx)
+	for _, b := range [][]byte{enc, unprefixed} {
+		tx := new(Transaction)
+		if err := tx.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Hash() != rightvrsTx.Hash() {
+			t.Fatalf("decoded transaction differs: %s", strings.Join(rightvrsTx.Diff(tx), ", "))
+		}
+	}
+
+	unknown := append([]byte{0x7f}, unprefixed...)
+	if err := new(Transaction).UnmarshalBinary(unknown); !errors.Is(err, ErrUnknownTxVersion) {
+		t.Fatalf("expected %v, got %v", ErrUnknownTxVersion, err)
+	}
+}
+
+func TestTransactionBinaryDeposit(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	for _, blockNumber := range []*big.Int{big.NewInt(0), big.NewInt(1234), nil} {
+		deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), []byte{1, 2, 3}, &sender, blockNumber, QueueOriginL1ToL2, SighashEIP155)
+		deposit.SetL1Timestamp(1600000000)
+		deposit.SetQueueIndex(0)
+
+		enc, err := deposit.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enc[0] != TxEncodingDepositVersion0 {
+			t.Fatalf("expected version %d, got %d", TxEncodingDepositVersion0, enc[0])
+		}
+		tx := new(Transaction)
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			t.Fatal(err)
+		}
+		if diff := deposit.Diff(tx); len(diff) != 0 {
+			t.Fatalf("block number %v: decoded deposit differs: %s", blockNumber, strings.Join(diff, ", "))
+		}
+	}
+
+	// The L1 metadata is not part of the version 0 encoding
+	enc, err := rightvrsTxWithL1BlockNumber.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := new(Transaction)
+	if err := tx.UnmarshalBinary(enc); err != nil {
+		t.Fatal(err)
+	}
+	if tx.L1BlockNumber() != nil {
+		t.Fatalf("expected no l1 block number, got %d", tx.L1BlockNumber())
+	}
+}
+
+func TestTransactionsSighashTypeCounts(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func(sighashType SignatureHashType) *Transaction {
+		return NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, sighashType)
+	}
+	// An undefined signature hash type is counted on its own
+	undefined := SignatureHashType(0x7f)
+
+	txs := Transactions{
+		newTx(SighashEIP155),
+		newTx(SighashEthSign),
+		newTx(SighashEIP155),
+		newTx(undefined),
+		newTx(SighashEthSign),
+		newTx(SighashEIP155),
+	}
+	want := map[SignatureHashType]int{
+		SighashEIP155:  3,
+		SighashEthSign: 2,
+		undefined:      1,
+	}
+	if counts := txs.SighashTypeCounts(); !reflect.DeepEqual(counts, want) {
+		t.Fatalf("expected counts %v, got %v", want, counts)
+	}
+	if counts := (Transactions{}).SighashTypeCounts(); len(counts) != 0 {
+		t.Fatalf("expected no counts for an empty slice, got %v", counts)
+	}
+}
+
+func TestTransactionsDepositCountFrom(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	alice := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	bob := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	deposit := func(l1Sender common.Address) *Transaction {
+		return NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &l1Sender, nil, QueueOriginL1ToL2, SighashEIP155)
+	}
+	// Sequencer transactions are not deposits, even with an L1 sender set
+	sequencer := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &alice, nil, QueueOriginSequencer, SighashEIP155)
+	txs := Transactions{deposit(alice), deposit(bob), sequencer, deposit(alice), emptyTx}
+
+	tests := []struct {
+		sender common.Address
+		count  int
+	}{
+		{alice, 2},
+		{bob, 1},
+		{to, 0},
+	}
+	for _, test := range tests {
+		if count := txs.DepositCountFrom(test.sender); count != test.count {
+			t.Errorf("%s: expected %d deposits, got %d", test.sender.Hex(), test.count, count)
+		}
+	}
+}
+
+func TestTransactionsOriginCounts(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func(queueOrigin QueueOrigin) *Transaction {
+		return NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, queueOrigin, SighashEIP155)
+	}
+	// A transaction without metadata has no queue origin
+	noOrigin := newTx(QueueOriginSequencer)
+	noOrigin.SetTransactionMeta(&TransactionMeta{})
+
+	txs := Transactions{
+		newTx(QueueOriginSequencer),
+		newTx(QueueOriginL1ToL2),
+		newTx(QueueOriginSequencer),
+		noOrigin,
+		newTx(QueueOriginL1ToL2),
+		newTx(QueueOriginL1ToL2),
+	}
+	want := map[QueueOrigin]int{
+		QueueOriginSequencer: 3,
+		QueueOriginL1ToL2:    3,
+	}
+	if counts := txs.OriginCounts(); !reflect.DeepEqual(counts, want) {
+		t.Fatalf("expected counts %v, got %v", want, counts)
+	}
+	if counts := (Transactions{}).OriginCounts(); len(counts) != 0 {
+		t.Fatalf("expected no counts for an empty slice, got %v", counts)
+	}
+}
+
+func TestTransactionRecoveryID(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx := NewTransaction(0, to, new(big.Int), 21000, big.NewInt(1), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+
+	for _, signer := range []Signer{HomesteadSigner{}, NewEIP155Signer(big.NewInt(1)), NewEIP155Signer(big.NewInt(420))} {
+		signed, err := SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, err := rlp.EncodeToBytes(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := new(Transaction)
+		if err := rlp.DecodeBytes(enc, decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		// The recovery bit matches the one produced by signing
+		sig, _ := crypto.Sign(signer.Hash(tx).Bytes(), key)
+		recid, err := decoded.RecoveryID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recid != sig[64] {
+			t.Errorf("%T: expected recovery id %d, got %d", signer, sig[64], recid)
+		}
+		// Re-encoding reproduces the original V
+		reenc, err := rlp.EncodeToBytes(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(enc, reenc) {
+			t.Errorf("%T: re-encoding changed the transaction, got %x, want %x", signer, reenc, enc)
+		}
+	}
+
+	recid, err := rightvrsTx.RecoveryID()
+	if err != nil || recid != 1 {
+		t.Errorf("expected recovery id 1 for V 28, got %d (%v)", recid, err)
+	}
+	if _, err := tx.RecoveryID(); err != ErrInvalidSig {
+		t.Errorf("expected %v for an unsigned transaction, got %v", ErrInvalidSig, err)
+	}
+}
+
+func TestLessByPriceAndOrigin(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyB, _ := crypto.GenerateKey()
+	signer := HomesteadSigner{}
+	sign := func(key *ecdsa.PrivateKey, nonce uint64, price int64) *Transaction {
+		tx, _ := SignTx(NewTransaction(nonce, common.Address{}, big.NewInt(100), 100, big.NewInt(price), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		return tx
+	}
+	indexed := func(tx *Transaction, index uint64) *Transaction {
+		meta := *tx.GetMeta()
+		meta.Index = &index
+		tx.SetTransactionMeta(&meta)
+		return tx
+	}
+
+	tests := []struct {
+		name string
+		a, b *Transaction
+		less bool
+	}{
+		{name: "higher price first", a: sign(keyA, 0, 2), b: sign(keyB, 0, 1), less: true},
+		{name: "lower price last", a: sign(keyA, 0, 1), b: sign(keyB, 0, 2), less: false},
+		{name: "indexed before unindexed", a: indexed(sign(keyA, 0, 1), 5), b: sign(keyB, 0, 100), less: true},
+		{name: "unindexed after indexed", a: sign(keyA, 0, 100), b: indexed(sign(keyB, 0, 1), 5), less: false},
+		{name: "lower index first", a: indexed(sign(keyA, 0, 1), 1), b: indexed(sign(keyB, 0, 100), 2), less: true},
+		{name: "lower nonce first for same sender", a: sign(keyA, 0, 1), b: sign(keyA, 1, 100), less: true},
+		{name: "higher nonce last for same sender", a: sign(keyA, 1, 100), b: sign(keyA, 0, 1), less: false},
+	}
+	for _, test := range tests {
+		if less := LessByPriceAndOrigin(signer, test.a, test.b); less != test.less {
+			t.Errorf("%s: expected %v, got %v", test.name, test.less, less)
+		}
+	}
+
+	// Without a signer the senders are not compared
+	if !LessByPriceAndOrigin(nil, sign(keyA, 1, 100), sign(keyA, 0, 1)) {
+		t.Error("expected price ordering without a signer")
+	}
+}
+
+func TestTransactionsFindDuplicates(t *testing.T) {
+	key, _ := defaultTestKey()
+	signer := NewEIP155Signer(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	var txs Transactions
+	for i := uint64(0); i < 4; i++ {
+		tx, err := SignTx(NewTransaction(i, to, new(big.Int), 21000, big.NewInt(1), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	if dups := txs.FindDuplicates(); len(dups) != 0 {
+		t.Fatalf("expected no duplicates, got %x", dups)
+	}
+
+	// A copy with a different L1 message sender has the same hash
+	dup := txs[2].AsLegacy()
+	dup.meta.L1MessageSender = &sender
+	txs = append(txs, dup, txs[2])
+
+	dups := txs.FindDuplicates()
+	if len(dups) != 1 || dups[0] != txs[2].Hash() {
+		t.Fatalf("expected duplicate %x, got %x", txs[2].Hash(), dups)
+	}
+}
+
+func TestResolveQueueOrigin(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func() *Transaction {
+		tx := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+		tx.SetTransactionMeta(&TransactionMeta{})
+		return tx
+	}
+
+	withID := newTx()
+	withID.SetQueueIndex(0)
+	if origin := withID.ResolveQueueOrigin(); origin != QueueOriginL1ToL2 {
+		t.Fatalf("expected %d with a queue index, got %d", QueueOriginL1ToL2, origin)
+	}
+	if qo := withID.QueueOrigin(); qo == nil || qo.Int64() != int64(QueueOriginL1ToL2) {
+		t.Fatalf("expected the inferred origin to be stored, got %v", qo)
+	}
+
+	withoutID := newTx()
+	if origin := withoutID.ResolveQueueOrigin(); origin != QueueOriginSequencer {
+		t.Fatalf("expected %d without a queue index, got %d", QueueOriginSequencer, origin)
+	}
+
+	// An explicit origin is never overridden
+	explicit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	explicit.SetQueueIndex(1)
+	if origin := explicit.ResolveQueueOrigin(); origin != QueueOriginSequencer {
+		t.Fatalf("expected the explicit origin %d, got %d", QueueOriginSequencer, origin)
+	}
+}
+
+func TestPoolKeyIgnoresMetadata(t *testing.T) {
+	if rightvrsTx.PoolKey() != rightvrsTxWithL1Sender.PoolKey() {
+		t.Errorf("L1MessageSender should not affect the pool key, want %x, got %x", rightvrsTx.PoolKey(), rightvrsTxWithL1Sender.PoolKey())
+	}
+	if rightvrsTx.PoolKey() != rightvrsTxWithL1BlockNumber.PoolKey() {
+		t.Errorf("L1BlockNumber should not affect the pool key, want %x, got %x", rightvrsTx.PoolKey(), rightvrsTxWithL1BlockNumber.PoolKey())
+	}
+	if emptyTx.PoolKey() != emptyTxEmptyL1Sender.PoolKey() {
+		t.Errorf("L1MessageSender should not affect the pool key, want %x, got %x", emptyTx.PoolKey(), emptyTxEmptyL1Sender.PoolKey())
+	}
+	if emptyTx.PoolKey() != emptyTxSighashEthSign.PoolKey() {
+		t.Errorf("SignatureHashType should not affect the pool key, want %x, got %x", emptyTx.PoolKey(), emptyTxSighashEthSign.PoolKey())
+	}
+	if rightvrsTx.PoolKey() != rightvrsTx.Hash() {
+		t.Errorf("expected the pool key to be the hash, want %x, got %x", rightvrsTx.Hash(), rightvrsTx.PoolKey())
+	}
+}
+
+func TestTransactionsSortByNonce(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func(nonce uint64, gasPrice int64) *Transaction {
+		return NewTransaction(nonce, to, new(big.Int), 21000, big.NewInt(gasPrice), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	}
+	// The gas price tells transactions with the same nonce apart
+	txs := Transactions{newTx(3, 1), newTx(1, 1), newTx(2, 1), newTx(1, 2), newTx(0, 1), newTx(1, 3)}
+	txs.SortByNonce()
+
+	want := []struct {
+		nonce    uint64
+		gasPrice int64
+	}{{0, 1}, {1, 1}, {1, 2}, {1, 3}, {2, 1}, {3, 1}}
+	for i, tx := range txs {
+		if tx.Nonce() != want[i].nonce || tx.GasPrice().Int64() != want[i].gasPrice {
+			t.Fatalf("position %d: expected nonce %d and gas price %d, got %d and %d", i, want[i].nonce, want[i].gasPrice, tx.Nonce(), tx.GasPrice())
+		}
+	}
+}
+
+func TestTransactionsL1Senders(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	alice := common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	bob := common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	newTx := func(l1Sender *common.Address, queueOrigin QueueOrigin) *Transaction {
+		return NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, l1Sender, nil, queueOrigin, SighashEIP155)
+	}
+	txs := Transactions{
+		newTx(nil, QueueOriginSequencer),
+		newTx(&bob, QueueOriginL1ToL2),
+		newTx(&alice, QueueOriginL1ToL2),
+		newTx(nil, QueueOriginSequencer),
+		newTx(&bob, QueueOriginL1ToL2),
+		newTx(&alice, QueueOriginSequencer),
+	}
+	want := []common.Address{bob, alice}
+	if senders := txs.L1Senders(); !reflect.DeepEqual(senders, want) {
+		t.Fatalf("expected senders %x, got %x", want, senders)
+	}
+	if senders := (Transactions{newTx(nil, QueueOriginSequencer)}).L1Senders(); len(senders) != 0 {
+		t.Fatalf("expected no senders, got %x", senders)
+	}
+}
+
+func TestTraceFields(t *testing.T) {
+	keys := func(fields []interface{}) map[string]bool {
+		if len(fields)%2 != 0 {
+			t.Fatalf("expected key value pairs, got %d fields", len(fields))
+		}
+		set := make(map[string]bool)
+		for i := 0; i < len(fields); i += 2 {
+			set[fields[i].(string)] = true
+		}
+		return set
+	}
+	shared := []string{"hash", "to", "nonce", "gas", "gasPrice", "value", "queueOrigin", "sighashType", "dataLen"}
+
+	key, _ := crypto.GenerateKey()
+	signer := NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := SignTx(NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1), []byte{1, 2}, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys(tx.TraceFields())["from"] {
+		t.Fatal("expected no sender before it is recovered")
+	}
+	if _, err := Sender(signer, tx); err != nil {
+		t.Fatal(err)
+	}
+	seq := keys(tx.TraceFields())
+	for _, k := range append(shared, "from") {
+		if !seq[k] {
+			t.Errorf("sequencer transaction: missing key %q", k)
+		}
+	}
+	if seq["l1MessageSender"] {
+		t.Error("sequencer transaction: unexpected key l1MessageSender")
+	}
+
+	l1Sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	deposit := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, &l1Sender, big.NewInt(1), QueueOriginL1ToL2, SighashEIP155)
+	dep := keys(deposit.TraceFields())
+	for _, k := range append(shared, "l1MessageSender") {
+		if !dep[k] {
+			t.Errorf("deposit: missing key %q", k)
+		}
+	}
+	if dep["from"] {
+		t.Error("deposit: unexpected key from")
+	}
+}
+
+func TestTransactionsMerkleRoot(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	var txs Transactions
+	for i := 0; i < 3; i++ {
+		txs = append(txs, NewTransaction(uint64(i), to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155))
+	}
+	h0, h1, h2 := txs[0].Hash(), txs[1].Hash(), txs[2].Hash()
+	h01 := crypto.Keccak256Hash(h0[:], h1[:])
+	h2z := crypto.Keccak256Hash(h2[:], common.Hash{}.Bytes())
+
+	tests := []struct {
+		txs  Transactions
+		root common.Hash
+	}{
+		{txs: Transactions{}, root: common.Hash{}},
+		{txs: txs[:1], root: h0},
+		{txs: txs[:2], root: h01},
+		// The odd leaf is paired with the zero hash
+		{txs: txs[:3], root: crypto.Keccak256Hash(h01[:], h2z[:])},
+	}
+	for _, test := range tests {
+		if root := test.txs.MerkleRoot(); root != test.root {
+			t.Errorf("%d transactions: root mismatch: have %x, want %x", len(test.txs), root, test.root)
+		}
+	}
+	// The root commits to the order of the transactions
+	if (Transactions{txs[1], txs[0]}).MerkleRoot() == h01 {
+		t.Error("expected reordered transactions to have a different root")
+	}
+}
+
+func TestEffectiveNonce(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tests := []struct {
+		queueOrigin QueueOrigin
+		ordered     bool
+	}{
+		{queueOrigin: QueueOriginSequencer, ordered: true},
+		{queueOrigin: QueueOriginL1ToL2, ordered: false},
+	}
+	for i, test := range tests {
+		tx := NewTransaction(5, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, test.queueOrigin, SighashEIP155)
+		nonce, ordered := tx.EffectiveNonce()
+		if nonce != 5 {
+			t.Errorf("test %d: expected nonce 5, got %d", i, nonce)
+		}
+		if ordered != test.ordered {
+			t.Errorf("test %d: expected ordered %v, got %v", i, test.ordered, ordered)
+		}
+	}
+}
+
+func TestHumanReadableIntent(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	ether := func(wei string) *big.Int {
+		v, _ := new(big.Int).SetString(wei, 10)
+		return v
+	}
+	tests := []struct {
+		tx     *Transaction
+		intent string
+	}{
+		{
+			tx:     NewTransaction(0, to, ether("1500000000000000000"), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEthSign),
+			intent: "Send 1.5 ETH to 0x1111111111111111111111111111111111111111",
+		},
+		{
+			tx:     NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEthSign),
+			intent: "Send 0 ETH to 0x1111111111111111111111111111111111111111",
+		},
+		{
+			tx:     NewTransaction(0, to, new(big.Int), 100000, new(big.Int), []byte{0xa9, 0x05, 0x9c, 0xbb}, nil, nil, QueueOriginSequencer, SighashEthSign),
+			intent: "Call contract 0x1111111111111111111111111111111111111111 with 4 bytes of data",
+		},
+		{
+			tx:     NewTransaction(0, to, ether("1"), 100000, new(big.Int), []byte{0xd0, 0xe3, 0x0d, 0xb0}, nil, nil, QueueOriginSequencer, SighashEthSign),
+			intent: "Call contract 0x1111111111111111111111111111111111111111 with 4 bytes of data, sending 0.000000000000000001 ETH",
+		},
+		{
+			tx:     NewContractCreation(0, new(big.Int), 1000000, new(big.Int), []byte{0x60, 0x00, 0x60, 0x00}, nil, nil, QueueOriginSequencer),
+			intent: "Deploy a contract with 4 bytes of code",
+		},
+		{
+			tx:     NewContractCreation(0, ether("2000000000000000000"), 1000000, new(big.Int), []byte{0x00}, nil, nil, QueueOriginSequencer),
+			intent: "Deploy a contract with 1 byte of code, sending 2 ETH",
+		},
+	}
+	for i, test := range tests {
+		if intent := test.tx.HumanReadableIntent(); intent != test.intent {
+			t.Errorf("test %d: intent mismatch:\nhave %q\nwant %q", i, intent, test.intent)
+		}
+	}
+}
+
+// countingSigner counts the senders it recovers.
+type countingSigner struct {
+	Signer
+	recovered *int
+}
+
+func (s countingSigner) Sender(tx *Transaction) (common.Address, error) {
+	*s.recovered++
+	return s.Signer.Sender(tx)
+}
+
+func TestAsLazyMessage(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	signer := NewOVMSigner(big.NewInt(1))
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := SignTx(NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recovered int
+	msg := tx.AsLazyMessage(countingSigner{signer, &recovered})
+	if recovered != 0 {
+		t.Fatalf("expected no recovery before From is called, got %d", recovered)
+	}
+	if have := msg.From(); have != from {
+		t.Fatalf("expected sender %s, got %s", from.Hex(), have.Hex())
+	}
+	// Copies of the message share the recovered sender
+	if have := msg.WithTxIndex(1).From(); have != from {
+		t.Fatalf("expected sender %s, got %s", from.Hex(), have.Hex())
+	}
+	if recovered != 1 {
+		t.Fatalf("expected the sender to be recovered once, got %d", recovered)
+	}
+
+	unsigned := NewTransaction(0, to, new(big.Int), 21000, new(big.Int), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	if _, err := unsigned.AsLazyMessage(signer).RecoverFrom(); err != ErrUnsignedTransaction {
+		t.Fatalf("expected %v, got %v", ErrUnsignedTransaction, err)
+	}
+}
+
+func TestHasDuplicateNonces(t *testing.T) {
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	newTx := func(nonce uint64, price int64) *Transaction {
+		return NewTransaction(nonce, to, new(big.Int), 21000, big.NewInt(price), nil, nil, nil, QueueOriginSequencer, SighashEIP155)
+	}
+	unique := Transactions{newTx(0, 1), newTx(1, 1), newTx(2, 1)}
+	// An unresolved replacement of nonce 1
+	duplicate := Transactions{newTx(0, 1), newTx(1, 1), newTx(1, 2), newTx(2, 1)}
+
+	if unique.HasDuplicateNonces() {
+		t.Fatal("expected no duplicate nonces")
+	}
+	if !duplicate.HasDuplicateNonces() {
+		t.Fatal("expected duplicate nonces")
+	}
+	if (Transactions{}).HasDuplicateNonces() {
+		t.Fatal("expected no duplicate nonces in an empty slice")
+	}
+
+	alice := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	bob := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	carol := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	groups := map[common.Address]Transactions{
+		carol: duplicate,
+		alice: unique,
+		bob:   duplicate,
+	}
+	if have, want := DuplicateNonceSenders(groups), []common.Address{bob, carol}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("expected senders %v, got %v", want, have)
+	}
+}
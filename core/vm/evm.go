@@ -148,10 +148,18 @@ type Context struct {
 	OriginalTargetAddress *common.Address
 	OriginalTargetResult  []byte
 	OriginalTargetReached bool
-	OvmExecutionManager   dump.OvmDumpAccount
-	OvmStateManager       dump.OvmDumpAccount
-	OvmMockAccount        dump.OvmDumpAccount
-	OvmSafetyChecker      dump.OvmDumpAccount
+	// OriginalTargetReverted is set when the target reverted inside of the
+	// execution manager, even though the outer call to the execution manager
+	// itself succeeded.
+	OriginalTargetReverted bool
+	// OvmDirectCall marks a message that is executed as a plain call instead
+	// of through the execution manager, which is only the case for messages
+	// from the god address. It is set by the state transition.
+	OvmDirectCall       bool
+	OvmExecutionManager dump.OvmDumpAccount
+	OvmStateManager     dump.OvmDumpAccount
+	OvmMockAccount      dump.OvmDumpAccount
+	OvmSafetyChecker    dump.OvmDumpAccount
 }
 
 // EVM is the Ethereum Virtual Machine base object and provides
@@ -257,15 +265,19 @@ func (evm *EVM) Interpreter() Interpreter {
 // execution error or failed value transfer.
 func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
 	var isTarget = false
-	if UsingOVM {
+	// A root call of a message that is not wrapped for the execution
+	// manager, which is the case for messages from the god address, is
+	// executed as a plain call.
+	var direct = UsingOVM && evm.depth == 0 && evm.Context.OvmDirectCall
+	if UsingOVM && evm.depth == 0 {
 		// OVM_ENABLED
-		if evm.depth == 0 {
-			// We're inside a new transaction, so make sure to wipe these variables beforehand.
-			evm.Context.OriginalTargetAddress = nil
-			evm.Context.OriginalTargetResult = []byte("00")
-			evm.Context.OriginalTargetReached = false
-		}
-
+		// We're inside a new transaction, so make sure to wipe these variables beforehand.
+		evm.Context.OriginalTargetAddress = nil
+		evm.Context.OriginalTargetResult = []byte("00")
+		evm.Context.OriginalTargetReached = false
+		evm.Context.OriginalTargetReverted = false
+	}
+	if UsingOVM && !direct {
 		if caller.Address() == evm.Context.OvmExecutionManager.Address &&
 			!strings.HasPrefix(strings.ToLower(addr.Hex()), "0xdeaddeaddeaddeaddeaddeaddeaddeaddead") &&
 			!strings.HasPrefix(strings.ToLower(addr.Hex()), "0x000000000000000000000000000000000000") &&
@@ -359,7 +371,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		}
 	}
 
-	if UsingOVM {
+	if UsingOVM && !direct {
 		// OVM_ENABLED
 
 		if isTarget {
@@ -395,6 +407,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 					// If the first 32 bytes are the ABI encoding of "false", then we need to add an
 					// artificial error that represents the revert.
 					err = errExecutionReverted
+					evm.Context.OriginalTargetReverted = true
 
 					// We also currently need to add an extra four empty bytes to the return data
 					// to appease ethers.js. Our return correctly inserts the four specific bytes
@@ -1009,7 +1009,12 @@ func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOr
 	if overrides != nil {
 		accounts = *overrides
 	}
-	result, _, _, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCGasCap())
+	result, _, failed, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCGasCap())
+	if err == nil && failed {
+		if reason, ok := core.RevertReason(result); ok {
+			return nil, fmt.Errorf("execution reverted: %s", reason)
+		}
+	}
 	return (hexutil.Bytes)(result), err
 }
 
@@ -53,6 +53,8 @@ type Config struct {
 	GasPrice  *big.Int       // Minimum gas price for mining a transaction
 	Recommit  time.Duration  // The time interval for miner to re-create mining work.
 	Noverify  bool           // Disable remote mining solution verification(only useful in ethash).
+
+	MaxDepositsPerBlock int // Maximum number of L1 to L2 deposits in a mined block (0 = unlimited)
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -819,6 +819,9 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
 			w.current.tcount++
+			if qo := tx.QueueOrigin(); qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2) {
+				txs.IncludeDeposit()
+			}
 			txs.Shift()
 
 		default:
@@ -1016,14 +1019,23 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 			localTxs[account] = txs
 		}
 	}
+	// Deposits beyond the limit stay in the pool for the next block
+	deposits := w.config.MaxDepositsPerBlock
 	if len(localTxs) > 0 {
 		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
+		if deposits > 0 {
+			txs.SetMaxDeposits(deposits)
+		}
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
+		deposits -= txs.Deposits()
 	}
 	if len(remoteTxs) > 0 {
 		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
+		if w.config.MaxDepositsPerBlock > 0 {
+			txs.SetMaxDeposits(deposits)
+		}
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
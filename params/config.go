@@ -216,16 +216,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(108), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(108), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil, nil, nil, nil, nil, nil, false, nil, 0, nil, nil, nil, 0}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(420), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(420), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, nil, nil, nil, nil, nil, false, nil, 0, nil, nil, nil, 0}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil, nil, nil, nil, nil, nil, false, nil, 0, nil, nil, nil, 0}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -304,6 +304,32 @@ type ChainConfig struct {
 
 	// OVM Specific
 	StateDump *dump.OvmDump `json:"-"`
+
+	OvmCreationGasBlock   *big.Int `json:"ovmCreationGasBlock,omitempty"`   // Execution manager creation gas deduction switch block (nil = no fork, 0 = already activated)
+	OvmL1TimestampBlock   *big.Int `json:"ovmL1TimestampBlock,omitempty"`   // Execution manager message timestamp switch block (nil = no fork, 0 = already activated)
+	OvmL1BlockNumberBlock *big.Int `json:"ovmL1BlockNumberBlock,omitempty"` // Execution manager deposit block number switch block (nil = no fork, 0 = already activated)
+
+	OvmGodAddressBlock  *big.Int        `json:"ovmGodAddressBlock,omitempty"`  // God address switch block, from which the god address settings apply (nil = no fork, 0 = already activated)
+	OvmGodAddress       *common.Address `json:"ovmGodAddress,omitempty"`       // Sender of privileged system transactions, which are executed without wrapping (nil = none)
+	OvmAllowGodSelfCall bool            `json:"ovmAllowGodSelfCall,omitempty"` // Whether the god address may send transactions to itself
+
+	OvmDepositGasFloorBlock *big.Int `json:"ovmDepositGasFloorBlock,omitempty"` // Deposit gas floor switch block (nil = no fork, 0 = already activated)
+	OvmDepositGasFloor      uint64   `json:"ovmDepositGasFloor,omitempty"`      // Minimum gas limit L1 to L2 messages are executed with from the switch block
+
+	OvmDecompressorForks []OvmDecompressorFork `json:"ovmDecompressorForks,omitempty"` // Sequencer decompressor upgrades, by ascending block (nil = state dump entrypoint only)
+
+	OvmEntrypointCodeCheckBlock *big.Int `json:"ovmEntrypointCodeCheckBlock,omitempty"` // Entrypoint code check switch block, rejecting sequencer transactions to an entrypoint without code (nil = no fork, 0 = already activated)
+
+	OvmDefaultSighashTypeBlock *big.Int `json:"ovmDefaultSighashTypeBlock,omitempty"` // Default signature hash type switch block (nil = no fork, 0 = already activated)
+	OvmDefaultSighashType      uint8    `json:"ovmDefaultSighashType,omitempty"`      // Signature hash type that messages without a known one are encoded as from the switch block
+}
+
+// OvmDecompressorFork schedules the sequencer decompressor that sequencer
+// transactions are sent to from a block onwards.
+type OvmDecompressorFork struct {
+	Block          *big.Int       `json:"block"`
+	Address        common.Address `json:"address"`
+	GasPriceScalar uint64         `json:"gasPriceScalar,omitempty"` // Divisor of the compressed gas price of the decompressor (0 = default)
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -410,6 +436,95 @@ func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
+// IsOvmCreationGas returns whether num is either equal to the OVM creation gas
+// fork block or greater.
+func (c *ChainConfig) IsOvmCreationGas(num *big.Int) bool {
+	return isForked(c.OvmCreationGasBlock, num)
+}
+
+// IsOvmL1Timestamp returns whether num is either equal to the OVM L1 timestamp
+// fork block or greater.
+func (c *ChainConfig) IsOvmL1Timestamp(num *big.Int) bool {
+	return isForked(c.OvmL1TimestampBlock, num)
+}
+
+// IsOvmL1BlockNumber returns whether num is either equal to the OVM L1 block
+// number fork block or greater.
+func (c *ChainConfig) IsOvmL1BlockNumber(num *big.Int) bool {
+	return isForked(c.OvmL1BlockNumberBlock, num)
+}
+
+// IsOvmEntrypointCodeCheck returns whether num is either equal to the OVM
+// entrypoint code check fork block or greater.
+func (c *ChainConfig) IsOvmEntrypointCodeCheck(num *big.Int) bool {
+	return isForked(c.OvmEntrypointCodeCheckBlock, num)
+}
+
+// OvmDepositGasFloorAt returns the minimum gas limit L1 to L2 messages are
+// executed with at block num, which is zero before the deposit gas floor fork.
+func (c *ChainConfig) OvmDepositGasFloorAt(num *big.Int) uint64 {
+	if !isForked(c.OvmDepositGasFloorBlock, num) {
+		return 0
+	}
+	return c.OvmDepositGasFloor
+}
+
+// OvmDefaultSighashTypeAt returns the signature hash type that messages
+// without a known signature hash type are encoded as at block num, or false
+// if the default signature hash type fork is not active.
+func (c *ChainConfig) OvmDefaultSighashTypeAt(num *big.Int) (uint8, bool) {
+	if !isForked(c.OvmDefaultSighashTypeBlock, num) {
+		return 0, false
+	}
+	return c.OvmDefaultSighashType, true
+}
+
+// OvmDecompressorAt returns the address of the sequencer decompressor of the
+// latest decompressor fork active at block num, or false if there is none.
+func (c *ChainConfig) OvmDecompressorAt(num *big.Int) (common.Address, bool) {
+	for i := len(c.OvmDecompressorForks) - 1; i >= 0; i-- {
+		if isForked(c.OvmDecompressorForks[i].Block, num) {
+			return c.OvmDecompressorForks[i].Address, true
+		}
+	}
+	return common.Address{}, false
+}
+
+// OvmGasPriceScalarAt returns the divisor of the compressed gas price of the
+// latest decompressor fork active at block num, or false if there is none or
+// it keeps the default divisor.
+func (c *ChainConfig) OvmGasPriceScalarAt(num *big.Int) (uint64, bool) {
+	for i := len(c.OvmDecompressorForks) - 1; i >= 0; i-- {
+		if isForked(c.OvmDecompressorForks[i].Block, num) {
+			scalar := c.OvmDecompressorForks[i].GasPriceScalar
+			return scalar, scalar != 0
+		}
+	}
+	return 0, false
+}
+
+// IsOvmGodAddress returns whether addr is the god address, the sender of
+// privileged system transactions, at block num. There is no god address
+// before the god address fork block.
+func (c *ChainConfig) IsOvmGodAddress(num *big.Int, addr common.Address) bool {
+	return isForked(c.OvmGodAddressBlock, num) && c.OvmGodAddress != nil && *c.OvmGodAddress == addr
+}
+
+// OvmGodAddressAt returns the god address at block num, or nil if there is
+// none.
+func (c *ChainConfig) OvmGodAddressAt(num *big.Int) *common.Address {
+	if !isForked(c.OvmGodAddressBlock, num) {
+		return nil
+	}
+	return c.OvmGodAddress
+}
+
+// IsOvmGodSelfCallAllowed returns whether the god address may send
+// transactions to itself at block num.
+func (c *ChainConfig) IsOvmGodSelfCallAllowed(num *big.Int) bool {
+	return isForked(c.OvmGodAddressBlock, num) && c.OvmAllowGodSelfCall
+}
+
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
 func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
@@ -462,6 +577,12 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 		}
 		lastFork = cur
 	}
+	for i := 1; i < len(c.OvmDecompressorForks); i++ {
+		prev, cur := c.OvmDecompressorForks[i-1].Block, c.OvmDecompressorForks[i].Block
+		if prev == nil || cur == nil || prev.Cmp(cur) >= 0 {
+			return fmt.Errorf("unsupported decompressor fork ordering: fork %d at %v, but fork %d at %v", i-1, prev, i, cur)
+		}
+	}
 	return nil
 }
 
@@ -505,6 +626,63 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.OvmCreationGasBlock, newcfg.OvmCreationGasBlock, head) {
+		return newCompatError("OVM creation gas fork block", c.OvmCreationGasBlock, newcfg.OvmCreationGasBlock)
+	}
+	if isForkIncompatible(c.OvmL1TimestampBlock, newcfg.OvmL1TimestampBlock, head) {
+		return newCompatError("OVM L1 timestamp fork block", c.OvmL1TimestampBlock, newcfg.OvmL1TimestampBlock)
+	}
+	if isForkIncompatible(c.OvmL1BlockNumberBlock, newcfg.OvmL1BlockNumberBlock, head) {
+		return newCompatError("OVM L1 block number fork block", c.OvmL1BlockNumberBlock, newcfg.OvmL1BlockNumberBlock)
+	}
+	if isForkIncompatible(c.OvmGodAddressBlock, newcfg.OvmGodAddressBlock, head) {
+		return newCompatError("OVM god address fork block", c.OvmGodAddressBlock, newcfg.OvmGodAddressBlock)
+	}
+	if isForked(c.OvmGodAddressBlock, head) && (!equalAddress(c.OvmGodAddress, newcfg.OvmGodAddress) || c.OvmAllowGodSelfCall != newcfg.OvmAllowGodSelfCall) {
+		return newCompatError("OVM god address", c.OvmGodAddressBlock, newcfg.OvmGodAddressBlock)
+	}
+	if isForkIncompatible(c.OvmDepositGasFloorBlock, newcfg.OvmDepositGasFloorBlock, head) {
+		return newCompatError("OVM deposit gas floor fork block", c.OvmDepositGasFloorBlock, newcfg.OvmDepositGasFloorBlock)
+	}
+	if isForked(c.OvmDepositGasFloorBlock, head) && c.OvmDepositGasFloor != newcfg.OvmDepositGasFloor {
+		return newCompatError("OVM deposit gas floor", c.OvmDepositGasFloorBlock, newcfg.OvmDepositGasFloorBlock)
+	}
+	if isForkIncompatible(c.OvmEntrypointCodeCheckBlock, newcfg.OvmEntrypointCodeCheckBlock, head) {
+		return newCompatError("OVM entrypoint code check fork block", c.OvmEntrypointCodeCheckBlock, newcfg.OvmEntrypointCodeCheckBlock)
+	}
+	if isForkIncompatible(c.OvmDefaultSighashTypeBlock, newcfg.OvmDefaultSighashTypeBlock, head) {
+		return newCompatError("OVM default signature hash type fork block", c.OvmDefaultSighashTypeBlock, newcfg.OvmDefaultSighashTypeBlock)
+	}
+	if isForked(c.OvmDefaultSighashTypeBlock, head) && c.OvmDefaultSighashType != newcfg.OvmDefaultSighashType {
+		return newCompatError("OVM default signature hash type", c.OvmDefaultSighashTypeBlock, newcfg.OvmDefaultSighashTypeBlock)
+	}
+	stored, next := activeDecompressorForks(c, head), activeDecompressorForks(newcfg, head)
+	for i := 0; i < len(stored) || i < len(next); i++ {
+		if i >= len(stored) || i >= len(next) || !configNumEqual(stored[i].Block, next[i].Block) || stored[i].Address != next[i].Address || stored[i].GasPriceScalar != next[i].GasPriceScalar {
+			return newCompatError("OVM decompressor fork", forkBlock(stored, i), forkBlock(next, i))
+		}
+	}
+	return nil
+}
+
+// activeDecompressorForks returns the decompressor forks of the config that
+// are active at the given head block.
+func activeDecompressorForks(c *ChainConfig, head *big.Int) []OvmDecompressorFork {
+	var forks []OvmDecompressorFork
+	for _, fork := range c.OvmDecompressorForks {
+		if isForked(fork.Block, head) {
+			forks = append(forks, fork)
+		}
+	}
+	return forks
+}
+
+// forkBlock returns the block of the i-th decompressor fork, or nil if there
+// is no such fork.
+func forkBlock(forks []OvmDecompressorFork, i int) *big.Int {
+	if i < len(forks) {
+		return forks[i].Block
+	}
 	return nil
 }
 
@@ -522,6 +700,14 @@ func isForked(s, head *big.Int) bool {
 	return s.Cmp(head) <= 0
 }
 
+// equalAddress returns whether both addresses are nil or equal.
+func equalAddress(x, y *common.Address) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return *x == *y
+}
+
 func configNumEqual(x, y *big.Int) bool {
 	if x == nil {
 		return y == nil
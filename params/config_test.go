@@ -20,6 +20,8 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -70,6 +72,84 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored:  &ChainConfig{OvmDecompressorForks: []OvmDecompressorFork{{Block: big.NewInt(10)}}},
+			new:     &ChainConfig{OvmDecompressorForks: []OvmDecompressorFork{{Block: big.NewInt(10)}, {Block: big.NewInt(30)}}},
+			head:    20,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{OvmDecompressorForks: []OvmDecompressorFork{{Block: big.NewInt(10)}}},
+			new:    &ChainConfig{OvmDecompressorForks: []OvmDecompressorFork{{Block: big.NewInt(10)}, {Block: big.NewInt(15)}}},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "OVM decompressor fork",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(15),
+				RewindTo:     14,
+			},
+		},
+		{
+			stored: &ChainConfig{OvmDecompressorForks: []OvmDecompressorFork{{Block: big.NewInt(10), GasPriceScalar: 1000}}},
+			new:    &ChainConfig{OvmDecompressorForks: []OvmDecompressorFork{{Block: big.NewInt(10)}}},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "OVM decompressor fork",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{OvmDefaultSighashTypeBlock: big.NewInt(10), OvmDefaultSighashType: 1},
+			new:    &ChainConfig{OvmDefaultSighashTypeBlock: big.NewInt(10), OvmDefaultSighashType: 0},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "OVM default signature hash type",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{OvmEntrypointCodeCheckBlock: big.NewInt(10)},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "OVM entrypoint code check fork block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{OvmGodAddressBlock: big.NewInt(10), OvmGodAddress: &common.Address{1}},
+			new:    &ChainConfig{OvmGodAddressBlock: big.NewInt(10), OvmGodAddress: &common.Address{2}},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "OVM god address",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{OvmGodAddressBlock: big.NewInt(10), OvmGodAddress: &common.Address{1}},
+			new:    &ChainConfig{OvmGodAddressBlock: big.NewInt(10), OvmGodAddress: &common.Address{1}, OvmAllowGodSelfCall: true},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "OVM god address",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{OvmGodAddressBlock: big.NewInt(30), OvmGodAddress: &common.Address{1}},
+			new:     &ChainConfig{OvmGodAddressBlock: big.NewInt(30), OvmGodAddress: &common.Address{2}},
+			head:    20,
+			wantErr: nil,
+		},
 	}
 
 	for _, test := range tests {
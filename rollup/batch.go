@@ -0,0 +1,491 @@
+package rollup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrBatchTooLarge is returned by VerifyBatchSize if the encoded batch does
+// not fit in the L1 calldata limit.
+var ErrBatchTooLarge = errors.New("batch too large")
+
+// maxUint24 is the largest value that fits in the 3 byte fields of the
+// canonical transaction chain encoding.
+const maxUint24 = 1<<24 - 1
+
+// CreateEOAHashFunc computes the hash included in the canonical transaction
+// chain representation of a CreateEOA transaction.
+type CreateEOAHashFunc func(tx *types.Transaction, signer types.Signer) common.Hash
+
+// NewCTCTransaction converts a signed sequencer transaction into its
+// canonical transaction chain representation. The signer is used to make
+// sure that the signature is valid before it is serialized. The hash of
+// CreateEOA transactions is computed by createEOAHash, or is the signature
+// hash of the signer if it is nil.
+func NewCTCTransaction(tx *types.Transaction, signer types.Signer, createEOAHash CreateEOAHashFunc) (*CTCTransaction, error) {
+	qo := tx.QueueOrigin()
+	if qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2) {
+		return nil, errors.New("Cannot encode queue origin L1ToL2 transaction")
+	}
+	if _, err := types.Sender(signer, tx); err != nil {
+		return nil, fmt.Errorf("Cannot recover sender: %w", err)
+	}
+	if tx.Gas() > maxUint24 {
+		return nil, fmt.Errorf("Gas limit out of range: %d", tx.Gas())
+	}
+	if tx.Nonce() > maxUint24 {
+		return nil, fmt.Errorf("Nonce out of range: %d", tx.Nonce())
+	}
+	gasPrice, err := types.EncodeGasPrice(tx.GasPrice())
+	if err != nil {
+		return nil, err
+	}
+
+	v, r, s := tx.RawSignatureValues()
+	var sig [65]byte
+	copy(sig[:32], common.LeftPadBytes(r.Bytes(), 32))
+	copy(sig[32:64], common.LeftPadBytes(s.Bytes(), 32))
+	if tx.Protected() {
+		sig[64] = byte(v.Uint64() - 35 - 2*tx.ChainId().Uint64())
+	} else {
+		sig[64] = byte(v.Uint64() - 27)
+	}
+
+	// Contract creations are represented by the zero address
+	var target common.Address
+	if tx.To() != nil {
+		target = *tx.To()
+	}
+
+	switch tx.SignatureHashType() {
+	case types.SighashEIP155:
+		return &CTCTransaction{
+			typ: CTCTransactionTypeEIP155,
+			tx: &CTCTxEIP155{
+				Signature: sig,
+				gasLimit:  uint32(tx.Gas()),
+				gasPrice:  gasPrice,
+				nonce:     uint32(tx.Nonce()),
+				target:    target,
+				data:      tx.Data(),
+			},
+		}, nil
+	case types.SighashEthSign:
+		return &CTCTransaction{
+			typ: CTCTransactionTypeEthSign,
+			tx: &CTCTxEthSign{
+				Signature: sig,
+				gasLimit:  uint32(tx.Gas()),
+				gasPrice:  gasPrice,
+				nonce:     uint32(tx.Nonce()),
+				target:    target,
+				data:      tx.Data(),
+			},
+		}, nil
+	case types.CreateEOA:
+		if createEOAHash == nil {
+			createEOAHash = func(tx *types.Transaction, signer types.Signer) common.Hash {
+				return signer.Hash(tx)
+			}
+		}
+		return &CTCTransaction{
+			typ: CTCTransactionTypeEOA,
+			tx: &CTCTxCreateEOA{
+				Signature: sig,
+				Hash:      createEOAHash(tx, signer),
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("Cannot encode signature hash type: %d", tx.SignatureHashType())
+	}
+}
+
+// encodeBatchTransaction serializes a sequencer transaction as a batch
+// element, which is the canonical transaction chain encoding prefixed
+// by its 3 byte length.
+func encodeBatchTransaction(tx *types.Transaction, signer types.Signer) ([]byte, error) {
+	ctcTx, err := NewCTCTransaction(tx, signer, nil)
+	if err != nil {
+		return nil, err
+	}
+	length, err := ctcTx.Len()
+	if err != nil {
+		return nil, err
+	}
+	if length > maxUint24 {
+		return nil, fmt.Errorf("Transaction too large: %d", length)
+	}
+	b := make([]byte, 3+length)
+	b[0] = byte(length >> 16)
+	b[1] = byte(length >> 8)
+	b[2] = byte(length)
+	if err := ctcTx.Encode(b[3:]); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// PackTransactionsForBatch encodes transactions in order until the next
+// transaction would exceed maxBytes. It returns the transactions that fit
+// along with their encoding. Transactions are never skipped, so that the
+// nonce ordering of the input is preserved.
+func PackTransactionsForBatch(txs types.Transactions, maxBytes int, signer types.Signer) (types.Transactions, []byte, error) {
+	fit := types.Transactions{}
+	encoded := new(bytes.Buffer)
+	for _, tx := range txs {
+		b, err := encodeBatchTransaction(tx, signer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot encode transaction %s: %w", tx.Hash().Hex(), err)
+		}
+		if encoded.Len()+len(b) > maxBytes {
+			break
+		}
+		encoded.Write(b)
+		fit = append(fit, tx)
+	}
+	return fit, encoded.Bytes(), nil
+}
+
+// ToTransaction converts the canonical transaction chain representation of a
+// sequencer transaction back into a signed transaction. The signer provides
+// the chain id that is folded into the signature.
+func (c *CTCTransaction) ToTransaction(signer types.Signer) (*types.Transaction, error) {
+	var (
+		sig         [65]byte
+		gasLimit    uint32
+		gasPrice    uint32
+		nonce       uint32
+		target      common.Address
+		data        []byte
+		sighashType types.SignatureHashType
+	)
+	switch tx := c.tx.(type) {
+	case *CTCTxEIP155:
+		sig, gasLimit, gasPrice, nonce, target, data = tx.Signature, tx.gasLimit, tx.gasPrice, tx.nonce, tx.target, tx.data
+		sighashType = types.SighashEIP155
+	case *CTCTxEthSign:
+		sig, gasLimit, gasPrice, nonce, target, data = tx.Signature, tx.gasLimit, tx.gasPrice, tx.nonce, tx.target, tx.data
+		sighashType = types.SighashEthSign
+	default:
+		return nil, fmt.Errorf("Cannot convert ctc tx of type %d", c.typ)
+	}
+
+	price := types.DecodeGasPrice(gasPrice)
+	var tx *types.Transaction
+	// The zero address represents a contract creation
+	if target == (common.Address{}) {
+		tx = types.NewContractCreation(uint64(nonce), new(big.Int), uint64(gasLimit), price, data, nil, nil, types.QueueOriginSequencer)
+		tx.SetSignatureHashType(sighashType)
+	} else {
+		tx = types.NewTransaction(uint64(nonce), target, new(big.Int), uint64(gasLimit), price, data, nil, nil, types.QueueOriginSequencer, sighashType)
+	}
+	return tx.WithSignature(signer, sig[:])
+}
+
+// newSequencerBatch builds the sequencer batch calldata for the
+// transactions. A new context is started whenever a sequencer transaction
+// follows a deposit or has a different L1 timestamp or block number.
+func newSequencerBatch(txs types.Transactions, signer types.Signer) (*appendSequencerBatchCallData, error) {
+	batch := appendSequencerBatchCallData{
+		ShouldStartAtBatch:    new(big.Int),
+		TotalElementsToAppend: new(big.Int).SetUint64(uint64(len(txs))),
+	}
+	var ctx *ctcBatchContext
+	for _, tx := range txs {
+		qo := tx.QueueOrigin()
+		if qo != nil && qo.Uint64() == uint64(types.QueueOriginL1ToL2) {
+			if ctx == nil {
+				batch.Contexts = append(batch.Contexts, newBatchContext(tx))
+				ctx = &batch.Contexts[len(batch.Contexts)-1]
+			}
+			ctx.NumSubsequentQueueTransactions.Add(ctx.NumSubsequentQueueTransactions, common.Big1)
+			batch.ChainElements = append(batch.ChainElements, chainElement{})
+			continue
+		}
+		b, err := encodeBatchTransaction(tx, signer)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot encode transaction %s: %w", tx.Hash().Hex(), err)
+		}
+		// A sequencer transaction cannot follow the queue transactions of a
+		// context, and every transaction in a context shares its timestamp
+		// and block number.
+		next := newBatchContext(tx)
+		if ctx == nil || ctx.NumSubsequentQueueTransactions.Sign() != 0 ||
+			ctx.Timestamp.Cmp(next.Timestamp) != 0 || ctx.BlockNumber.Cmp(next.BlockNumber) != 0 {
+			batch.Contexts = append(batch.Contexts, next)
+			ctx = &batch.Contexts[len(batch.Contexts)-1]
+		}
+		ctx.NumSequencedTransactions.Add(ctx.NumSequencedTransactions, common.Big1)
+		batch.ChainElements = append(batch.ChainElements, chainElement{
+			IsSequenced: true,
+			Timestamp:   ctx.Timestamp,
+			BlockNumber: ctx.BlockNumber,
+			TxData:      b[3:],
+		})
+	}
+	return &batch, nil
+}
+
+// VerifyBatchRoundTrip encodes the transactions as the calldata of a
+// sequencer batch, decodes the calldata and checks that every sequencer
+// transaction decodes to a transaction with the same hash. Deposits are not
+// part of the calldata, so it is only checked that they decode to queue
+// elements at the same position.
+func VerifyBatchRoundTrip(txs types.Transactions, signer types.Signer) error {
+	batch, err := newSequencerBatch(txs, signer)
+	if err != nil {
+		return err
+	}
+
+	encoded := new(bytes.Buffer)
+	if err := batch.Encode(encoded); err != nil {
+		return fmt.Errorf("Cannot encode batch: %w", err)
+	}
+	decoded := appendSequencerBatchCallData{}
+	if err := decoded.Decode(bytes.NewReader(encoded.Bytes())); err != nil {
+		return fmt.Errorf("Cannot decode batch: %w", err)
+	}
+	if len(decoded.ChainElements) != len(txs) {
+		return fmt.Errorf("Decoded %d elements, expected %d", len(decoded.ChainElements), len(txs))
+	}
+
+	for i, element := range decoded.ChainElements {
+		if element.IsSequenced != batch.ChainElements[i].IsSequenced {
+			return fmt.Errorf("Element %d decoded with wrong queue origin", i)
+		}
+		if !element.IsSequenced {
+			continue
+		}
+		ctcTx := CTCTransaction{}
+		if err := ctcTx.Decode(element.TxData); err != nil {
+			return fmt.Errorf("Cannot decode element %d: %w", i, err)
+		}
+		tx, err := ctcTx.ToTransaction(signer)
+		if err != nil {
+			return fmt.Errorf("Cannot convert element %d: %w", i, err)
+		}
+		if tx.Hash() != txs[i].Hash() {
+			return fmt.Errorf("Element %d hash mismatch: got %s, expected %s", i, tx.Hash().Hex(), txs[i].Hash().Hex())
+		}
+	}
+	return nil
+}
+
+// VerifyBatchSize encodes the transactions as the calldata of a sequencer
+// batch and returns ErrBatchTooLarge if the encoding is larger than
+// maxBytes.
+func VerifyBatchSize(txs types.Transactions, signer types.Signer, maxBytes int) error {
+	batch, err := newSequencerBatch(txs, signer)
+	if err != nil {
+		return err
+	}
+	encoded := new(bytes.Buffer)
+	if err := batch.Encode(encoded); err != nil {
+		return fmt.Errorf("Cannot encode batch: %w", err)
+	}
+	if encoded.Len() > maxBytes {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrBatchTooLarge, encoded.Len(), maxBytes)
+	}
+	return nil
+}
+
+// newBatchContext returns an empty batch context with the L1 timestamp and
+// block number of the transaction.
+func newBatchContext(tx *types.Transaction) ctcBatchContext {
+	blockNumber := new(big.Int)
+	if bn := tx.L1BlockNumber(); bn != nil {
+		blockNumber.Set(bn)
+	}
+	return ctcBatchContext{
+		NumSequencedTransactions:       new(big.Int),
+		NumSubsequentQueueTransactions: new(big.Int),
+		Timestamp:                      new(big.Int).SetUint64(tx.L1Timestamp()),
+		BlockNumber:                    blockNumber,
+	}
+}
+
+// SequencerBatchReader decodes the sequencer transactions of sequencer batch
+// calldata one at a time, so that large batches do not need to be held in
+// memory. The batch header and contexts are read when the reader is created,
+// the transactions are read as they are requested. Queue elements carry no
+// calldata and are skipped.
+type SequencerBatchReader struct {
+	r        io.Reader
+	signer   types.Signer
+	contexts []ctcBatchContext
+	context  int    // Index of the context of the next transaction
+	read     uint64 // Number of transactions read from the current context
+}
+
+// NewSequencerBatchReader reads the header and contexts of the batch from r.
+// The signer is used to reconstruct the signatures of the transactions.
+func NewSequencerBatchReader(r io.Reader, signer types.Signer) (*SequencerBatchReader, error) {
+	header := make([]byte, 5+3+3)
+	if err := readFull(r, header); err != nil {
+		return nil, fmt.Errorf("Cannot read batch header: %w", err)
+	}
+	totalElements := new(big.Int).SetBytes(header[5:8]).Uint64()
+	ctxCount := new(big.Int).SetBytes(header[8:11]).Uint64()
+
+	// The context count comes from the input, so the contexts are only
+	// allocated as they are actually read.
+	var contexts []ctcBatchContext
+	txCount := uint64(0)
+	for i := uint64(0); i < ctxCount; i++ {
+		var ctx ctcBatchContext
+		b := make([]byte, ctx.Len())
+		if err := readFull(r, b); err != nil {
+			return nil, fmt.Errorf("Cannot read batch context: %w", err)
+		}
+		if err := ctx.Decode(bytes.NewReader(b)); err != nil {
+			return nil, fmt.Errorf("Cannot decode batch context: %w", err)
+		}
+		txCount += ctx.NumSequencedTransactions.Uint64()
+		txCount += ctx.NumSubsequentQueueTransactions.Uint64()
+		contexts = append(contexts, ctx)
+	}
+	if txCount != totalElements {
+		return nil, errors.New("Incorrect number of elements")
+	}
+	return &SequencerBatchReader{
+		r:        r,
+		signer:   signer,
+		contexts: contexts,
+	}, nil
+}
+
+// Next returns the next sequencer transaction of the batch, with the L1
+// timestamp and block number of its context. It returns io.EOF once all
+// transactions have been read. A transaction that fails to decode is
+// skipped, so the following transaction can still be read.
+func (b *SequencerBatchReader) Next() (*types.Transaction, error) {
+	data, ctx, err := b.nextData()
+	if err != nil {
+		return nil, err
+	}
+	return b.decode(data, ctx)
+}
+
+// nextData reads the encoded next sequencer transaction and its context.
+// An error means that the layout of the batch could not be followed.
+func (b *SequencerBatchReader) nextData() ([]byte, ctcBatchContext, error) {
+	for b.context < len(b.contexts) && b.read == b.contexts[b.context].NumSequencedTransactions.Uint64() {
+		b.context++
+		b.read = 0
+	}
+	if b.context == len(b.contexts) {
+		return nil, ctcBatchContext{}, io.EOF
+	}
+	ctx := b.contexts[b.context]
+
+	header := make([]byte, 3)
+	if err := readFull(b.r, header); err != nil {
+		return nil, ctx, fmt.Errorf("Cannot read tx header: %w", err)
+	}
+	size := int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+	data := make([]byte, size)
+	if err := readFull(b.r, data); err != nil {
+		return nil, ctx, fmt.Errorf("Cannot read tx: %w", err)
+	}
+	b.read++
+	return data, ctx, nil
+}
+
+// decode builds the transaction from its encoding and context.
+func (b *SequencerBatchReader) decode(data []byte, ctx ctcBatchContext) (*types.Transaction, error) {
+	ctcTx := CTCTransaction{}
+	if err := ctcTx.Decode(data); err != nil {
+		return nil, err
+	}
+	tx, err := ctcTx.ToTransaction(b.signer)
+	if err != nil {
+		return nil, err
+	}
+	tx.SetL1Timestamp(ctx.Timestamp.Uint64())
+	tx.SetL1BlockNumber(ctx.BlockNumber.Uint64())
+	return tx, nil
+}
+
+// BatchTxError is the error of a sequencer transaction of a batch that
+// could not be decoded.
+type BatchTxError struct {
+	Index int // Position of the transaction among the sequencer transactions
+	Err   error
+}
+
+func (e *BatchTxError) Error() string {
+	return fmt.Sprintf("Cannot decode tx %d: %s", e.Index, e.Err)
+}
+
+func (e *BatchTxError) Unwrap() error { return e.Err }
+
+// DecodeSequencerBatchLenient decodes the sequencer transactions of sequencer
+// batch calldata, skipping the transactions that fail to decode. It returns
+// the decoded transactions and a BatchTxError for every skipped transaction.
+// If the layout of the batch itself is broken, decoding stops there and the
+// last error says so.
+func DecodeSequencerBatchLenient(data []byte, signer types.Signer) (types.Transactions, []error) {
+	reader, err := NewSequencerBatchReader(bytes.NewReader(data), signer)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var (
+		txs  types.Transactions
+		errs []error
+	)
+	for i := 0; ; i++ {
+		data, ctx, err := reader.nextData()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, &BatchTxError{Index: i, Err: err})
+			break
+		}
+		tx, err := reader.decode(data, ctx)
+		if err != nil {
+			errs = append(errs, &BatchTxError{Index: i, Err: err})
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	return txs, errs
+}
+
+// BatchIndex returns the position of the transaction with the given hash
+// among the sequencer transactions of sequencer batch calldata. The signer
+// is needed to rebuild the signatures, which are part of the hash.
+func BatchIndex(batch []byte, txHash common.Hash, signer types.Signer) (int, error) {
+	reader, err := NewSequencerBatchReader(bytes.NewReader(batch), signer)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; ; i++ {
+		tx, err := reader.Next()
+		if err == io.EOF {
+			return 0, fmt.Errorf("Cannot find tx %s in batch", txHash.Hex())
+		}
+		if err != nil {
+			return 0, err
+		}
+		if tx.Hash() == txHash {
+			return i, nil
+		}
+	}
+}
+
+// readFull reads exactly len(b) bytes from r. Running out of input is
+// reported as io.ErrUnexpectedEOF, since the batch layout says more follows.
+func readFull(r io.Reader, b []byte) error {
+	_, err := io.ReadFull(r, b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,332 @@
+package rollup
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"io"
+	"math/big"
+	"runtime"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newTestSequencerTransactions(t *testing.T, key *ecdsa.PrivateKey, signer types.Signer, count int) types.Transactions {
+	txs := make(types.Transactions, count)
+	for i := 0; i < count; i++ {
+		to := common.HexToAddress("0x1212121212121212121212121212121212121212")
+		gasPrice := new(big.Int).Mul(big.NewInt(int64(i+1)), big.NewInt(types.GasPriceScalar))
+		sighashType := types.SighashEIP155
+		if i%2 == 1 {
+			sighashType = types.SighashEthSign
+		}
+		tx := types.NewTransaction(uint64(i), to, new(big.Int), 1000000, gasPrice, []byte("abcdef"), nil, nil, types.QueueOriginSequencer, sighashType)
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs[i] = tx
+	}
+	return txs
+}
+
+func TestPackTransactionsForBatch(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(420))
+	txs := newTestSequencerTransactions(t, key, signer, 4)
+
+	// Each element is a 3 byte header, a 1 byte type, 65 bytes of signature,
+	// 9 bytes of gas limit, gas price and nonce, 20 bytes of target and the data.
+	elementSize := 3 + 1 + 65 + 9 + 20 + len("abcdef")
+
+	tests := []struct {
+		maxBytes int
+		fit      int
+	}{
+		{maxBytes: elementSize * len(txs), fit: len(txs)},
+		{maxBytes: elementSize*len(txs) + 1, fit: len(txs)},
+		{maxBytes: elementSize*2 + elementSize/2, fit: 2},
+		{maxBytes: elementSize - 1, fit: 0},
+		{maxBytes: 0, fit: 0},
+	}
+
+	for _, test := range tests {
+		fit, encoded, err := PackTransactionsForBatch(txs, test.maxBytes, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fit) != test.fit {
+			t.Fatalf("Expected %d transactions to fit in %d bytes, got %d", test.fit, test.maxBytes, len(fit))
+		}
+		if len(encoded) != elementSize*test.fit {
+			t.Fatalf("Expected %d encoded bytes, got %d", elementSize*test.fit, len(encoded))
+		}
+		if len(encoded) > test.maxBytes {
+			t.Fatalf("Encoded %d bytes, more than the budget of %d", len(encoded), test.maxBytes)
+		}
+		for i, tx := range fit {
+			if tx != txs[i] {
+				t.Fatalf("Transaction %d packed out of order", i)
+			}
+		}
+	}
+
+	// The encoding of each element can be decoded as a ctc transaction
+	_, encoded, err := PackTransactionsForBatch(txs, elementSize, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctcTx := CTCTransaction{}
+	if err := ctcTx.Decode(encoded[3:]); err != nil {
+		t.Fatal(err)
+	}
+	eip155, ok := ctcTx.tx.(*CTCTxEIP155)
+	if !ok {
+		t.Fatal("Wrong type decoded")
+	}
+	if !bytes.Equal(eip155.data, txs[0].Data()) {
+		t.Fatal("Wrong data decoded")
+	}
+}
+
+func TestVerifyBatchRoundTrip(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(420))
+	sequenced := newTestSequencerTransactions(t, key, signer, 4)
+
+	l1Sender := common.HexToAddress("0x3434343434343434343434343434343434343434")
+	to := common.HexToAddress("0x1212121212121212121212121212121212121212")
+	deposit := func(i uint64) *types.Transaction {
+		tx := types.NewTransaction(i, to, new(big.Int), 1000000, new(big.Int), []byte("deposit"), &l1Sender, big.NewInt(1), types.QueueOriginL1ToL2, types.SighashEIP155)
+		tx.SetQueueIndex(i)
+		return tx
+	}
+	txs := types.Transactions{sequenced[0], deposit(0), deposit(1), sequenced[1], sequenced[2], deposit(2), sequenced[3]}
+	if err := VerifyBatchRoundTrip(txs, signer); err != nil {
+		t.Fatal(err)
+	}
+
+	// A gas price that is not a multiple of the scalar is truncated by the
+	// batch encoding and the decoded transaction hashes differently.
+	gasPrice := new(big.Int).Add(big.NewInt(types.GasPriceScalar), common.Big1)
+	tx := types.NewTransaction(0, to, new(big.Int), 1000000, gasPrice, nil, nil, nil, types.QueueOriginSequencer, types.SighashEIP155)
+	tx, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBatchRoundTrip(types.Transactions{tx, deposit(0)}, signer); err == nil {
+		t.Fatal("Expected round trip of truncated gas price to fail")
+	}
+}
+
+func TestVerifyBatchSize(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(420))
+	txs := newTestSequencerTransactions(t, key, signer, 4)
+
+	batch, err := newSequencerBatch(txs, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := new(bytes.Buffer)
+	if err := batch.Encode(encoded); err != nil {
+		t.Fatal(err)
+	}
+	size := encoded.Len()
+
+	if err := VerifyBatchSize(txs, signer, size); err != nil {
+		t.Fatalf("Expected batch of %d bytes to fit its size, got %v", size, err)
+	}
+	if err := VerifyBatchSize(txs, signer, size-1); !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("Expected %v, got %v", ErrBatchTooLarge, err)
+	}
+}
+
+func TestNewCTCTransactionCreateEOAHash(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(420))
+	to := common.HexToAddress("0x1212121212121212121212121212121212121212")
+	tx := types.NewTransaction(0, to, new(big.Int), 1000000, new(big.Int), nil, nil, nil, types.QueueOriginSequencer, types.CreateEOA)
+	tx, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashOf := func(tx *types.Transaction, createEOAHash CreateEOAHashFunc) common.Hash {
+		ctcTx, err := NewCTCTransaction(tx, signer, createEOAHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctcTx.typ != CTCTransactionTypeEOA {
+			t.Fatalf("expected ctc type %d, got %d", CTCTransactionTypeEOA, ctcTx.typ)
+		}
+		return ctcTx.tx.(*CTCTxCreateEOA).Hash
+	}
+
+	// The signature hash is used by default
+	if hash := hashOf(tx, nil); hash != signer.Hash(tx) {
+		t.Fatalf("expected signature hash %s, got %s", signer.Hash(tx).Hex(), hash.Hex())
+	}
+
+	custom := common.HexToHash("0x1234")
+	createEOAHash := func(*types.Transaction, types.Signer) common.Hash { return custom }
+	if hash := hashOf(tx, createEOAHash); hash != custom {
+		t.Fatalf("expected custom hash %s, got %s", custom.Hex(), hash.Hex())
+	}
+}
+
+func TestSequencerBatchReader(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(420))
+	sequenced := newTestSequencerTransactions(t, key, signer, 100)
+
+	// Spread the transactions over several contexts, separated by deposits
+	l1Sender := common.HexToAddress("0x3434343434343434343434343434343434343434")
+	to := common.HexToAddress("0x1212121212121212121212121212121212121212")
+	var txs types.Transactions
+	for i, tx := range sequenced {
+		tx.SetL1Timestamp(uint64(1000 + i/25))
+		tx.SetL1BlockNumber(uint64(10 + i/25))
+		txs = append(txs, tx)
+		if i%25 == 24 {
+			deposit := types.NewTransaction(uint64(i), to, new(big.Int), 1000000, new(big.Int), nil, &l1Sender, big.NewInt(1), types.QueueOriginL1ToL2, types.SighashEIP155)
+			txs = append(txs, deposit)
+		}
+	}
+	batch, err := newSequencerBatch(txs, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Contexts) != 4 {
+		t.Fatalf("expected 4 contexts, got %d", len(batch.Contexts))
+	}
+	encoded := new(bytes.Buffer)
+	if err := batch.Encode(encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewSequencerBatchReader(bytes.NewReader(encoded.Bytes()), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range sequenced {
+		tx, err := reader.Next()
+		if err != nil {
+			t.Fatalf("transaction %d: %v", i, err)
+		}
+		if tx.Hash() != want.Hash() {
+			t.Fatalf("transaction %d: expected hash %s, got %s", i, want.Hash().Hex(), tx.Hash().Hex())
+		}
+		if tx.L1Timestamp() != want.L1Timestamp() || tx.L1BlockNumber().Cmp(want.L1BlockNumber()) != 0 {
+			t.Fatalf("transaction %d: context mismatch", i)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+
+	// A truncated batch fails on the last transaction
+	truncated := encoded.Bytes()[:encoded.Len()-1]
+	reader, err = NewSequencerBatchReader(bytes.NewReader(truncated), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(sequenced)-1; i++ {
+		if _, err := reader.Next(); err != nil {
+			t.Fatalf("transaction %d: %v", i, err)
+		}
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	// A header announcing more contexts than the input holds is rejected
+	// without allocating them
+	header := common.CopyBytes(encoded.Bytes()[:11])
+	header[8], header[9], header[10] = 0xff, 0xff, 0xff
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := NewSequencerBatchReader(bytes.NewReader(header), signer); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("expected a small allocation, got %d bytes", allocated)
+	}
+}
+
+func TestDecodeSequencerBatchLenient(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(420))
+	txs := newTestSequencerTransactions(t, key, signer, 5)
+	batch, err := newSequencerBatch(txs, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := new(bytes.Buffer)
+	if err := batch.Encode(encoded); err != nil {
+		t.Fatal(err)
+	}
+	data := encoded.Bytes()
+
+	// Give the third transaction an unknown type, keeping its length
+	offset := 11 + len(batch.Contexts)*(&ctcBatchContext{}).Len()
+	for i := 0; i < 2; i++ {
+		offset += 3 + (int(data[offset])<<16 | int(data[offset+1])<<8 | int(data[offset+2]))
+	}
+	data[offset+3] = 0xff
+
+	decoded, errs := DecodeSequencerBatchLenient(data, signer)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", errs)
+	}
+	var txErr *BatchTxError
+	if !errors.As(errs[0], &txErr) || txErr.Index != 2 {
+		t.Fatalf("expected an error for transaction 2, got %v", errs[0])
+	}
+	want := append(types.Transactions{txs[0], txs[1]}, txs[3:]...)
+	if len(decoded) != len(want) {
+		t.Fatalf("expected %d transactions, got %d", len(want), len(decoded))
+	}
+	for i, tx := range decoded {
+		if tx.Hash() != want[i].Hash() {
+			t.Fatalf("transaction %d: expected hash %s, got %s", i, want[i].Hash().Hex(), tx.Hash().Hex())
+		}
+	}
+
+	// A truncated batch stops at the transaction that cannot be read
+	decoded, errs = DecodeSequencerBatchLenient(data[:len(data)-1], signer)
+	if len(decoded) != 3 || len(errs) != 2 || !errors.Is(errs[1], io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected result for a truncated batch: %d transactions, errors %v", len(decoded), errs)
+	}
+}
+
+func TestBatchIndex(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(420))
+	txs := newTestSequencerTransactions(t, key, signer, 10)
+	batch, err := newSequencerBatch(txs, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := new(bytes.Buffer)
+	if err := batch.Encode(encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []int{0, 6, 9} {
+		index, err := BatchIndex(encoded.Bytes(), txs[i].Hash(), signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index != i {
+			t.Fatalf("expected index %d, got %d", i, index)
+		}
+	}
+	if _, err := BatchIndex(encoded.Bytes(), common.Hash{1}, signer); err == nil {
+		t.Fatal("expected an error for a transaction that is not in the batch")
+	}
+}
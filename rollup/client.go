@@ -197,6 +197,16 @@ func enqueueToTransaction(enqueue *Enqueue) (*types.Transaction, error) {
 	return tx, nil
 }
 
+// PredictDepositTxHash returns the hash of the L2 transaction that an enqueue
+// with the given fields is executed as, see enqueueToTransaction. The queue
+// index is the nonce of the transaction. The L1 block number and timestamp
+// are part of the metadata of the transaction and do not affect its hash, so
+// the hash can be predicted from the L1 event alone.
+func PredictDepositTxHash(l1Sender, to common.Address, data []byte, gas uint64, queueIndex hexutil.Uint64) common.Hash {
+	tx := types.NewTransaction(uint64(queueIndex), to, big.NewInt(0), gas, big.NewInt(0), data, &l1Sender, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	return tx.Hash()
+}
+
 func (c *Client) GetLatestEnqueue() (*types.Transaction, error) {
 	response, err := c.client.R().
 		SetResult(&Enqueue{}).
@@ -0,0 +1,40 @@
+package rollup
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestPredictDepositTxHash(t *testing.T) {
+	index, queueIndex := uint64(10), uint64(4)
+	target := common.HexToAddress("0x1212121212121212121212121212121212121212")
+	origin := common.HexToAddress("0x3434343434343434343434343434343434343434")
+	data := hexutil.Bytes("deposit")
+	gasLimit, blockNumber, timestamp := uint64(1000000), uint64(100), uint64(1600000000)
+
+	enqueue := &Enqueue{
+		Index:       &index,
+		Target:      &target,
+		Data:        &data,
+		GasLimit:    &gasLimit,
+		Origin:      &origin,
+		BlockNumber: &blockNumber,
+		Timestamp:   &timestamp,
+		QueueIndex:  &queueIndex,
+	}
+	tx, err := enqueueToTransaction(enqueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	predicted := PredictDepositTxHash(origin, target, data, gasLimit, hexutil.Uint64(queueIndex))
+	if predicted != tx.Hash() {
+		t.Fatalf("Predicted hash %s, expected %s", predicted.Hex(), tx.Hash().Hex())
+	}
+
+	// The queue index is part of the hash
+	if PredictDepositTxHash(origin, target, data, gasLimit, hexutil.Uint64(queueIndex+1)) == predicted {
+		t.Fatal("Expected a different queue index to change the hash")
+	}
+}
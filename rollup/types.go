@@ -40,6 +40,20 @@ type appendSequencerBatchCallData struct {
 	ChainElements         []chainElement
 }
 
+// readFullAt reads exactly len(b) bytes from r starting at offset. It
+// returns io.ErrUnexpectedEOF if fewer bytes are available so that
+// truncated input is reported instead of silently decoded.
+func readFullAt(r io.ReaderAt, b []byte, offset int64) (int, error) {
+	n, err := r.ReadAt(b, offset)
+	if n == len(b) {
+		return n, nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
 func (c *ctcBatchContext) Encode(w io.Writer) error {
 	elements := [][]byte{
 		common.LeftPadBytes(c.NumSequencedTransactions.Bytes(), 3),
@@ -70,8 +84,7 @@ func (c *ctcBatchContext) Decode(r io.ReaderAt) error {
 	}
 
 	for i, element := range elements {
-		sr := io.NewSectionReader(r, offset, int64(len(element)))
-		off, err := sr.Read(element)
+		off, err := readFullAt(r, element, offset)
 		if err != nil {
 			return err
 		}
@@ -142,8 +155,7 @@ func (a *appendSequencerBatchCallData) Decode(r io.ReaderAt) error {
 	}
 	ctxCount := new(big.Int)
 	for i, element := range elements {
-		sr := io.NewSectionReader(r, offset, int64(len(element)))
-		off, err := sr.Read(element)
+		off, err := readFullAt(r, element, offset)
 		if err != nil {
 			return err
 		}
@@ -162,7 +174,7 @@ func (a *appendSequencerBatchCallData) Decode(r io.ReaderAt) error {
 	a.Contexts = make([]ctcBatchContext, ctxCount.Uint64())
 	for i := uint64(0); i < ctxCount.Uint64(); i++ {
 		batchCtx := ctcBatchContext{}
-		sr := io.NewSectionReader(r, offset, offset+int64(batchCtx.Len()))
+		sr := io.NewSectionReader(r, offset, int64(batchCtx.Len()))
 		err := batchCtx.Decode(sr)
 		if err != nil {
 			return fmt.Errorf("Cannot decode batch context: %w", err)
@@ -187,8 +199,7 @@ func (a *appendSequencerBatchCallData) Decode(r io.ReaderAt) error {
 		blockNumber := ctx.BlockNumber
 		for i := uint64(0); i < ctx.NumSequencedTransactions.Uint64(); i++ {
 			header := make([]byte, 3)
-			sr := io.NewSectionReader(r, offset, offset+3)
-			off, err := sr.Read(header)
+			off, err := readFullAt(r, header, offset)
 
 			if err != nil {
 				return fmt.Errorf("Cannot read tx header: %w", err)
@@ -209,8 +220,7 @@ func (a *appendSequencerBatchCallData) Decode(r io.ReaderAt) error {
 
 			size := (sizeHi << 8) | uint16(sizeLo)
 			tx := make([]byte, size)
-			tsr := io.NewSectionReader(r, offset, offset+int64(size))
-			off, err = tsr.Read(tx)
+			off, err = readFullAt(r, tx, offset)
 			if err != nil {
 				return fmt.Errorf("Cannot read tx: %w", err)
 			}
@@ -250,6 +260,25 @@ const (
 type CTCTransaction struct {
 	typ CTCTransactionType
 	tx  Ser
+	// omitType skips the leading type byte when encoding and decoding.
+	// It is used for streams where every transaction is known to be of
+	// the same type, such as EOA creations. When decoding, typ must be
+	// set ahead of time.
+	omitType bool
+}
+
+// NewUntypedCTCTransaction creates a transaction that decodes an encoding
+// without the leading type byte, which is known to be of the given type.
+func NewUntypedCTCTransaction(typ CTCTransactionType) *CTCTransaction {
+	return &CTCTransaction{typ: typ, omitType: true}
+}
+
+// WithoutType returns a copy of the transaction that is encoded without the
+// leading type byte. It can be decoded by NewUntypedCTCTransaction.
+func (c *CTCTransaction) WithoutType() *CTCTransaction {
+	cpy := *c
+	cpy.omitType = true
+	return &cpy
 }
 
 func (c *CTCTransaction) Len() (int, error) {
@@ -260,6 +289,9 @@ func (c *CTCTransaction) Len() (int, error) {
 	if err != nil {
 		return 0, fmt.Errorf("Unable to compute length: %w", err)
 	}
+	if c.omitType {
+		return length, nil
+	}
 	return 1 + length, nil
 }
 
@@ -268,8 +300,11 @@ func (c *CTCTransaction) Encode(b []byte) error {
 	if len(b) < length {
 		return errors.New("Encoding overflow")
 	}
-	b[0] = uint8(c.typ)
-	err := c.tx.Encode(b[1:])
+	if !c.omitType {
+		b[0] = uint8(c.typ)
+		b = b[1:]
+	}
+	err := c.tx.Encode(b)
 	if err != nil {
 		return fmt.Errorf("Cannot encode ctc tx: %w", err)
 	}
@@ -278,34 +313,40 @@ func (c *CTCTransaction) Encode(b []byte) error {
 }
 
 func (c *CTCTransaction) Decode(b []byte) error {
-	// only care about the first byte, the other decode methods
-	// will handle length checks
-	if len(b) < 1 {
-		return errors.New("CTCTransaction Decoding overflow")
+	raw := b
+	if !c.omitType {
+		// only care about the first byte, the other decode methods
+		// will handle length checks
+		if len(b) < 1 {
+			return errors.New("CTCTransaction Decoding overflow")
+		}
+		c.typ = CTCTransactionType(b[0])
+		b = b[1:]
 	}
-	c.typ = CTCTransactionType(b[0])
 	switch c.typ {
 	case CTCTransactionTypeEOA:
 		tx := CTCTxCreateEOA{}
-		err := tx.Decode(b[1:])
+		err := tx.Decode(b)
 		if err != nil {
-			return fmt.Errorf("Cannot decode EOA ctc tx %x: %w", b, err)
+			return fmt.Errorf("Cannot decode EOA ctc tx %x: %w", raw, err)
 		}
 		c.tx = &tx
 	case CTCTransactionTypeEIP155:
 		tx := CTCTxEIP155{}
-		err := tx.Decode(b[1:])
+		err := tx.Decode(b)
 		if err != nil {
-			return fmt.Errorf("Cannot decode EIP155 ctc tx %x: %w", b, err)
+			return fmt.Errorf("Cannot decode EIP155 ctc tx %x: %w", raw, err)
 		}
 		c.tx = &tx
 	case CTCTransactionTypeEthSign:
 		tx := CTCTxEthSign{}
-		err := tx.Decode(b[1:])
+		err := tx.Decode(b)
 		if err != nil {
-			return fmt.Errorf("Cannot decode EthSign ctc tx %x: %w", b, err)
+			return fmt.Errorf("Cannot decode EthSign ctc tx %x: %w", raw, err)
 		}
 		c.tx = &tx
+	default:
+		return fmt.Errorf("Cannot decode ctc tx of unknown type %d", c.typ)
 	}
 
 	return nil
@@ -337,6 +378,10 @@ func (c *CTCTxCreateEOA) Decode(b []byte) error {
 	if len(b) < length {
 		return errors.New("CTCTxCreateEOA decoding overflow")
 	}
+	// Trailing bytes would be dropped when encoding again
+	if len(b) > length {
+		return errors.New("CTCTxCreateEOA decoding trailing bytes")
+	}
 	copy(c.Signature[:], b[:65])
 	copy(c.Hash[:], b[65:])
 	return nil
@@ -2,12 +2,16 @@ package rollup
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"math/big"
 	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 func TestCanonicalChainBatchContext(t *testing.T) {
@@ -244,6 +248,67 @@ func TestSequencerBatchCalldata(t *testing.T) {
 	}
 }
 
+func TestSequencerBatchCalldataTruncated(t *testing.T) {
+	input := appendSequencerBatchCallData{
+		ChainElements: []chainElement{
+			{
+				IsSequenced: true,
+				Timestamp:   big.NewInt(1602821663),
+				BlockNumber: big.NewInt(12),
+				TxData:      hexutil.MustDecode("0x12"),
+			},
+			{
+				IsSequenced: true,
+				Timestamp:   big.NewInt(1602821663),
+				BlockNumber: big.NewInt(12),
+				TxData:      hexutil.MustDecode("0x1234"),
+			},
+		},
+		Contexts: []ctcBatchContext{
+			{
+				NumSequencedTransactions:       big.NewInt(1),
+				NumSubsequentQueueTransactions: big.NewInt(0),
+				Timestamp:                      big.NewInt(1602821663),
+				BlockNumber:                    big.NewInt(12),
+			},
+			{
+				NumSequencedTransactions:       big.NewInt(1),
+				NumSubsequentQueueTransactions: big.NewInt(0),
+				Timestamp:                      big.NewInt(1602821663),
+				BlockNumber:                    big.NewInt(12),
+			},
+		},
+		ShouldStartAtBatch:    big.NewInt(0),
+		TotalElementsToAppend: big.NewInt(2),
+	}
+	buf := new(bytes.Buffer)
+	if err := input.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+
+	cd := appendSequencerBatchCallData{}
+	if err := cd.Decode(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("Cannot decode complete calldata: %s", err)
+	}
+
+	// Field boundaries: batch index, total elements, context count, each
+	// context, and each transaction header and body.
+	boundaries := []int{0, 5, 8, 11, 27, 43, 46, 47, 50}
+	for _, boundary := range boundaries {
+		for _, length := range []int{boundary, boundary + 1} {
+			if length >= len(raw) {
+				continue
+			}
+			cd := appendSequencerBatchCallData{}
+			err := cd.Decode(bytes.NewReader(raw[:length]))
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("Expected %v decoding %d of %d bytes, got %v", io.ErrUnexpectedEOF, length, len(raw), err)
+			}
+		}
+	}
+}
+
 func TestCTCTransactionDeserialization(t *testing.T) {
 	// Use a test vector generated by the javascript
 	raw := hexutil.MustDecode("0x0011111111111111111111111111111111111111111111111111111111111111112222222222222222222222222222222222222222222222222222222222222222010001f4000064000064121212121212121212121212121212121212121299999999999999999999")
@@ -462,6 +527,60 @@ func TestCTCTransactionSerialization(t *testing.T) {
 	}
 }
 
+func TestCTCTransactionOmitType(t *testing.T) {
+	raw := hexutil.MustDecode("0x789a80053e4927d0a898db8e065e948f5cf086e32f9ccaa54c1908e22ac430c62621578113ddbb62d509bf6049b8fb544ab06d36f916685a2eb8e57ffadde02301")
+	var sig [65]byte
+	copy(sig[:], raw)
+	eoa := &CTCTxCreateEOA{
+		Signature: sig,
+		Hash:      common.HexToHash("0xffcfa4cf82b5326f382ece74ba547c368677f922b8f652f5b370a38eccf5f8e1"),
+	}
+
+	for _, omitType := range []bool{false, true} {
+		tx := &CTCTransaction{typ: CTCTransactionTypeEOA, tx: eoa}
+		if omitType {
+			tx = tx.WithoutType()
+		}
+		length, err := tx.Len()
+		if err != nil {
+			t.Fatal(err)
+		}
+		inner, _ := eoa.Len()
+		if omitType && length != inner {
+			t.Fatalf("Expected length %d without type byte, got %d", inner, length)
+		}
+		if !omitType && length != inner+1 {
+			t.Fatalf("Expected length %d with type byte, got %d", inner+1, length)
+		}
+
+		encoded := make([]byte, length)
+		if err := tx.Encode(encoded); err != nil {
+			t.Fatalf("Cannot encode: %s", err)
+		}
+		if !omitType && encoded[0] != uint8(CTCTransactionTypeEOA) {
+			t.Fatalf("Expected type byte %d, got %d", CTCTransactionTypeEOA, encoded[0])
+		}
+		if omitType && !bytes.Equal(encoded[:65], sig[:]) {
+			t.Fatal("Expected encoding to start with the signature")
+		}
+
+		decoded := new(CTCTransaction)
+		if omitType {
+			decoded = NewUntypedCTCTransaction(CTCTransactionTypeEOA)
+		}
+		if err := decoded.Decode(encoded); err != nil {
+			t.Fatalf("Cannot decode: %s", err)
+		}
+		got, ok := decoded.tx.(*CTCTxCreateEOA)
+		if !ok {
+			t.Fatal("Cannot type cast")
+		}
+		if got.Signature != eoa.Signature || got.Hash != eoa.Hash {
+			t.Fatalf("Round trip mismatch with omitType=%t", omitType)
+		}
+	}
+}
+
 func isSequencerBatchCalldataEqual(one, two *appendSequencerBatchCallData) bool {
 	if one == nil && two == nil {
 		return true
@@ -532,3 +651,60 @@ func xornil(one, two interface{}) bool {
 
 	return false
 }
+
+func TestCTCTransactionCanonical(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewOVMSigner(big.NewInt(420))
+	to := common.HexToAddress("0x1212121212121212121212121212121212121212")
+
+	// The javascript test vector and one encoding of every type
+	fixtures := [][]byte{
+		hexutil.MustDecode("0x0011111111111111111111111111111111111111111111111111111111111111112222222222222222222222222222222222222222222222222222222222222222010001f4000064000064121212121212121212121212121212121212121299999999999999999999"),
+	}
+	for _, sighashType := range []types.SignatureHashType{types.SighashEIP155, types.SighashEthSign, types.CreateEOA} {
+		tx, err := types.SignTx(types.NewTransaction(1, to, new(big.Int), 21000, big.NewInt(1000000), []byte{1, 2, 3}, nil, nil, types.QueueOriginSequencer, sighashType), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctcTx, err := NewCTCTransaction(tx, signer, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, err := ctcTx.Len()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded := make([]byte, length)
+		if err := ctcTx.Encode(encoded); err != nil {
+			t.Fatal(err)
+		}
+		fixtures = append(fixtures, encoded)
+	}
+
+	for i, raw := range fixtures {
+		decoded := CTCTransaction{}
+		if err := decoded.Decode(raw); err != nil {
+			t.Fatalf("fixture %d: %v", i, err)
+		}
+		length, err := decoded.Len()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded := make([]byte, length)
+		if err := decoded.Encode(encoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw, encoded) {
+			t.Fatalf("fixture %d: re-encoding mismatch\ngot:\n%x\nexpected:\n%x", i, encoded, raw)
+		}
+	}
+
+	// Encodings that cannot be reproduced are rejected
+	eoa := fixtures[len(fixtures)-1]
+	if err := new(CTCTransaction).Decode(append(common.CopyBytes(eoa), 0)); err == nil {
+		t.Fatal("expected an error for an EOA creation with trailing bytes")
+	}
+	if err := new(CTCTransaction).Decode([]byte{0xff}); err == nil {
+		t.Fatal("expected an error for an unknown type")
+	}
+}